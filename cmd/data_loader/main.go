@@ -1,19 +1,25 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/signal"
 	"strings"
+	"syscall"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
 	"github.com/msalopek/solver_monitor/monitor"
 	"github.com/rs/zerolog"
 	"github.com/rs/zerolog/log"
+	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 )
 
+// used if no osmosis api_url is configured
 const API_URL = "https://osmosis-lcd.quickapi.com"
 const defaultContractAddress = "osmo1vy34lpt5zlj797w7zqdta3qfq834kapx88qtgudy7jgljztj567s73ny82"
 
@@ -24,6 +30,11 @@ var (
 	dbPath           string
 	saveRawResponses bool
 	filePath         string
+	network          string
+	fromBlock        int64
+	fromDate         string
+	toDate           string
+	threshold        string
 )
 
 func main() {
@@ -49,7 +60,7 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			db, m := setupMonitor()
 			defer db.Close()
-			m.LoadFromFile(filePath, saveRawResponses)
+			m.LoadFromFile(cmd.Context(), filePath, saveRawResponses)
 		},
 	}
 	loadCmd.Flags().StringVar(&filePath, "file", "", "Load orders from file")
@@ -62,7 +73,7 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			db, m := setupMonitor()
 			defer db.Close()
-			m.LoadMissingOrderFromFile(filePath)
+			m.LoadMissingOrderFromFile(cmd.Context(), filePath)
 		},
 	}
 	saveMissingCmd.Flags().StringVar(&filePath, "file", "", "Persist missing orders from file")
@@ -75,15 +86,158 @@ func main() {
 		Run: func(cmd *cobra.Command, args []string) {
 			db, m := setupMonitor()
 			defer db.Close()
-			m.GetAllOsmosisOrders(defaultContractAddress, API_URL, filePath)
+			m.GetAllOsmosisOrders(cmd.Context(), defaultContractAddress, filePath)
 		},
 	}
 	getOrdersCmd.Flags().StringVar(&filePath, "file", "", "Save orders to file")
 	getOrdersCmd.MarkFlagRequired("file")
 
-	rootCmd.AddCommand(loadCmd, saveMissingCmd, getOrdersCmd)
+	// Backfill EVM txs command
+	backfillEvmTxsCmd := &cobra.Command{
+		Use:   "backfill_evm_txs",
+		Short: "Page through the full etherscan tx history of a network and persist missing txs to db",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+			chain, ok := m.Config().ChainByName(network)
+			if !ok || chain.Kind != monitor.CHAIN_KIND_ETHERSCAN {
+				log.Fatal().Str("network", network).Msg("network is not configured as an etherscan chain")
+			}
+			if err := m.BackfillEtherscanTxHistory(cmd.Context(), chain, fromBlock, saveRawResponses); err != nil {
+				log.Fatal().Err(err).Str("network", network).Msg("failed to backfill txs")
+			}
+		},
+	}
+	backfillEvmTxsCmd.Flags().StringVar(&network, "network", "", "Network name from the config (e.g. ethereum, arbitrum)")
+	backfillEvmTxsCmd.Flags().Int64Var(&fromBlock, "from-block", -1, "Start from block; resumes the previous backfill or starts at block 0 if not set")
+	backfillEvmTxsCmd.MarkFlagRequired("network")
+
+	// Reconcile command
+	reconcileCmd := &cobra.Command{
+		Use:   "reconcile",
+		Short: "Explain balance changes by gas, revenue and transfers and print the report as JSON",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+
+			from, err := time.Parse("2006-01-02", fromDate)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid from date format, expected YYYY-MM-DD")
+			}
+			to := time.Now().UTC()
+			if toDate != "" {
+				to, err = time.Parse("2006-01-02", toDate)
+				if err != nil {
+					log.Fatal().Err(err).Msg("invalid to date format, expected YYYY-MM-DD")
+				}
+				to = to.AddDate(0, 0, 1)
+			}
+			thresholdDec, err := decimal.NewFromString(threshold)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid threshold")
+			}
+
+			report, err := m.GetReconciliationReport(network, from, to, thresholdDec)
+			if err != nil {
+				log.Fatal().Err(err).Msg("failed to get reconciliation report")
+			}
+			for i := range report {
+				if err := report[i].Shift(); err != nil {
+					log.Fatal().Err(err).Msg("failed to convert report amounts")
+				}
+			}
+
+			encoder := json.NewEncoder(os.Stdout)
+			encoder.SetIndent("", "  ")
+			if err := encoder.Encode(report); err != nil {
+				log.Fatal().Err(err).Send()
+			}
+		},
+	}
+	reconcileCmd.Flags().StringVar(&network, "network", "", "Network name from the config; all configured networks if not set")
+	reconcileCmd.Flags().StringVar(&fromDate, "from", "", "Start date (YYYY-MM-DD)")
+	reconcileCmd.Flags().StringVar(&toDate, "to", "", "End date (YYYY-MM-DD, inclusive); defaults to now")
+	reconcileCmd.Flags().StringVar(&threshold, "threshold", "0.01", "Flag residuals above this amount (in token units)")
+	reconcileCmd.MarkFlagRequired("from")
+
+	// Link orders command
+	linkOrdersCmd := &cobra.Command{
+		Use:   "link_orders",
+		Short: "Link filled orders to the solver EVM txs on their source network (used for per-order PnL)",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+
+			since, err := time.Parse("2006-01-02", fromDate)
+			if err != nil {
+				log.Fatal().Err(err).Msg("invalid from date format, expected YYYY-MM-DD")
+			}
+			for _, chain := range m.Config().Chains {
+				if chain.Kind != monitor.CHAIN_KIND_ETHERSCAN || (network != "" && chain.Name != network) {
+					continue
+				}
+				linked, err := m.LinkOrdersToEvmTxs(cmd.Context(), chain, since)
+				if err != nil {
+					log.Fatal().Err(err).Str("network", chain.Name).Msg("failed to link orders")
+				}
+				log.Info().Int("linked", linked).Str("network", chain.Name).Msg("linked orders")
+			}
+		},
+	}
+	linkOrdersCmd.Flags().StringVar(&network, "network", "", "Network name from the config; all etherscan networks if not set")
+	linkOrdersCmd.Flags().StringVar(&fromDate, "from", "", "Link orders filled since date (YYYY-MM-DD)")
+	linkOrdersCmd.MarkFlagRequired("from")
 
-	if err := rootCmd.Execute(); err != nil {
+	// Backfill gaps command
+	backfillGapsCmd := &cobra.Command{
+		Use:   "backfill_gaps",
+		Short: "Compare stored orders against the node tx counts per height range and backfill missing orders",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+			if err := m.RunGapDetection(cmd.Context(), saveRawResponses); err != nil {
+				log.Fatal().Err(err).Msg("failed to backfill order gaps")
+			}
+		},
+	}
+
+	// Backfill order details command
+	backfillOrderDetailsCmd := &cobra.Command{
+		Use:   "backfill_order_details",
+		Short: "Decode stored raw tx responses and fill in the order details (recipient, nonce, destination domain...) of older orders",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+			updated, err := m.BackfillOrderDetails(cmd.Context())
+			if err != nil {
+				log.Fatal().Err(err).Int("updated", updated).Msg("failed to backfill order details")
+			}
+			log.Info().Int("updated", updated).Msg("backfilled order details")
+		},
+	}
+
+	backfillOrderActionsCmd := &cobra.Command{
+		Use:   "backfill_order_actions",
+		Short: "Page back through the contract txs and persist the settlement, timeout and refund messages above a height",
+		Run: func(cmd *cobra.Command, args []string) {
+			db, m := setupMonitor()
+			defer db.Close()
+			saved, err := m.BackfillOrderActions(cmd.Context(), fromBlock)
+			if err != nil {
+				log.Fatal().Err(err).Int("saved", saved).Msg("failed to backfill order actions")
+			}
+			log.Info().Int("saved", saved).Msg("backfilled order actions")
+		},
+	}
+	backfillOrderActionsCmd.Flags().Int64Var(&fromBlock, "from-height", 0, "Store the actions of txs above this osmosis height")
+	backfillOrderActionsCmd.MarkFlagRequired("from-height")
+
+	rootCmd.AddCommand(loadCmd, saveMissingCmd, getOrdersCmd, backfillEvmTxsCmd, reconcileCmd, linkOrdersCmd, backfillGapsCmd, backfillOrderDetailsCmd, backfillOrderActionsCmd)
+
+	// commands stop pending requests on SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	if err := rootCmd.ExecuteContext(ctx); err != nil {
 		os.Exit(1)
 	}
 }
@@ -134,5 +288,9 @@ func setupMonitor() (*sql.DB, *monitor.Monitor) {
 	if err != nil {
 		log.Fatal().Err(err).Send()
 	}
-	return db, monitor.NewMonitor(db, cfg, &log.Logger, API_URL)
+	apiUrls := cfg.Osmosis.LcdUrls()
+	if len(apiUrls) == 0 {
+		apiUrls = []string{API_URL}
+	}
+	return db, monitor.NewMonitor(db, cfg, &log.Logger, apiUrls)
 }
@@ -23,7 +23,7 @@ import (
 const defaultContractAddress = "osmo1vy34lpt5zlj797w7zqdta3qfq834kapx88qtgudy7jgljztj567s73ny82"
 
 func main() {
-	interval := flag.Int("interval", 1, "Polling interval in minutes")
+	interval := flag.Int("interval", 1, "Default polling interval in minutes for jobs without a schedule in the config")
 	contractAddress := flag.String("contract-address", defaultContractAddress, "Osmosis skip-go-fast contract address to monitor.")
 	logLevel := flag.String("log-level", "INFO", "Set the logging level")
 	logFormat := flag.String("log-format", "json", "Set the log output format")
@@ -32,10 +32,11 @@ func main() {
 	dbPath := flag.String("db", "tx_data.db", "Path to the db file")
 	loadFromFile := flag.String("load-from-file", "", "Load orders from file. If provided, all other arguments are ignored.")
 	serverAddr := flag.String("server-addr", ":8080", "Server address to listen on")
-	skipInitialization := flag.Bool("skip-init", false, "Skip fetching state and txs on startup. Cron job will run on interval.")
-	serverOnly := flag.Bool("server-only", false, "Only run the server, don't fetch txs and dont start the cron job.")
-	getBlocks := flag.Bool("get-blocks", false, "Get blocks from the chain and save them to the db.")
-	getBlocksInterval := flag.Int("get-blocks-interval", 5, "Interval in seconds to get blocks from the chain and save them to the db.")
+	skipInitialization := flag.Bool("skip-init", false, "Skip fetching state and txs on startup. Jobs will run on their schedules.")
+	serverOnly := flag.Bool("server-only", false, "Only run the server and the price job, don't fetch txs.")
+	getBlocks := flag.Bool("get-blocks", false, "Backfill block times of orders stored without one and exit.")
+	getBlocksInterval := flag.Int("get-blocks-interval", 5, "Interval in seconds between block requests per endpoint when backfilling block times.")
+	shutdownTimeout := flag.Duration("shutdown-timeout", 30*time.Second, "Max time to wait for running workers on shutdown.")
 	flag.Parse()
 
 	// Set up logging
@@ -84,16 +85,20 @@ func main() {
 	}
 	defer db.Close()
 
-	m := monitor.NewMonitor(db, cfg, &log.Logger, cfg.Osmosis.ApiUrl)
+	m := monitor.NewMonitor(db, cfg, &log.Logger, cfg.Osmosis.LcdUrls())
+
+	// cancelled on SIGINT/SIGTERM -- stops pending requests, retries and db writes
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
 
 	// this can be done via subcommands
 	if *loadFromFile != "" {
-		m.LoadFromFile(*loadFromFile, *saveRawResponses)
+		m.LoadFromFile(ctx, *loadFromFile, *saveRawResponses)
 		return
 	}
 
 	if *getBlocks != false {
-		err := m.FetchAndSaveBlocktimes(*getBlocksInterval)
+		err := m.FetchAndSaveBlocktimes(ctx, *getBlocksInterval)
 		if err != nil {
 			log.Error().Err(err).Msg("failed storing osmosis block times")
 		}
@@ -105,10 +110,8 @@ func main() {
 		"interval", strconv.Itoa(*interval)}).Msg("monitor started")
 
 	// Get coingecko prices at startup (required for eth tx calculation routines)
-	m.GetCoingeckoPrices()
+	m.GetCoingeckoPrices(ctx)
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
 	server := monitor.NewServer(m)
 
 	// Start server in a goroutine with context after initial state and txs are fetched
@@ -119,42 +122,37 @@ func main() {
 		}
 	}()
 
+	jobs, err := m.Jobs(fmt.Sprintf("@every %dm", *interval), *saveRawResponses, *serverOnly)
+	if err != nil {
+		log.Fatal().Err(err).Msg("invalid job schedule")
+	}
+
 	var wg sync.WaitGroup
 	if !*skipInitialization {
-		// there's no do while loop in go, so we just run the orders once on startup
+		// there's no do while loop in go, so we just run the jobs once on startup
 		log.Logger.Info().Msg("initializing state and fetching txs")
-		m.RunAll(&wg, *saveRawResponses)
+		m.RunAll(ctx, &wg, *saveRawResponses)
 		wg.Wait()
-		log.Logger.Info().Int("interval_minutes", *interval).Msg("initial state and txs fetched -- running cron")
+		log.Logger.Info().Int("default_interval_minutes", *interval).Msg("initial state and txs fetched -- running scheduler")
 	}
 
-	ticker := time.NewTicker(time.Duration(*interval) * time.Minute)
-	defer ticker.Stop()
-
-	tickerHourly := time.NewTicker(time.Hour)
-	defer tickerHourly.Stop()
-
-	sigs := make(chan os.Signal, 1)
-	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
-
-	for {
-		select {
-		case <-tickerHourly.C:
-			m.GetCoingeckoPrices()
-		case <-ticker.C:
-			if !*serverOnly {
-				log.Logger.Debug().Msg("interval tick -- fetching txs")
-				m.RunAll(&wg, *saveRawResponses)
-			}
-		case <-sigs:
-			log.Info().Msg("shutdown signal received")
-			cancel() // Cancel the context
-			log.Info().Msg("waiting for ongoing operations to complete...")
-			wg.Wait() // Wait for any running goroutines to finish
-			return
-		case <-ctx.Done():
-			log.Info().Msg("context cancelled")
-			return
-		}
+	m.RunScheduler(ctx, &wg, jobs)
+	if cfg.Osmosis.WebsocketUrl != "" && !*serverOnly {
+		m.RunOrdersSubscription(ctx, &wg, *saveRawResponses)
+	}
+
+	<-ctx.Done()
+	log.Info().Msg("shutdown signal received")
+	log.Info().Dur("timeout", *shutdownTimeout).Msg("waiting for ongoing operations to complete...")
+	done := make(chan struct{})
+	go func() {
+		wg.Wait() // Wait for any running goroutines to finish
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Info().Msg("all operations completed")
+	case <-time.After(*shutdownTimeout):
+		log.Warn().Msg("shutdown timeout exceeded -- exiting with operations still running")
 	}
 }
@@ -1,7 +1,9 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -18,6 +20,9 @@ const (
 	AVALANCHE_CHAIN_ID = 43114
 )
 
+// pause between the balance queries, replaced in tests
+var avaxBalanceSleep = 1 * time.Second
+
 type AvaxTxsResponse struct {
 	// not exactly the same as the Etherscan response but works for gas calculations
 	Items []AvaxEVMTxDetails `json:"items"`
@@ -99,17 +104,43 @@ type AvaxErc20 struct {
 	UpdatedAtBlock  int64  `json:"updatedAtBlock"`
 }
 
-func (m *Monitor) RunAvalancheBalances() {
-	apiUrl := m.cfg.Avalanche.ApiUrl
-	address := m.cfg.Avalanche.Address
+type glacierAdapter struct {
+	m     *Monitor
+	chain ChainConfig
+}
+
+func newGlacierAdapter(m *Monitor, chain ChainConfig) ChainAdapter {
+	return &glacierAdapter{m: m, chain: chain}
+}
+
+func (a *glacierAdapter) Network() string {
+	return a.chain.Name
+}
+
+func (a *glacierAdapter) RunBalances(ctx context.Context) error {
+	return a.m.RunAvalancheBalances(ctx, a.chain)
+}
+
+func (a *glacierAdapter) RunTxHistory(ctx context.Context, saveRawResponses bool) error {
+	// sleep to avoid rate limiting after the balance queries
+	if err := sleepCtx(ctx, 60*time.Second); err != nil {
+		return err
+	}
+	return a.m.RunAvalancheTxHistory(ctx, a.chain, saveRawResponses)
+}
+
+// AvaxUsdcNotFoundErr is returned if the address holds no USDC
+var AvaxUsdcNotFoundErr = errors.New("USDC balance not found")
+
+func (m *Monitor) RunAvalancheBalances(ctx context.Context, chain ChainConfig) error {
+	apiUrl := chain.ApiUrl
+	address := chain.Address
+	network := chain.Name
 	useTs := time.Now()
 
-	avaxWei, err := m.getAvaxGasBalance(apiUrl, address)
+	avaxWei, err := m.getAvaxGasBalance(ctx, apiUrl, address)
 	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", AVALANCHE_NETWORK).
-			Msg("failed to get AVAX balance")
+		return fmt.Errorf("failed to get %s balance: %w", chain.NativeToken, err)
 	}
 
 	if avaxWei != "" {
@@ -117,75 +148,79 @@ func (m *Monitor) RunAvalancheBalances() {
 			Timestamp: useTs.Unix(),
 			Balance:   avaxWei,
 			Exponent:  18,
-			Token:     "AVAX",
+			Token:     chain.NativeToken,
 			Address:   address,
-			Network:   AVALANCHE_NETWORK,
+			Network:   network,
 		}
 
-		m.logger.Debug().Str("network", AVALANCHE_NETWORK).Msg("inserting AVAX balance")
-		if err := m.InsertBalance(avaxBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", AVALANCHE_NETWORK).Msg("failed to insert balance")
+		m.logger.Debug().Str("network", network).Msgf("inserting %s balance", chain.NativeToken)
+		if err := m.InsertBalance(ctx, avaxBalance); err != nil {
+			return fmt.Errorf("failed to insert %s balance: %w", chain.NativeToken, err)
 		}
 
 		if avaxDecimal, err := decimal.NewFromString(avaxWei); err == nil {
 			m.logger.Info().
-				Str("AVAX", avaxDecimal.Shift(-18).String()).
-				Str("network", AVALANCHE_NETWORK).
+				Str(chain.NativeToken, avaxDecimal.Shift(-18).String()).
+				Str("network", network).
 				Str("datetime", useTs.Format(time.RFC3339)).
 				Msg("current balance")
 		}
 	}
 
 	// sleep to avoid rate limiting -> 2 requests per second for free tier
-	time.Sleep(1 * time.Second)
-	usdc, err := m.getAvaxUSDCBalance(apiUrl, address)
-	if err != nil {
+	if err := sleepCtx(ctx, avaxBalanceSleep); err != nil {
+		return err
+	}
+	usdc, err := m.getAvaxUSDCBalance(ctx, apiUrl, address, chain.UsdcAddress)
+	if errors.Is(err, AvaxUsdcNotFoundErr) {
 		m.logger.Debug().
 			Str("address", address).
-			Str("network", AVALANCHE_NETWORK).
+			Str("network", network).
 			Msg("no USDC balance found")
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get USDC balance: %w", err)
 	}
 
-	if usdc != "" {
-		usdcBalance := DbBalance{
-			Timestamp: useTs.Unix(),
-			Balance:   usdc,
-			Exponent:  6,
-			Token:     "USDC",
-			Address:   address,
-			Network:   AVALANCHE_NETWORK,
-		}
-		if err := m.InsertBalance(usdcBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", AVALANCHE_NETWORK).Msg("failed to insert balance")
-		}
-		if usdcDecimal, err := decimal.NewFromString(usdc); err == nil {
-			m.logger.Info().
-				Str("USDC", usdcDecimal.Shift(-6).String()).
-				Str("network", AVALANCHE_NETWORK).
-				Str("datetime", useTs.Format(time.RFC3339)).
-				Msg("current balance")
-		}
+	usdcBalance := DbBalance{
+		Timestamp: useTs.Unix(),
+		Balance:   usdc,
+		Exponent:  6,
+		Token:     "USDC",
+		Address:   address,
+		Network:   network,
+	}
+	if err := m.InsertBalance(ctx, usdcBalance); err != nil {
+		return fmt.Errorf("failed to insert USDC balance: %w", err)
 	}
+	if usdcDecimal, err := decimal.NewFromString(usdc); err == nil {
+		m.logger.Info().
+			Str("USDC", usdcDecimal.Shift(-6).String()).
+			Str("network", network).
+			Str("datetime", useTs.Format(time.RFC3339)).
+			Msg("current balance")
+	}
+	return nil
 }
 
-func (m *Monitor) RunAvalancheTxHistory(saveRawResponses bool) {
-	apiUrl := m.cfg.Avalanche.ApiUrl
-	address := m.cfg.Avalanche.Address
+func (m *Monitor) RunAvalancheTxHistory(ctx context.Context, chain ChainConfig, saveRawResponses bool) error {
+	apiUrl := chain.ApiUrl
+	address := chain.Address
+	network := chain.Name
 
-	txs, err := m.getAvaxTxs(apiUrl, address)
+	txs, err := m.getAvaxTxs(ctx, apiUrl, address)
 	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get avalanche txs")
-		return
+		return fmt.Errorf("failed to get avalanche txs: %w", err)
 	}
-	latestHeight, err := m.GetLatestEthHeight(AVALANCHE_NETWORK)
+	latestHeight, err := m.GetLatestEthHeight(network)
 	if err != nil {
 		m.logger.Warn().Msg("failed to get latest avalanche height -- starting from 0")
 	}
 
-	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(COINGECKO_AVALANCHE_ID)
+	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(chain.CoingeckoId)
 	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get latest USD token price")
-		return
+		return fmt.Errorf("failed to get latest USD token price: %w", err)
 	}
 
 	inserted := 0
@@ -208,18 +243,18 @@ func (m *Monitor) RunAvalancheTxHistory(saveRawResponses bool) {
 			m.logger.Error().Err(err).
 				Str("tx_hash", tx.Hash).
 				Str("block_number", tx.BlockNumber).
-				Str("network", AVALANCHE_NETWORK).
+				Str("network", network).
 				Msg("failed to calculate gas used USD")
 		}
 
 		totalGasUsedUsd = totalGasUsedUsd.Add(gasUsedUsd)
 		tx.GasUsedUsd = gasUsedUsd.String()
-		tx.Network = AVALANCHE_NETWORK
-		if err := m.InsertEthTxResponse(tx, AVALANCHE_NETWORK, saveRawResponses); err != nil {
+		tx.Network = network
+		if err := m.InsertEthTxResponse(ctx, tx, network, saveRawResponses); err != nil {
 			m.logger.Error().Err(err).
 				Str("tx_hash", tx.Hash).
 				Str("block_number", tx.BlockNumber).
-				Str("network", AVALANCHE_NETWORK).
+				Str("network", network).
 				Msg("failed to insert avalanche tx")
 			failed++
 			continue
@@ -234,9 +269,10 @@ func (m *Monitor) RunAvalancheTxHistory(saveRawResponses bool) {
 		Str("total_gas_used_avax", decimal.NewFromBigInt(totalGasUsed, -18).String()).
 		Str("total_gas_used_usd", totalGasUsedUsd.String()).
 		Msg("finished processing AVALANCHE txs history")
+	return nil
 }
 
-func (m *Monitor) getAvaxTxs(apiUrl string, address string) ([]EthTxDetails, error) {
+func (m *Monitor) getAvaxTxs(ctx context.Context, apiUrl string, address string) ([]EthTxDetails, error) {
 	headers := map[string]string{"Accept": "application/json"}
 
 	params := url.Values{}
@@ -245,7 +281,7 @@ func (m *Monitor) getAvaxTxs(apiUrl string, address string) ([]EthTxDetails, err
 
 	path := fmt.Sprintf("%s/address/%s/transactions", apiUrl, address)
 	url := fmt.Sprintf("%s?%s", path, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
@@ -253,9 +289,7 @@ func (m *Monitor) getAvaxTxs(apiUrl string, address string) ([]EthTxDetails, err
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -279,7 +313,7 @@ func (m *Monitor) getAvaxTxs(apiUrl string, address string) ([]EthTxDetails, err
 	return txs, nil
 }
 
-func (m *Monitor) getAvaxGasBalance(apiUrl string, address string) (string, error) {
+func (m *Monitor) getAvaxGasBalance(ctx context.Context, apiUrl string, address string) (string, error) {
 	headers := map[string]string{"Accept": "application/json"}
 
 	params := url.Values{}
@@ -287,7 +321,7 @@ func (m *Monitor) getAvaxGasBalance(apiUrl string, address string) (string, erro
 
 	path := fmt.Sprintf("%s/addresses/%s", apiUrl, address)
 	url := fmt.Sprintf("%s?%s", path, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -295,14 +329,16 @@ func (m *Monitor) getAvaxGasBalance(apiUrl string, address string) (string, erro
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s, code: %d", HttpCodeCheck(resp.StatusCode), resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
@@ -316,8 +352,7 @@ func (m *Monitor) getAvaxGasBalance(apiUrl string, address string) (string, erro
 	return data.Balance, nil
 }
 
-func (m *Monitor) getAvaxUSDCBalance(apiUrl string, address string) (string, error) {
-	usdcAddress := m.cfg.Avalanche.UsdcAddress
+func (m *Monitor) getAvaxUSDCBalance(ctx context.Context, apiUrl string, address string, usdcAddress string) (string, error) {
 	headers := map[string]string{"Accept": "application/json"}
 
 	params := url.Values{}
@@ -325,7 +360,7 @@ func (m *Monitor) getAvaxUSDCBalance(apiUrl string, address string) (string, err
 
 	path := fmt.Sprintf("%s/address/%s/erc20-holdings", apiUrl, address)
 	url := fmt.Sprintf("%s?%s", path, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return "", err
 	}
@@ -333,14 +368,16 @@ func (m *Monitor) getAvaxUSDCBalance(apiUrl string, address string) (string, err
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("%s, code: %d", HttpCodeCheck(resp.StatusCode), resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
 		return "", err
@@ -357,5 +394,5 @@ func (m *Monitor) getAvaxUSDCBalance(apiUrl string, address string) (string, err
 		}
 	}
 
-	return "", fmt.Errorf("USDC balance not found")
+	return "", AvaxUsdcNotFoundErr
 }
@@ -1,11 +1,13 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"sync"
 	"time"
 )
@@ -18,7 +20,8 @@ type BlockTime struct {
 
 const BLOCK_QUERY string = "/cosmos/base/tendermint/v1beta1/blocks"
 
-var urls = []string{
+// public LCDs used to backfill block times if block_time_urls is not configured
+var defaultBlockTimeUrls = []string{
 	"https://osmosis-api.polkachu.com",          // doesn't have old block heights
 	"https://rest.lavenderfive.com:443/osmosis", // doesn't have old block heights
 	"https://osmosis-lcd.quickapi.com",
@@ -28,13 +31,40 @@ var urls = []string{
 	"https://rest.cosmos.directory/osmosis",
 }
 
+// a height is given up after failing on this many requests, it is retried on the next backfill
+const BLOCK_TIME_MAX_ATTEMPTS = 5
+
+// how long a worker waits after leaving a height it failed to the other endpoints
+const BLOCK_TIME_REQUEUE_WAIT = 100 * time.Millisecond
+
 var RateLimitErr = errors.New("rate limit error")
 var NotAvailableError = errors.New("server not available error")
 
-func (m *Monitor) FetchAndSaveBlocktimes(intervalSeconds int) error {
+func newBlockTime(height int64, t time.Time) *BlockTime {
+	return &BlockTime{
+		Height:    height,
+		Datetime:  t.UTC().Format("2006-01-02 15:04:05"),
+		Timestamp: t.Unix(),
+	}
+}
+
+// blockTimeUrls returns the configured block time endpoints or the default public LCDs
+func (m *Monitor) blockTimeUrls() []string {
+	if len(m.cfg.Osmosis.BlockTimeUrls) > 0 {
+		return m.cfg.Osmosis.BlockTimeUrls
+	}
+	return defaultBlockTimeUrls
+}
+
+// FetchAndSaveBlocktimes fetches the block times of stored orders that don't have one.
+// Block times are stored when orders are ingested, this backfills orders stored before that.
+// Every endpoint of the pool has its own worker that waits intervalSeconds between requests. Failed heights are put
+// back in the queue and left to the endpoints that didn't fail them yet while any of those is available. Workers of
+// endpoints with an open circuit breaker stop taking heights until the cooldown passed.
+func (m *Monitor) FetchAndSaveBlocktimes(ctx context.Context, intervalSeconds int) error {
 	m.logger.Info().Msg("fetching blocktimes")
 	// fetch heights that we don't already have stored
-	rows, err := m.db.Query(`
+	rows, err := m.db.QueryContext(ctx, `
 	SELECT DISTINCT t.height 
 	FROM tx_data t
 	LEFT JOIN osmo_block_times b ON t.height = b.height
@@ -52,7 +82,14 @@ func (m *Monitor) FetchAndSaveBlocktimes(intervalSeconds int) error {
 		}
 		heights = append(heights, h)
 	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
 	m.logger.Info().Int64("count", int64(len(heights))).Msg("new blocks in database")
+	if len(heights) == 0 {
+		return nil
+	}
 
 	// process fetched block times
 	var blocktimes = make(chan *BlockTime, 100)
@@ -62,45 +99,112 @@ func (m *Monitor) FetchAndSaveBlocktimes(intervalSeconds int) error {
 		defer processWg.Done()
 		for b := range blocktimes {
 			bt := b
-			m.storeBlockTime(bt)
+			if err := m.storeBlockTime(ctx, bt); err != nil {
+				m.logger.Error().Err(err).Int64("height", bt.Height).Msg("failed to store block time")
+			}
 		}
 	}()
 
+	// failed heights are put back, so the queue never holds more than all heights
 	heightsChan := make(chan int64, len(heights))
 	for _, h := range heights {
 		heightsChan <- h
 	}
-	close(heightsChan)
 
-	var fetchWg sync.WaitGroup
+	// done is closed once every height is stored or given up
+	var mu sync.Mutex
+	pending := len(heights)
+	attempts := map[int64]int{}
+	failedUrls := map[int64][]string{}
+	done := make(chan struct{})
+	finish := func() {
+		mu.Lock()
+		defer mu.Unlock()
+		pending--
+		if pending == 0 {
+			close(done)
+		}
+	}
+	retry := func(h int64, apiUrl string, err error) {
+		mu.Lock()
+		attempts[h]++
+		failedUrls[h] = append(failedUrls[h], apiUrl)
+		giveUp := attempts[h] >= BLOCK_TIME_MAX_ATTEMPTS
+		mu.Unlock()
+		if giveUp {
+			m.logger.Error().Int64("height", h).Str("URL", apiUrl).Err(err).Msg("error getting block timestamp - giving up")
+			finish()
+			return
+		}
+		m.logger.Warn().Int64("height", h).Str("URL", apiUrl).Err(err).Msg("error getting block timestamp - requeueing")
+		heightsChan <- h
+	}
+	wait := func(d time.Duration) bool {
+		select {
+		case <-time.After(d):
+			return true
+		case <-done:
+			return false
+		case <-ctx.Done():
+			return false
+		}
+	}
 
-	for _, url := range urls {
+	pool := NewEndpointPool(m.blockTimeUrls(), DefaultCircuitBreakerConfig)
+	var fetchWg sync.WaitGroup
+	for _, url := range pool.Urls() {
 		fetchWg.Add(1)
 		go func(wg *sync.WaitGroup, apiUrl string) {
 			defer wg.Done()
-			for h := range heightsChan {
-				time.Sleep(time.Duration(intervalSeconds) * time.Second)
-				b, err := m.getBlockTimestamp(apiUrl, h)
+			for {
+				var h int64
+				select {
+				case h = <-heightsChan:
+				case <-done:
+					return
+				case <-ctx.Done():
+					return
+				}
 
-				if err != nil && errors.Is(err, RateLimitErr) {
-					m.logger.Warn().Int64("height", h).Str("URL", url).Msg("request was rate limited - sleeping for a minute")
-					time.Sleep(1 * time.Minute)
+				mu.Lock()
+				failed := failedUrls[h]
+				mu.Unlock()
+				if slices.Contains(failed, apiUrl) && pool.Available(failed...) {
+					// leave the height to an endpoint that didn't fail it
+					heightsChan <- h
+					if !wait(BLOCK_TIME_REQUEUE_WAIT) {
+						return
+					}
 					continue
 				}
 
-				// stop using this endpoint
-				if err != nil && errors.Is(err, NotAvailableError) {
-					m.logger.Error().Int64("height", h).Str("URL", url).Msg("server not available - stopping routine")
-					break
+				if ok, retryIn := pool.Allow(apiUrl); !ok {
+					// leave the height to the other endpoints until the breaker lets requests through again
+					heightsChan <- h
+					if !wait(retryIn) {
+						return
+					}
+					continue
 				}
 
+				start := time.Now()
+				b, err := m.getBlockTimestamp(ctx, apiUrl, h)
+				// reported before returning on shutdown so a trial request of the breaker doesn't stay pending
+				pool.Report(apiUrl, time.Since(start), err)
+				if ctx.Err() != nil {
+					return
+				}
 				if err != nil {
-					m.logger.Error().Int64("height", h).Str("URL", url).Err(err).Msg("error getting block timestamp - skipping")
-					break
+					retry(h, apiUrl, err)
+				} else {
+					m.logger.Info().Int64("height", h).Str("URL", apiUrl).Msg("fetched block time")
+					blocktimes <- b
+					finish()
 				}
 
-				m.logger.Info().Int64("height", h).Str("URL", url).Msg("fetched block time")
-				blocktimes <- b
+				if !wait(time.Duration(intervalSeconds) * time.Second) {
+					return
+				}
 			}
 		}(&fetchWg, url)
 	}
@@ -110,22 +214,29 @@ func (m *Monitor) FetchAndSaveBlocktimes(intervalSeconds int) error {
 	fetchWg.Wait()
 	close(blocktimes)
 	processWg.Wait()
+
+	for _, e := range pool.Status() {
+		m.logger.Info().
+			Str("URL", e.Url).
+			Str("state", e.State).
+			Int("requests", e.Requests).
+			Int("failures", e.Failures).
+			Float64("avg_latency_ms", e.AvgLatencyMs).
+			Float64("error_rate", e.ErrorRate).
+			Msg("block time endpoint")
+	}
 	m.logger.Info().Msg("finished inserting block heights")
 
-	return nil
+	return ctx.Err()
 }
 
-func (m *Monitor) storeBlockTime(b *BlockTime) error {
+func (m *Monitor) storeBlockTime(ctx context.Context, b *BlockTime) error {
 	m.logger.Debug().Int64("height", b.Height).
 		Int64("timestamp", b.Timestamp).
 		Str("datetime", b.Datetime).
 		Msg("inserting block time")
-	_, err := m.db.Exec(`
-	INSERT INTO osmo_block_times (height, timestamp, datetime)
-	VALUES (?, ?, ?)
-`, b.Height, b.Timestamp, b.Datetime)
-	if err != nil {
-		return fmt.Errorf("failed to insert block time: %w", err)
+	if err := insertBlockTime(ctx, m.db, b); err != nil {
+		return err
 	}
 
 	m.logger.Debug().Int64("height", b.Height).
@@ -135,6 +246,19 @@ func (m *Monitor) storeBlockTime(b *BlockTime) error {
 	return nil
 }
 
+// insertBlockTime stores the block time unless the height is already stored
+func insertBlockTime(ctx context.Context, exec dbExecer, b *BlockTime) error {
+	_, err := exec.ExecContext(ctx, `
+	INSERT INTO osmo_block_times (height, timestamp, datetime)
+	SELECT ?, ?, ?
+	WHERE NOT EXISTS (SELECT 1 FROM osmo_block_times WHERE height = ?)
+`, b.Height, b.Timestamp, b.Datetime, b.Height)
+	if err != nil {
+		return fmt.Errorf("failed to insert block time: %w", err)
+	}
+	return nil
+}
+
 // don't want to mess widh cosmos/comet encoders again...
 type ShortBlockResp struct {
 	Block struct {
@@ -150,15 +274,13 @@ type ShortBlockResp struct {
 	} `json:"block"`
 }
 
-func (m *Monitor) getBlockTimestamp(apiUrl string, height int64) (*BlockTime, error) {
+func (m *Monitor) getBlockTimestamp(ctx context.Context, apiUrl string, height int64) (*BlockTime, error) {
 	url := fmt.Sprintf("%s/%s/%d", apiUrl, BLOCK_QUERY, height)
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
 		return nil, err
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -180,19 +302,29 @@ func (m *Monitor) getBlockTimestamp(apiUrl string, height int64) (*BlockTime, er
 	if err != nil {
 		return nil, err
 	}
+	return m.decodeBlockTime(height, body)
+}
+
+// getOsmosisBlockTime fetches the block time of the height from the osmosis LCDs of the monitor
+func (m *Monitor) getOsmosisBlockTime(ctx context.Context, height int64) (*BlockTime, error) {
+	body, err := m.osmosis.Get(ctx, fmt.Sprintf("%s/%d", BLOCK_QUERY, height))
+	if err != nil {
+		return nil, err
+	}
+	return m.decodeBlockTime(height, body)
+}
 
+func (m *Monitor) decodeBlockTime(height int64, body []byte) (*BlockTime, error) {
 	var data ShortBlockResp
 	if err := json.Unmarshal(body, &data); err != nil {
 		m.logger.Debug().Str("body", string(body)).Msg("error unmarshalling block response")
 		return nil, err
 	}
 
-	m.logger.Debug().Int("block_height", int(height)).Msg("fetched osmosis block")
-	b := &BlockTime{
-		Height:    height,
-		Datetime:  data.Block.Header.Time.UTC().Format("2006-01-02 15:04:05"),
-		Timestamp: data.Block.Header.Time.Unix(),
+	if data.Block.Header.Time.IsZero() {
+		return nil, fmt.Errorf("block %d without time", height)
 	}
-	return b, nil
 
+	m.logger.Debug().Int("block_height", int(height)).Msg("fetched osmosis block")
+	return newBlockTime(height, data.Block.Header.Time), nil
 }
@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFetchAndSaveBlocktimes(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	heights := []int64{100, 101, 102, 103, 104, 105}
+	for i, h := range heights {
+		require.NoError(t, m.InsertOrderFilled(ctx, DbOrderFilled{TxHash: "TX" + strconv.Itoa(i), Height: h}))
+	}
+	// already stored heights are not fetched
+	require.NoError(t, m.storeBlockTime(ctx, newBlockTime(100, time.Unix(1_000, 0))))
+
+	var failedRequests atomic.Int32
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		failedRequests.Add(1)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		parts := strings.Split(r.URL.Path, "/")
+		height, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+		require.NoError(t, err)
+		var resp ShortBlockResp
+		resp.Block.Header.Time = time.Unix(1_000_000+height, 0)
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer up.Close()
+	m.cfg.Osmosis.BlockTimeUrls = []string{down.URL, up.URL}
+
+	require.NoError(t, m.FetchAndSaveBlocktimes(ctx, 0))
+
+	// heights failed on the unavailable endpoint are fetched from the other one
+	for _, h := range heights[1:] {
+		var timestamp int64
+		require.NoError(t, m.db.QueryRow(`SELECT timestamp FROM osmo_block_times WHERE height = ?`, h).Scan(&timestamp))
+		assert.Equal(t, 1_000_000+h, timestamp)
+	}
+	// the breaker of the unavailable endpoint opened after the failure threshold
+	assert.LessOrEqual(t, int(failedRequests.Load()), DefaultCircuitBreakerConfig.FailureThreshold)
+}
+
+func TestFetchAndSaveBlocktimesRequeuesToOtherEndpoints(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	heights := []int64{100, 101, 102, 103, 104, 105}
+	for i, h := range heights {
+		require.NoError(t, m.InsertOrderFilled(ctx, DbOrderFilled{TxHash: "TX" + strconv.Itoa(i), Height: h}))
+	}
+
+	var flakyRequests atomic.Int32
+	node := func(failing int64) http.HandlerFunc {
+		return func(w http.ResponseWriter, r *http.Request) {
+			parts := strings.Split(r.URL.Path, "/")
+			height, err := strconv.ParseInt(parts[len(parts)-1], 10, 64)
+			require.NoError(t, err)
+			if height == failing {
+				flakyRequests.Add(1)
+				w.WriteHeader(http.StatusInternalServerError)
+				return
+			}
+			var resp ShortBlockResp
+			resp.Block.Header.Time = time.Unix(1_000_000+height, 0)
+			json.NewEncoder(w).Encode(resp)
+		}
+	}
+	// the first endpoint is missing a block the second one has
+	flaky := httptest.NewServer(node(102))
+	defer flaky.Close()
+	up := httptest.NewServer(node(0))
+	defer up.Close()
+	m.cfg.Osmosis.BlockTimeUrls = []string{flaky.URL, up.URL}
+
+	require.NoError(t, m.FetchAndSaveBlocktimes(ctx, 0))
+
+	var count int
+	require.NoError(t, m.db.QueryRow(`SELECT COUNT(*) FROM osmo_block_times`).Scan(&count))
+	assert.Equal(t, len(heights), count)
+	// the failed height is not sent to the same endpoint again
+	assert.LessOrEqual(t, int(flakyRequests.Load()), 1)
+}
@@ -0,0 +1,170 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+)
+
+// chain kinds that can be used in [[chains]] config blocks
+const (
+	CHAIN_KIND_ETHERSCAN         = "etherscan"
+	CHAIN_KIND_AVALANCHE_GLACIER = "avalanche-glacier"
+	CHAIN_KIND_COSMOS_LCD        = "cosmos-lcd"
+)
+
+// ChainConfig describes a single network that the monitor tracks.
+// Example:
+//
+//	[[chains]]
+//	name = "polygon"
+//	kind = "etherscan"
+//	chain_id = 137
+//	native_token = "POL"
+//	coingecko_id = "polygon-ecosystem-token"
+//	key = "<api key>"
+//	api_url = "https://api.etherscan.io/v2/api"
+//	usdc_address = "0x3c499c542cEF5E3811e1192ce70d8cC03d5c3359"
+//	address = "<solver account address eth 0x format>"
+type ChainConfig struct {
+	ChainEntry
+	Name string `json:"name,omitempty" yaml:"name,omitempty" toml:"name,omitempty"`
+	Kind string `json:"kind,omitempty" yaml:"kind,omitempty" toml:"kind,omitempty"`
+	// defaults to the chain id from NetworkToChainId, required for etherscan chains not listed there
+	ChainId int `json:"chain_id,omitempty" yaml:"chain_id,omitempty" toml:"chain_id,omitempty"`
+	// gas token symbol for EVM chains (ETH, AVAX, POL...) or base denom for cosmos chains (uosmo)
+	NativeToken string `json:"native_token,omitempty" yaml:"native_token,omitempty" toml:"native_token,omitempty"`
+	// coingecko id of the native token - used for gas USD calculations
+	CoingeckoId string `json:"coingecko_id,omitempty" yaml:"coingecko_id,omitempty" toml:"coingecko_id,omitempty"`
+}
+
+// ChainAdapter fetches balances and tx history for a single configured network.
+type ChainAdapter interface {
+	Network() string
+	// RunBalances returns an error if the balances could not be fetched or stored -- the run is retried with backoff
+	RunBalances(ctx context.Context) error
+	// RunTxHistory returns an error if any of the tx history steps failed -- the run is retried with backoff
+	RunTxHistory(ctx context.Context, saveRawResponses bool) error
+}
+
+type ChainAdapterFactory func(m *Monitor, chain ChainConfig) ChainAdapter
+
+type chainKindDefaults struct {
+	nativeToken string
+	coingeckoId string
+}
+
+var chainAdapterRegistry = map[string]ChainAdapterFactory{}
+var chainDefaults = map[string]chainKindDefaults{}
+
+// RegisterChainAdapter makes a chain kind available to [[chains]] config blocks.
+func RegisterChainAdapter(kind, nativeToken, coingeckoId string, factory ChainAdapterFactory) {
+	chainAdapterRegistry[kind] = factory
+	chainDefaults[kind] = chainKindDefaults{
+		nativeToken: nativeToken,
+		coingeckoId: coingeckoId,
+	}
+}
+
+func init() {
+	RegisterChainAdapter(CHAIN_KIND_ETHERSCAN, "ETH", COINGECKO_ETHEREUM_ID, newEtherscanAdapter)
+	RegisterChainAdapter(CHAIN_KIND_AVALANCHE_GLACIER, "AVAX", COINGECKO_AVALANCHE_ID, newGlacierAdapter)
+	RegisterChainAdapter(CHAIN_KIND_COSMOS_LCD, "uosmo", COINGECKO_OSMOSIS_ID, newCosmosAdapter)
+}
+
+// validate checks the chain config and fills in the defaults for the chain kind
+func (c *ChainConfig) validate() error {
+	if c.Name == "" {
+		return fmt.Errorf("chain name is required")
+	}
+	defaults, ok := chainDefaults[c.Kind]
+	if !ok {
+		return fmt.Errorf("unknown chain kind %q for chain %s", c.Kind, c.Name)
+	}
+	if c.ChainId == 0 {
+		if id, err := strconv.Atoi(NetworkToChainId[c.Name]); err == nil {
+			c.ChainId = id
+		}
+	}
+	if c.Kind == CHAIN_KIND_ETHERSCAN && c.ChainId == 0 {
+		// etherscan v2 selects the network by chain id
+		return fmt.Errorf("chain_id is required for etherscan chain %s", c.Name)
+	}
+	if c.NativeToken == "" {
+		c.NativeToken = defaults.nativeToken
+	}
+	if c.CoingeckoId == "" {
+		c.CoingeckoId = defaults.coingeckoId
+	}
+	return nil
+}
+
+// legacyChains converts the per-network config blocks ([ethereum], [arbitrum]...) to chain configs.
+// Blocks without an api_url are treated as not configured.
+func (cfg *Config) legacyChains() []ChainConfig {
+	legacy := []ChainConfig{
+		{ChainEntry: cfg.Arbitrum, Name: ARBITRUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN},
+		{ChainEntry: cfg.Ethereum, Name: ETHEREUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN},
+		{ChainEntry: cfg.Base, Name: BASE_NETWORK, Kind: CHAIN_KIND_ETHERSCAN},
+		{ChainEntry: cfg.Avalanche, Name: AVALANCHE_NETWORK, Kind: CHAIN_KIND_AVALANCHE_GLACIER},
+		{ChainEntry: cfg.Osmosis.ChainEntry, Name: OSMOSIS_NETWORK, Kind: CHAIN_KIND_COSMOS_LCD},
+	}
+
+	chains := []ChainConfig{}
+	for _, c := range legacy {
+		if c.ApiUrl == "" {
+			continue
+		}
+		chains = append(chains, c)
+	}
+	return chains
+}
+
+// resolveChains merges [[chains]] blocks with the legacy per-network blocks.
+// Chains declared in [[chains]] take precedence over legacy blocks with the same name.
+func (cfg *Config) resolveChains() error {
+	seen := map[string]bool{}
+	for _, c := range cfg.Chains {
+		if seen[c.Name] {
+			return fmt.Errorf("chain %s is configured more than once", c.Name)
+		}
+		seen[c.Name] = true
+	}
+
+	for _, c := range cfg.legacyChains() {
+		if seen[c.Name] {
+			continue
+		}
+		cfg.Chains = append(cfg.Chains, c)
+	}
+
+	for i := range cfg.Chains {
+		if err := cfg.Chains[i].validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ChainByName returns the chain config of a configured network
+func (cfg *Config) ChainByName(name string) (ChainConfig, bool) {
+	for _, c := range cfg.Chains {
+		if c.Name == name {
+			return c, true
+		}
+	}
+	return ChainConfig{}, false
+}
+
+func (m *Monitor) buildChainAdapters() []ChainAdapter {
+	adapters := []ChainAdapter{}
+	for _, c := range m.cfg.Chains {
+		factory, ok := chainAdapterRegistry[c.Kind]
+		if !ok {
+			m.logger.Error().Str("network", c.Name).Str("kind", c.Kind).Msg("unknown chain kind -- skipping")
+			continue
+		}
+		adapters = append(adapters, factory(m, c))
+	}
+	return adapters
+}
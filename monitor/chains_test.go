@@ -0,0 +1,146 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/pelletier/go-toml/v2"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveChains(t *testing.T) {
+	raw := `
+[ethereum]
+api_url = "https://api.etherscan.io/api"
+address = "0xabc"
+
+[osmosis]
+api_url = "https://lcd.osmosis.zone"
+address = "osmo1abc"
+
+[[chains]]
+name = "polygon"
+kind = "etherscan"
+native_token = "POL"
+api_url = "https://api.etherscan.io/v2/api"
+
+[[chains]]
+name = "ethereum"
+kind = "etherscan"
+api_url = "https://api.etherscan.io/v2/api"
+`
+	cfg := &Config{}
+	require.NoError(t, toml.Unmarshal([]byte(raw), cfg))
+	require.NoError(t, cfg.resolveChains())
+	require.Len(t, cfg.Chains, 3)
+
+	polygon, ok := cfg.ChainByName("polygon")
+	require.True(t, ok)
+	assert.Equal(t, 137, polygon.ChainId)
+	assert.Equal(t, "POL", polygon.NativeToken)
+	assert.Equal(t, COINGECKO_ETHEREUM_ID, polygon.CoingeckoId)
+
+	// [[chains]] entry takes precedence over the legacy block
+	ethereum, ok := cfg.ChainByName("ethereum")
+	require.True(t, ok)
+	assert.Equal(t, "https://api.etherscan.io/v2/api", ethereum.ApiUrl)
+	assert.Equal(t, 1, ethereum.ChainId)
+
+	osmosis, ok := cfg.ChainByName("osmosis")
+	require.True(t, ok)
+	assert.Equal(t, CHAIN_KIND_COSMOS_LCD, osmosis.Kind)
+	assert.Equal(t, "uosmo", osmosis.NativeToken)
+
+	bad := &Config{Chains: []ChainConfig{{Name: "optimism", Kind: "unknown"}}}
+	assert.Error(t, bad.resolveChains())
+
+	// unknown network without chain_id
+	noChainId := &Config{Chains: []ChainConfig{{Name: "linea", Kind: CHAIN_KIND_ETHERSCAN}}}
+	assert.ErrorContains(t, noChainId.resolveChains(), "chain_id is required")
+
+	noChainId.Chains[0].ChainId = 59144
+	assert.NoError(t, noChainId.resolveChains())
+}
+
+func TestBalanceAdaptersReturnErrors(t *testing.T) {
+	ctx := context.Background()
+	avaxBalanceSleep = 0
+
+	t.Run("cosmos", func(t *testing.T) {
+		var queries atomic.Int32
+		failing := fakeLcd(t, 100, http.StatusServiceUnavailable, "", &queries)
+		healthy := fakeLcd(t, 100, http.StatusOK, `{"balances":[{"denom":"uosmo","amount":"5000000"},{"denom":"ufoo","amount":"1"}]}`, &queries)
+
+		m := newTestMonitorWithDB(t, nil)
+		chain := ChainConfig{Name: "cosmoshub", Kind: CHAIN_KIND_COSMOS_LCD, NativeToken: "uosmo", ChainEntry: ChainEntry{ApiUrl: failing.URL, Address: "osmo1abc"}}
+		assert.Error(t, newCosmosAdapter(m, chain).RunBalances(ctx))
+
+		chain.ApiUrl = healthy.URL
+		require.NoError(t, newCosmosAdapter(m, chain).RunBalances(ctx))
+		balances, err := m.GetDbBalancesInTimeRange("cosmoshub", time.Unix(0, 0), time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		require.Len(t, balances, 1)
+		assert.Equal(t, "5000000", balances[0].Balance)
+	})
+
+	t.Run("etherscan", func(t *testing.T) {
+		resp := EthBalanceResponse{Status: "0", Message: "NOTOK", Result: "Invalid API Key"}
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			json.NewEncoder(w).Encode(resp)
+		}))
+		defer srv.Close()
+
+		m := newTestMonitorWithDB(t, nil)
+		chain := ChainConfig{Name: ARBITRUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN, NativeToken: "ETH", ChainEntry: ChainEntry{ApiUrl: srv.URL, Address: testSolverAddress, UsdcAddress: testUsdcAddress}}
+		adapter := newEtherscanAdapter(m, chain)
+		// the error message is served with a 200 and is not stored as a balance
+		assert.ErrorContains(t, adapter.RunBalances(ctx), "Invalid API Key")
+		balances, err := m.GetDbBalancesInTimeRange(ARBITRUM_NETWORK, time.Unix(0, 0), time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Empty(t, balances)
+
+		resp = EthBalanceResponse{Status: "1", Message: "OK", Result: "2000000"}
+		require.NoError(t, adapter.RunBalances(ctx))
+		balances, err = m.GetDbBalancesInTimeRange(ARBITRUM_NETWORK, time.Unix(0, 0), time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, balances, 2)
+	})
+
+	t.Run("glacier", func(t *testing.T) {
+		code := http.StatusTooManyRequests
+		holdings := `{"items":[{"tokenAddress":"0xUSDC","tokenQuantity":"7000000"}]}`
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if code != http.StatusOK {
+				w.WriteHeader(code)
+				return
+			}
+			if r.URL.Path == "/address/0xsolver/erc20-holdings" {
+				w.Write([]byte(holdings))
+				return
+			}
+			w.Write([]byte(`{"balance":"2000000000000000000"}`))
+		}))
+		defer srv.Close()
+
+		m := newTestMonitorWithDB(t, nil)
+		chain := ChainConfig{Name: AVALANCHE_NETWORK, Kind: CHAIN_KIND_AVALANCHE_GLACIER, NativeToken: "AVAX", ChainEntry: ChainEntry{ApiUrl: srv.URL, Address: "0xsolver", UsdcAddress: "0xusdc"}}
+		adapter := newGlacierAdapter(m, chain)
+		assert.Error(t, adapter.RunBalances(ctx))
+
+		code = http.StatusOK
+		require.NoError(t, adapter.RunBalances(ctx))
+		balances, err := m.GetDbBalancesInTimeRange(AVALANCHE_NETWORK, time.Unix(0, 0), time.Now().Add(time.Minute))
+		require.NoError(t, err)
+		assert.Len(t, balances, 2)
+
+		// holding no USDC is not an error
+		holdings = `{"items":[]}`
+		assert.NoError(t, adapter.RunBalances(ctx))
+	})
+}
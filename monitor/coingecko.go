@@ -1,10 +1,12 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
+	"slices"
 	"strings"
 )
 
@@ -30,7 +32,7 @@ type UsdPrice struct {
 //	}
 type PriceResponse map[string]UsdPrice
 
-func (m *Monitor) GetCoingeckoPrices() error {
+func (m *Monitor) GetCoingeckoPrices(ctx context.Context) error {
 	m.logger.Info().Msg("Fetching USD prices from CoinGecko")
 
 	denoms := []string{
@@ -38,15 +40,21 @@ func (m *Monitor) GetCoingeckoPrices() error {
 		COINGECKO_OSMOSIS_ID,
 		COINGECKO_AVALANCHE_ID,
 	}
+	// native tokens of additional configured chains (e.g. polygon, bnb)
+	for _, chain := range m.cfg.Chains {
+		if chain.CoingeckoId != "" && !slices.Contains(denoms, chain.CoingeckoId) {
+			denoms = append(denoms, chain.CoingeckoId)
+		}
+	}
 	denomString := strings.Join(denoms, ",")
-	prices, err := fetchPrice(denomString)
+	prices, err := fetchPrice(ctx, denomString)
 	if err != nil {
 		m.logger.Error().Err(err).Msgf("Failed to fetch prices for %s", denomString)
 		return err
 	}
 
 	for denom, price := range prices {
-		err = m.InsertUsdPrice(denom, price.USD)
+		err = m.InsertUsdPrice(ctx, denom, price.USD)
 		if err != nil {
 			m.logger.Error().Err(err).Msg("Failed to store ETH price in database")
 			return err
@@ -57,11 +65,15 @@ func (m *Monitor) GetCoingeckoPrices() error {
 }
 
 // Accepts a comma separated list of denoms (e.g. "ethereum,osmosis")
-func fetchPrice(denoms string) (PriceResponse, error) {
+func fetchPrice(ctx context.Context, denoms string) (PriceResponse, error) {
 	url := fmt.Sprintf("https://api.coingecko.com/api/v3/simple/price?ids=%s&vs_currencies=usd", denoms)
 
 	// Make HTTP GET request
-	resp, err := http.Get(url)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := httpClient.Do(req)
 	if err != nil {
 		return nil, err
 	}
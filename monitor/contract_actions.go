@@ -0,0 +1,311 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"golang.org/x/crypto/sha3"
+)
+
+// execute messages of the fast transfer contract
+const (
+	CONTRACT_ACTION_FILL_ORDER          = "fill_order"
+	CONTRACT_ACTION_INITIATE_SETTLEMENT = "initiate_settlement"
+	CONTRACT_ACTION_INITIATE_TIMEOUT    = "initiate_timeout"
+	// execute message of the hyperlane mailbox that relays the settlements and refunds to the contract
+	CONTRACT_ACTION_PROCESS = "process"
+	// commands of the relayed hyperlane messages -- stored as actions in order_actions
+	CONTRACT_ACTION_SETTLE_ORDERS = "settle_orders"
+	CONTRACT_ACTION_REFUND_ORDERS = "refund_orders"
+)
+
+// first byte of the hyperlane message body
+const (
+	HYPERLANE_COMMAND_SETTLE_ORDERS = 0
+	HYPERLANE_COMMAND_REFUND_ORDERS = 1
+)
+
+// version (1 byte) || nonce (4) || origin (4) || sender (32) || destination (4) || recipient (32) before the body
+const HYPERLANE_MESSAGE_HEADER_SIZE = 77
+
+const (
+	ORDER_ACTIONS_JOB              = "order_actions"
+	DEFAULT_ORDER_ACTIONS_SCHEDULE = "@every 5m"
+)
+
+func orderActionsQuery(contractAddress string) string {
+	return fmt.Sprintf("execute._contract_address='%s'", contractAddress)
+}
+
+// normalizeHex lowercases and strips the 0x prefix so ids from messages and events compare equal
+func normalizeHex(s string) string {
+	return strings.TrimPrefix(strings.ToLower(s), "0x")
+}
+
+func decodeHexBytes(s string, size int) ([]byte, error) {
+	bz, err := hex.DecodeString(normalizeHex(s))
+	if err != nil {
+		return nil, err
+	}
+	if size > 0 && len(bz) != size {
+		return nil, fmt.Errorf("expected %d bytes, got %d", size, len(bz))
+	}
+	return bz, nil
+}
+
+func uint256Bytes(amount string) ([]byte, error) {
+	v, ok := new(big.Int).SetString(amount, 10)
+	if !ok || v.Sign() < 0 || v.BitLen() > 256 {
+		return nil, fmt.Errorf("invalid amount %q", amount)
+	}
+	return v.FillBytes(make([]byte, 32)), nil
+}
+
+// OrderId is the keccak256 hash of the packed order, same as the order_id emitted by the contract
+func (o FastTransferOrder) OrderId() (string, error) {
+	sender, err := decodeHexBytes(o.Sender, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid sender: %w", err)
+	}
+	recipient, err := decodeHexBytes(o.Recipient, 32)
+	if err != nil {
+		return "", fmt.Errorf("invalid recipient: %w", err)
+	}
+	amountIn, err := uint256Bytes(o.AmountIn)
+	if err != nil {
+		return "", err
+	}
+	amountOut, err := uint256Bytes(o.AmountOut)
+	if err != nil {
+		return "", err
+	}
+	data, err := decodeHexBytes(o.Data, 0)
+	if err != nil {
+		return "", fmt.Errorf("invalid data: %w", err)
+	}
+
+	packed := append([]byte{}, sender...)
+	packed = append(packed, recipient...)
+	packed = append(packed, amountIn...)
+	packed = append(packed, amountOut...)
+	packed = binary.BigEndian.AppendUint32(packed, o.Nonce)
+	packed = binary.BigEndian.AppendUint32(packed, o.SourceDomain)
+	packed = binary.BigEndian.AppendUint32(packed, o.DestinationDomain)
+	packed = binary.BigEndian.AppendUint64(packed, o.TimeoutTimestamp)
+	packed = append(packed, data...)
+
+	hash := sha3.NewLegacyKeccak256()
+	hash.Write(packed)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// decodeHyperlaneMessage returns the origin domain, the recipient and the hex encoded body of a hyperlane message
+func decodeHyperlaneMessage(message string) (uint32, []byte, string, error) {
+	bz, err := decodeHexBytes(message, 0)
+	if err != nil {
+		return 0, nil, "", err
+	}
+	if len(bz) < HYPERLANE_MESSAGE_HEADER_SIZE {
+		return 0, nil, "", fmt.Errorf("message shorter than the %d byte header", HYPERLANE_MESSAGE_HEADER_SIZE)
+	}
+	origin := binary.BigEndian.Uint32(bz[5:9])
+	recipient := bz[45:HYPERLANE_MESSAGE_HEADER_SIZE]
+	return origin, recipient, hex.EncodeToString(bz[HYPERLANE_MESSAGE_HEADER_SIZE:]), nil
+}
+
+// decodeHyperlaneBody decodes the body of a message relayed to the contract:
+// command (1 byte) || repayment address (32 bytes, settle_orders only) || order ids (32 bytes each)
+func decodeHyperlaneBody(body string) (string, string, []string, error) {
+	bz, err := decodeHexBytes(body, 0)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if len(bz) == 0 {
+		return "", "", nil, fmt.Errorf("empty body")
+	}
+
+	action, repaymentAddress, ids := "", "", bz[1:]
+	switch bz[0] {
+	case HYPERLANE_COMMAND_SETTLE_ORDERS:
+		if len(ids) < 32 {
+			return "", "", nil, fmt.Errorf("settle_orders body without repayment address")
+		}
+		action = CONTRACT_ACTION_SETTLE_ORDERS
+		repaymentAddress = hex.EncodeToString(ids[:32])
+		ids = ids[32:]
+	case HYPERLANE_COMMAND_REFUND_ORDERS:
+		action = CONTRACT_ACTION_REFUND_ORDERS
+	default:
+		return "", "", nil, fmt.Errorf("unknown command %d", bz[0])
+	}
+
+	if len(ids)%32 != 0 {
+		return "", "", nil, fmt.Errorf("order ids are not 32 byte aligned")
+	}
+	orderIds := []string{}
+	for i := 0; i < len(ids); i += 32 {
+		orderIds = append(orderIds, hex.EncodeToString(ids[i:i+32]))
+	}
+	return action, repaymentAddress, orderIds, nil
+}
+
+// DecodeOrderActions returns a row per order of the initiate_settlement and initiate_timeout messages of the tx
+// and of the hyperlane messages the mailbox relays to the contract
+func (m *Monitor) DecodeOrderActions(r *sdktypes.TxResponse) []DbOrderAction {
+	// the mailbox also relays messages to other contracts
+	_, contract, err := bech32.DecodeAndConvert(m.cfg.Osmosis.ContractAddress)
+	if err != nil {
+		m.logger.Warn().Err(err).Msg("invalid contract address -- skipping relayed hyperlane messages")
+	}
+
+	actions := []DbOrderAction{}
+	m.decodeTxMessages(r, func(i int, msg []byte) {
+		execs, err := m.getExecuteMsgs(msg, CONTRACT_ACTION_INITIATE_SETTLEMENT, CONTRACT_ACTION_INITIATE_TIMEOUT, CONTRACT_ACTION_PROCESS)
+		if err != nil {
+			return
+		}
+
+		for _, exec := range execs {
+			envelope := ContractActionEnvelope{}
+			if err := json.Unmarshal(exec.body, &envelope); err != nil {
+				// types don't match -- skip
+				continue
+			}
+			base := DbOrderAction{
+				TxHash:             r.TxHash,
+				MsgIndex:           i,
+				AuthzMsgIndex:      exec.authzMsgIndex,
+				Sender:             exec.sender,
+				Height:             r.Height,
+				Code:               int64(r.Code),
+				IngestionTimestamp: time.Now(),
+			}
+
+			switch {
+			case envelope.InitiateSettlement != nil:
+				for _, orderId := range envelope.InitiateSettlement.OrderIds {
+					a := base
+					a.Action = CONTRACT_ACTION_INITIATE_SETTLEMENT
+					a.OrderId = normalizeHex(orderId)
+					a.RepaymentAddress = normalizeHex(envelope.InitiateSettlement.RepaymentAddress)
+					actions = append(actions, a)
+				}
+			case envelope.InitiateTimeout != nil:
+				for _, order := range envelope.InitiateTimeout.Orders {
+					orderId, err := order.OrderId()
+					if err != nil {
+						m.logger.Warn().Err(err).Str("tx_hash", r.TxHash).Msg("failed to compute order id of timed out order")
+						continue
+					}
+					a := base
+					a.Action = CONTRACT_ACTION_INITIATE_TIMEOUT
+					a.OrderId = orderId
+					a.Domain = strconv.Itoa(int(order.SourceDomain))
+					actions = append(actions, a)
+				}
+			case envelope.Process != nil:
+				origin, recipient, body, err := decodeHyperlaneMessage(envelope.Process.Message)
+				if err != nil {
+					m.logger.Warn().Err(err).Str("tx_hash", r.TxHash).Msg("failed to decode hyperlane message")
+					continue
+				}
+				if len(contract) == 0 || !bytes.Equal(recipient, contract) {
+					continue
+				}
+				action, repaymentAddress, orderIds, err := decodeHyperlaneBody(body)
+				if err != nil {
+					m.logger.Warn().Err(err).Str("tx_hash", r.TxHash).Msg("failed to decode hyperlane message body")
+					continue
+				}
+				for _, orderId := range orderIds {
+					a := base
+					a.Action = action
+					a.OrderId = orderId
+					a.RepaymentAddress = repaymentAddress
+					a.Domain = strconv.Itoa(int(origin))
+					actions = append(actions, a)
+				}
+			}
+		}
+	})
+	return actions
+}
+
+// RunOrderActions stores the settlement, timeout and refund messages executed on the contract since the cursor
+// and marks the orders repaid on their source domain as settled.
+// The cursor only advances after every action up to its height is stored.
+func (m *Monitor) RunOrderActions(ctx context.Context) error {
+	cursor, err := m.GetEthCursor(OSMOSIS_NETWORK, ORDER_ACTIONS_JOB)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return fmt.Errorf("failed to get order actions cursor: %w", err)
+	}
+
+	saved, maxHeight, err := m.storeOrderActionsSince(ctx, cursor)
+	if err != nil {
+		return err
+	}
+	if maxHeight > cursor {
+		if err := m.UpsertEthCursor(ctx, OSMOSIS_NETWORK, ORDER_ACTIONS_JOB, maxHeight); err != nil {
+			return fmt.Errorf("failed to store order actions cursor: %w", err)
+		}
+	}
+	m.logger.Info().Int("count", saved).Int64("height", maxHeight).Msg("saved contract order actions from osmosis")
+
+	settled, err := m.MatchOrderSettlements(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to match order settlements: %w", err)
+	}
+	m.logger.Info().Int("count", settled).Msg("matched repayments of settled orders")
+	return nil
+}
+
+// BackfillOrderActions stores the order actions above fromHeight without moving the cursor of the periodic job.
+// Returns the number of stored actions.
+func (m *Monitor) BackfillOrderActions(ctx context.Context, fromHeight int64) (int, error) {
+	saved, _, err := m.storeOrderActionsSince(ctx, fromHeight)
+	return saved, err
+}
+
+func (m *Monitor) storeOrderActionsSince(ctx context.Context, height int64) (int, int64, error) {
+	txResponses, err := m.getTxResponsesSince(ctx, orderActionsQuery(m.cfg.Osmosis.ContractAddress), int(height))
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to fetch contract txs: %w", err)
+	}
+
+	saved, maxHeight := 0, height
+	for _, txResponse := range txResponses {
+		for _, action := range m.DecodeOrderActions(txResponse) {
+			if err := m.InsertOrderAction(ctx, action); err != nil {
+				return saved, 0, fmt.Errorf("failed to insert order action %s: %w", action.TxHash, err)
+			}
+			saved++
+		}
+		maxHeight = max(maxHeight, txResponse.Height)
+	}
+	return saved, maxHeight, nil
+}
+
+// SettlementStats are the fills of a filler with the same source domain.
+// Fills stay unsettled until the filler initiates their settlement -- the unsettled amount is the capital fronted on osmosis.
+type SettlementStats struct {
+	SourceDomain            string `json:"source_domain"`
+	Network                 string `json:"network"`
+	FilledOrders            int64  `json:"filled_orders"`
+	SettlementInitiated     int64  `json:"settlement_initiated"`
+	UnsettledOrders         int64  `json:"unsettled_orders"`
+	UnsettledAmount         string `json:"unsettled_amount"` // USDC
+	AvgSettlementLagSeconds int64  `json:"avg_settlement_lag_seconds"`
+	MaxSettlementLagSeconds int64  `json:"max_settlement_lag_seconds"`
+}
@@ -0,0 +1,294 @@
+package monitor
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	wasmtypes "github.com/CosmWasm/wasmd/x/wasm/types"
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/bech32"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testContract         = "osmo1vy34lpt5zlj797w7zqdta3qfq834kapx88qtgudy7jgljztj567s73ny82"
+	testRepaymentAddress = "000000000000000000000000a1b2c3d4e5f60718293a4b5c6d7e8f9001122334"
+)
+
+func wasmExecAny(t *testing.T, sender string, body any) *codectypes.Any {
+	t.Helper()
+	msg, err := json.Marshal(body)
+	require.NoError(t, err)
+	exec, err := codectypes.NewAnyWithValue(&wasmtypes.MsgExecuteContract{Sender: sender, Contract: testContract, Msg: msg})
+	require.NoError(t, err)
+	return exec
+}
+
+// setTxMessages replaces the messages of the tx with msgs
+func setTxMessages(t *testing.T, m *Monitor, txResponse *sdktypes.TxResponse, msgs ...*codectypes.Any) {
+	t.Helper()
+	var sdkTx tx.Tx
+	require.NoError(t, m.Codec.Unmarshal(txResponse.Tx.Value, &sdkTx))
+	sdkTx.Body.Messages = msgs
+	bz, err := m.Codec.Marshal(&sdkTx)
+	require.NoError(t, err)
+	txResponse.Tx.Value = bz
+}
+
+// orderActionsTx turns the first fixture tx into a tx where the filler of the fixture settles order1 and times out
+// the filled order with authz.
+// Senders have to be valid addresses, the decoder resolves the signers of the messages.
+func orderActionsTx(t *testing.T, m *Monitor, order1 string) (*sdktypes.TxResponse, FastTransferOrder, string) {
+	t.Helper()
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+	txResponse := data.TxResponses[0]
+	fills := m.DecodeTxResponse(txResponse)
+	require.Len(t, fills, 1)
+	order, filler := *fills[0].FillOrder.Order, fills[0].FillOrder.Filler
+
+	timeout, err := codectypes.NewAnyWithValue(&authz.MsgExec{
+		Grantee: filler,
+		Msgs:    []*codectypes.Any{wasmExecAny(t, filler, ContractActionEnvelope{InitiateTimeout: &InitiateTimeoutMsg{Orders: []FastTransferOrder{order}}})},
+	})
+	require.NoError(t, err)
+
+	setTxMessages(t, m, txResponse,
+		wasmExecAny(t, filler, ContractActionEnvelope{InitiateSettlement: &InitiateSettlementMsg{
+			OrderIds:         []string{"0x" + strings.ToUpper(order1)},
+			RepaymentAddress: testRepaymentAddress,
+		}}),
+		timeout,
+	)
+	return txResponse, order, filler
+}
+
+// hyperlaneMessage encodes a message from the arbitrum contract to the recipient on osmosis
+func hyperlaneMessage(recipient []byte, body string) string {
+	header := []byte{3}
+	header = binary.BigEndian.AppendUint32(header, 1042)
+	header = binary.BigEndian.AppendUint32(header, 42161)
+	header = append(header, bytes.Repeat([]byte{0xaa}, 32)...)
+	header = binary.BigEndian.AppendUint32(header, 875)
+	header = append(header, recipient...)
+	return hex.EncodeToString(header) + body
+}
+
+// relayedSettlementTx turns the second fixture tx into a tx as sent by a hyperlane relayer: process on the mailbox
+// delivers a settlement of order1 and order2 from arbitrum, the mailbox calls handle on the contract as a sub-message.
+// A second message in the tx is relayed to another contract.
+func relayedSettlementTx(t *testing.T, m *Monitor, order1, order2 string) *sdktypes.TxResponse {
+	t.Helper()
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+	txResponse := data.TxResponses[1]
+	relayer := m.DecodeTxResponse(txResponse)[0].FillOrder.Filler
+
+	_, contract, err := bech32.DecodeAndConvert(testContract)
+	require.NoError(t, err)
+	mailbox := sdktypes.MustBech32ifyAddressBytes("osmo", bytes.Repeat([]byte{0x4d}, 32))
+	process := func(recipient []byte, body string) *codectypes.Any {
+		msg, err := json.Marshal(ContractActionEnvelope{Process: &HyperlaneProcessMsg{
+			// multisig ism metadata: merkle tree hook, root, index and a signature
+			Metadata: strings.Repeat("0b", 32) + strings.Repeat("ef", 32) + "00000412" + strings.Repeat("5a", 65),
+			Message:  hyperlaneMessage(recipient, body),
+		}})
+		require.NoError(t, err)
+		exec, err := codectypes.NewAnyWithValue(&wasmtypes.MsgExecuteContract{Sender: relayer, Contract: mailbox, Msg: msg})
+		require.NoError(t, err)
+		return exec
+	}
+	setTxMessages(t, m, txResponse,
+		process(contract, "00"+testRepaymentAddress+order1+order2),
+		process(bytes.Repeat([]byte{0x77}, 32), "01"+order1),
+	)
+
+	// the sub-message executes the contract, which is what the order actions query matches
+	txResponse.Events = nil
+	for _, address := range []string{mailbox, testContract} {
+		txResponse.Events = append(txResponse.Events, abcitypes.Event{Type: "execute", Attributes: []abcitypes.EventAttribute{
+			{Key: "_contract_address", Value: address, Index: true},
+			{Key: "msg_index", Value: "0", Index: true},
+		}})
+	}
+	return txResponse
+}
+
+func TestOrderIdMatchesEvents(t *testing.T) {
+	m := newTestMonitor()
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+	var authzTx sdktypes.TxResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "test_multi_execs_authz.json"), &authzTx))
+
+	for _, txResponse := range append(data.TxResponses, &authzTx) {
+		orders, _ := m.decodeOrderTxResponses([]*sdktypes.TxResponse{txResponse})
+		fills := m.DecodeTxResponse(txResponse)
+		require.Len(t, fills, len(orders))
+		for i, fill := range fills {
+			require.NotEmpty(t, orders[i].OrderId, txResponse.TxHash)
+			orderId, err := fill.FillOrder.Order.OrderId()
+			require.NoError(t, err)
+			assert.Equal(t, orders[i].OrderId, orderId, txResponse.TxHash)
+		}
+	}
+}
+
+func TestDecodeHyperlaneBody(t *testing.T) {
+	id := strings.Repeat("ab", 32)
+	action, repaymentAddress, ids, err := decodeHyperlaneBody("0x01" + id + id)
+	require.NoError(t, err)
+	assert.Equal(t, CONTRACT_ACTION_REFUND_ORDERS, action)
+	assert.Empty(t, repaymentAddress)
+	assert.Equal(t, []string{id, id}, ids)
+
+	_, _, _, err = decodeHyperlaneBody("00" + id[:10])
+	assert.Error(t, err)
+	_, _, _, err = decodeHyperlaneBody("02" + id)
+	assert.Error(t, err)
+
+	origin, recipient, body, err := decodeHyperlaneMessage(hyperlaneMessage(bytes.Repeat([]byte{0x01}, 32), "01"+id))
+	require.NoError(t, err)
+	assert.Equal(t, uint32(42161), origin)
+	assert.Equal(t, bytes.Repeat([]byte{0x01}, 32), recipient)
+	assert.Equal(t, "01"+id, body)
+
+	_, _, _, err = decodeHyperlaneMessage(hyperlaneMessage(nil, ""))
+	assert.Error(t, err)
+}
+
+func TestDecodeOrderActions(t *testing.T) {
+	m := newTestMonitor()
+	m.cfg = &Config{Osmosis: OsmosisConfig{SolverConfig: SolverConfig{ContractAddress: testContract}}}
+	order1, order2 := strings.Repeat("11", 32), strings.Repeat("22", 32)
+	txResponse, order, filler := orderActionsTx(t, m, order1)
+	timedOutId, err := order.OrderId()
+	require.NoError(t, err)
+
+	actions := m.DecodeOrderActions(txResponse)
+	require.Len(t, actions, 2)
+
+	assert.Equal(t, CONTRACT_ACTION_INITIATE_SETTLEMENT, actions[0].Action)
+	assert.Equal(t, order1, actions[0].OrderId)
+	assert.Equal(t, testRepaymentAddress, actions[0].RepaymentAddress)
+	assert.Equal(t, filler, actions[0].Sender)
+	assert.Equal(t, 0, actions[0].MsgIndex)
+	assert.Equal(t, -1, actions[0].AuthzMsgIndex)
+
+	assert.Equal(t, CONTRACT_ACTION_INITIATE_TIMEOUT, actions[1].Action)
+	assert.Equal(t, timedOutId, actions[1].OrderId)
+	assert.Equal(t, filler, actions[1].Sender)
+	assert.Equal(t, 1, actions[1].MsgIndex)
+	assert.Equal(t, 0, actions[1].AuthzMsgIndex)
+
+	// only the message relayed to the contract is decoded
+	relayed := relayedSettlementTx(t, m, order1, order2)
+	actions = m.DecodeOrderActions(relayed)
+	require.Len(t, actions, 2)
+	for i, id := range []string{order1, order2} {
+		a := actions[i]
+		assert.Equal(t, CONTRACT_ACTION_SETTLE_ORDERS, a.Action)
+		assert.Equal(t, id, a.OrderId)
+		assert.Equal(t, testRepaymentAddress, a.RepaymentAddress)
+		assert.Equal(t, "42161", a.Domain)
+		assert.Equal(t, relayed.TxHash, a.TxHash)
+		assert.Equal(t, 0, a.MsgIndex)
+		assert.Equal(t, -1, a.AuthzMsgIndex)
+	}
+
+	// without the contract address nothing is attributed to the contract
+	m.cfg.Osmosis.ContractAddress = ""
+	assert.Empty(t, m.DecodeOrderActions(relayed))
+
+	// fills are not order actions
+	var authzTx sdktypes.TxResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "test_multi_execs_authz.json"), &authzTx))
+	assert.Empty(t, m.DecodeOrderActions(&authzTx))
+}
+
+func TestRunOrderActions(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	m := newTestMonitorWithDB(t, &Config{Osmosis: OsmosisConfig{SolverConfig: SolverConfig{ContractAddress: testContract}}})
+	ctx := context.Background()
+
+	order1, order2 := strings.Repeat("11", 32), strings.Repeat("22", 32)
+	txResponse, _, _ := orderActionsTx(t, m, order1)
+	relayed := relayedSettlementTx(t, m, order1, order2)
+	srv := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return []*sdktypes.TxResponse{txResponse, relayed} }))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	require.NoError(t, m.RunOrderActions(ctx))
+	cursor, err := m.GetEthCursor(OSMOSIS_NETWORK, ORDER_ACTIONS_JOB)
+	require.NoError(t, err)
+	assert.Equal(t, max(txResponse.Height, relayed.Height), cursor)
+
+	// the txs are below the cursor and are not fetched again, a backfill stores them only once
+	require.NoError(t, m.RunOrderActions(ctx))
+	saved, err := m.BackfillOrderActions(ctx, min(txResponse.Height, relayed.Height)-1)
+	require.NoError(t, err)
+	assert.Equal(t, 4, saved)
+
+	var count int
+	require.NoError(t, m.db.QueryRow(`SELECT COUNT(*) FROM order_actions`).Scan(&count))
+	assert.Equal(t, 4, count)
+}
+
+func TestGetDbSettlementStats(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+	ctx := context.Background()
+
+	orders := seedFixtureOrders(t, m)
+
+	settled := orders[0]
+	expected := SettlementStats{SourceDomain: settled.SourceDomain, Network: ChainIdToNetwork[settled.SourceDomain]}
+	unsettledAmount := int64(0)
+	for _, o := range orders {
+		if o.Filler != settled.Filler || o.SourceDomain != settled.SourceDomain || o.Code != 0 {
+			continue
+		}
+		expected.FilledOrders++
+		if o.TxHash != settled.TxHash {
+			amount, ok := new(big.Int).SetString(o.AmountOut, 10)
+			require.True(t, ok)
+			unsettledAmount += amount.Int64()
+		}
+	}
+	expected.SettlementInitiated = 1
+	expected.UnsettledOrders = expected.FilledOrders - 1
+	expected.UnsettledAmount = strconv.FormatInt(unsettledAmount, 10)
+	expected.AvgSettlementLagSeconds = 600
+	expected.MaxSettlementLagSeconds = 600
+
+	// settlement of the first fill 10 minutes later, a failed settlement doesn't count
+	require.NoError(t, m.storeBlockTime(ctx, &BlockTime{Height: settled.Height + 600, Timestamp: settled.BlockTimestamp + 600}))
+	for _, a := range []DbOrderAction{
+		{TxHash: "SETTLE", Action: CONTRACT_ACTION_INITIATE_SETTLEMENT, OrderId: settled.OrderId, Height: settled.Height + 600},
+		{TxHash: "FAILED", Action: CONTRACT_ACTION_INITIATE_SETTLEMENT, OrderId: orders[1].OrderId, Height: settled.Height + 600, Code: 5},
+	} {
+		require.NoError(t, m.InsertOrderAction(ctx, a))
+	}
+
+	stats, err := m.GetDbSettlementStats(settled.Filler)
+	require.NoError(t, err)
+	var got SettlementStats
+	for _, s := range stats {
+		if s.SourceDomain == settled.SourceDomain {
+			got = s
+		}
+	}
+	assert.Equal(t, expected, got)
+}
@@ -1,11 +1,16 @@
 package monitor
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
 	"log"
 	"math/big"
+	"slices"
+	"sort"
 	"strconv"
+	"strings"
 	"time"
 
 	_ "github.com/mattn/go-sqlite3"
@@ -22,6 +27,37 @@ type DbOrderFilled struct {
 	Code               int64     `json:"code"`
 	IngestionTimestamp time.Time `json:"ingestion_timestamp"`
 	Filler             string    `json:"filler"`
+	Recipient          string    `json:"recipient"`
+	Nonce              uint32    `json:"nonce"`
+	DestinationDomain  string    `json:"destination_domain"`
+	TimeoutTimestamp   uint64    `json:"timeout_timestamp"`
+	Data               string    `json:"data,omitempty"`
+	// position of the fill_order message in the tx and inside the authz.MsgExec (-1 if not executed with authz)
+	MsgIndex      int `json:"msg_index"`
+	AuthzMsgIndex int `json:"authz_msg_index"`
+	// order ID from the order_filled event
+	OrderId string `json:"order_id"`
+	// comma separated reasons if the fill message and the tx events disagree, empty otherwise
+	EventMismatch string `json:"event_mismatch"`
+	// block time of the tx from the tx response (0 if unknown), stored in osmo_block_times
+	BlockTimestamp int64 `json:"-"`
+}
+
+// DbOrderAction is an order referenced by an initiate_settlement, initiate_timeout or hyperlane handle message
+type DbOrderAction struct {
+	TxHash        string `json:"tx_hash"`
+	MsgIndex      int    `json:"msg_index"`
+	AuthzMsgIndex int    `json:"authz_msg_index"`
+	Action        string `json:"action"`
+	OrderId       string `json:"order_id"`
+	// sender of the execute message (the filler for initiate_settlement)
+	Sender           string `json:"sender"`
+	RepaymentAddress string `json:"repayment_address,omitempty"`
+	// source domain of timed out orders, origin domain of hyperlane messages
+	Domain             string    `json:"domain,omitempty"`
+	Height             int64     `json:"height"`
+	Code               int64     `json:"code"`
+	IngestionTimestamp time.Time `json:"ingestion_timestamp"`
 }
 
 type DbTxResponse struct {
@@ -38,9 +74,39 @@ type DbEthTxResponse struct {
 	GasUsedWei int64  `json:"gas_used_wei"` // value in wei -> gasUsed * gasPrice
 	Valid      bool   `json:"valid"`
 	Network    string `json:"network"`
+	From       string `json:"from"`
+	Input      string `json:"input"`       // calldata -- used to link txs to osmosis orders
 	TxResponse []byte `json:"tx_response"` // raw response so we can fallback to local stores if we need to recover or sth
 }
 
+type DbTokenTransfer struct {
+	TxHash        string `json:"tx_hash"`
+	LogIndex      string `json:"log_index,omitempty"`
+	Network       string `json:"network"`
+	Token         string `json:"token"`
+	TokenAddress  string `json:"token_address"`
+	Direction     string `json:"direction"` // in or out -- relative to the solver address
+	Counterparty  string `json:"counterparty"`
+	Amount        int64  `json:"amount"`
+	Height        int64  `json:"height"`
+	Timestamp     int64  `json:"timestamp"`
+	SolverAddress string `json:"solver_address"`
+}
+
+type DbInternalTx struct {
+	TxHash       string `json:"tx_hash"`
+	TraceId      string `json:"trace_id"`
+	Network      string `json:"network"`
+	Token        string `json:"token"`
+	Direction    string `json:"direction"` // in or out -- relative to the solver address
+	Counterparty string `json:"counterparty"`
+	Value        string `json:"value"` // value in wei -> kept as string (uint256)
+	Height       int64  `json:"height"`
+	Timestamp    int64  `json:"timestamp"`
+	Type         string `json:"type"`
+	IsError      bool   `json:"is_error"`
+}
+
 type DbBalance struct {
 	Timestamp int64  `json:"timestamp"`
 	Balance   string `json:"balance"`
@@ -50,36 +116,59 @@ type DbBalance struct {
 	Network   string `json:"network,omitempty"`
 }
 
-func InitDB(db *sql.DB) {
+// a tx can fill multiple orders (e.g. batched authz execs) so fills are keyed by the message and the order nonce.
+// nonce is 0 on fills stored before nonces were tracked.
+const txDataSchema = `(
+	tx_hash TEXT,
+	sender TEXT,
+	amount_in INTEGER,
+	amount_out INTEGER,
+	source_domain TEXT,
+	solver_revenue INTEGER,
+	code INTEGER,
+	height INTEGER,
+	filler TEXT,
+	ingestion_timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	recipient TEXT,
+	nonce INTEGER NOT NULL DEFAULT 0,
+	order_id TEXT,
+	event_mismatch TEXT,
+	destination_domain TEXT,
+	timeout_timestamp INTEGER,
+	data TEXT,
+	msg_index INTEGER NOT NULL DEFAULT 0,
+	authz_msg_index INTEGER NOT NULL DEFAULT -1,
+	PRIMARY KEY (tx_hash, msg_index, authz_msg_index, nonce)
+)`
+
+// a fill is keyed by its message in the tx and in the authz exec, and by the order nonce
+var txDataKey = []string{"tx_hash", "msg_index", "authz_msg_index", "nonce"}
+
+const txDataColumns = `tx_hash, sender, amount_in, amount_out, source_domain, solver_revenue, code, height, filler,
+	ingestion_timestamp, recipient, nonce, order_id, event_mismatch, destination_domain, timeout_timestamp, data,
+	msg_index, authz_msg_index`
+
+func createTxDataIndexes(db *sql.DB) error {
 	_, err := db.Exec(`
-		CREATE TABLE IF NOT EXISTS tx_data (
-			tx_hash TEXT PRIMARY KEY,
-			sender TEXT,
-			amount_in INTEGER,
-			amount_out INTEGER,
-			source_domain TEXT,
-			solver_revenue INTEGER,
-			code INTEGER,
-			height INTEGER,
-			filler TEXT,
-			ingestion_timestamp DATETIME DEFAULT CURRENT_TIMESTAMP
-		)
+		CREATE INDEX IF NOT EXISTS idx_tx_data_filler ON tx_data(filler)
 	`)
 	if err != nil {
-		log.Fatal(err)
+		return err
 	}
 
 	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_tx_data_filler ON tx_data(filler)
+		CREATE INDEX IF NOT EXISTS idx_tx_data_height ON tx_data(height)
 	`)
+	return err
+}
+
+func InitDB(db *sql.DB) {
+	_, err := db.Exec("CREATE TABLE IF NOT EXISTS tx_data " + txDataSchema)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	_, err = db.Exec(`
-		CREATE INDEX IF NOT EXISTS idx_tx_data_height ON tx_data(height)
-	`)
-	if err != nil {
+	if err := createTxDataIndexes(db); err != nil {
 		log.Fatal(err)
 	}
 
@@ -106,7 +195,11 @@ func InitDB(db *sql.DB) {
 			gas_used_usd REAL,
 			network TEXT,
 			valid BOOLEAN,
-			tx_response TEXT
+			tx_response TEXT,
+			from_address TEXT,
+			input TEXT,
+			to_address TEXT,
+			value TEXT
 		)
 	`)
 	if err != nil {
@@ -178,28 +271,340 @@ func InitDB(db *sql.DB) {
 		log.Fatal(err)
 	}
 
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS token_transfers (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tx_hash TEXT,
+			log_index TEXT,
+			network TEXT,
+			token TEXT,
+			token_address TEXT,
+			direction TEXT,
+			counterparty TEXT,
+			amount INTEGER,
+			height INTEGER,
+			timestamp INTEGER,
+			solver_address TEXT,
+			UNIQUE (network, tx_hash, log_index, direction, counterparty, amount)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_token_transfers_composite
+        ON token_transfers(network, token, timestamp)
+    `)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS eth_internal_txs (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			tx_hash TEXT,
+			trace_id TEXT,
+			network TEXT,
+			token TEXT,
+			direction TEXT,
+			counterparty TEXT,
+			value TEXT,
+			height INTEGER,
+			timestamp INTEGER,
+			type TEXT,
+			is_error BOOLEAN,
+			UNIQUE (network, tx_hash, trace_id)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_eth_internal_txs_composite
+        ON eth_internal_txs(network, timestamp)
+    `)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// last fully fetched block per network and etherscan list action (txlist, tokentx...)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS eth_sync_cursors (
+			network TEXT,
+			action TEXT,
+			height INTEGER,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (network, action)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// links osmosis fills to the EVM tx of the solver on the source domain
+	// a single EVM tx can be linked to multiple orders (e.g. batch settlements)
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS order_evm_links (
+			order_tx_hash TEXT,
+			nonce INTEGER,
+			network TEXT,
+			eth_tx_hash TEXT,
+			matched_by TEXT,
+			time_diff INTEGER,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (order_tx_hash, nonce)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_order_evm_links_eth_tx
+        ON order_evm_links(network, eth_tx_hash)
+    `)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// height ranges where the node reports more order_filled txs than tx_data holds
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS tx_data_gaps (
+			from_height INTEGER,
+			to_height INTEGER,
+			node_count INTEGER,
+			db_count INTEGER,
+			backfilled INTEGER,
+			resolved BOOLEAN,
+			detected_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			PRIMARY KEY (from_height, to_height)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// settlement, timeout and refund messages of the fast transfer contract -- one row per order
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS order_actions (
+			tx_hash TEXT,
+			msg_index INTEGER,
+			authz_msg_index INTEGER,
+			action TEXT,
+			order_id TEXT,
+			sender TEXT,
+			repayment_address TEXT,
+			domain TEXT,
+			height INTEGER,
+			code INTEGER,
+			ingestion_timestamp DATETIME,
+			PRIMARY KEY (tx_hash, msg_index, authz_msg_index, action, order_id)
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_order_actions_order_id
+        ON order_actions(order_id, action)
+    `)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	// orders repaid on the source domain after their settlement was initiated on osmosis
+	_, err = db.Exec(`
+		CREATE TABLE IF NOT EXISTS order_settlements (
+			order_id TEXT PRIMARY KEY,
+			initiation_tx_hash TEXT,
+			network TEXT,
+			repayment_tx_hash TEXT,
+			repayment_amount INTEGER,
+			settled_at INTEGER
+		)
+	`)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	_, err = db.Exec(`
+        CREATE INDEX IF NOT EXISTS idx_order_settlements_repayment
+        ON order_settlements(network, repayment_tx_hash)
+    `)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	migrateDB(db)
+
 	_, err = db.Exec("PRAGMA journal_mode=WAL")
 	if err != nil {
 		log.Fatal(err)
 	}
 }
 
-func (m *Monitor) InsertUsdPrice(denom string, price float64) error {
+// migrateDB adds the columns that were introduced after the tables were created
+func migrateDB(db *sql.DB) {
+	columns := []struct {
+		table      string
+		column     string
+		definition string
+	}{
+		{"tx_data", "recipient", "TEXT"},
+		{"tx_data", "nonce", "INTEGER NOT NULL DEFAULT 0"},
+		{"tx_data", "order_id", "TEXT"},
+		{"tx_data", "event_mismatch", "TEXT"},
+		{"tx_data", "destination_domain", "TEXT"},
+		{"tx_data", "timeout_timestamp", "INTEGER"},
+		{"tx_data", "data", "TEXT"},
+		{"tx_data", "msg_index", "INTEGER NOT NULL DEFAULT 0"},
+		{"tx_data", "authz_msg_index", "INTEGER NOT NULL DEFAULT -1"},
+		{"eth_tx_responses", "from_address", "TEXT"},
+		{"eth_tx_responses", "input", "TEXT"},
+		{"eth_tx_responses", "to_address", "TEXT"},
+		{"eth_tx_responses", "value", "TEXT"},
+	}
+
+	for _, c := range columns {
+		exists, err := columnExists(db, c.table, c.column)
+		if err != nil {
+			log.Fatal(err)
+		}
+		if exists {
+			continue
+		}
+		_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", c.table, c.column, c.definition))
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
+
+	if err := migrateTxDataKey(db); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// migrateTxDataKey rebuilds tx_data created with an older primary key than txDataKey.
+// sqlite can't alter primary keys so the rows are copied into a new table that replaces the old one.
+// Existing fills keep msg_index 0 -- only the first fill of each tx was stored before.
+// Rows without a nonce get nonce 0, duplicates that a NULL nonce let into the old key are dropped.
+func migrateTxDataKey(db *sql.DB) error {
+	pk, err := primaryKeyColumns(db, "tx_data")
+	if err != nil {
+		return err
+	}
+	if slices.Equal(pk, txDataKey) {
+		return nil
+	}
+	selected := strings.Replace(txDataColumns, "nonce", "COALESCE(nonce, 0)", 1)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{
+		"CREATE TABLE tx_data_migrated " + txDataSchema,
+		fmt.Sprintf("INSERT OR IGNORE INTO tx_data_migrated (%s) SELECT %s FROM tx_data", txDataColumns, selected),
+		"DROP TABLE tx_data",
+		"ALTER TABLE tx_data_migrated RENAME TO tx_data",
+	} {
+		if _, err := tx.Exec(stmt); err != nil {
+			return fmt.Errorf("failed to migrate tx_data primary key: %w", err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	// indexes are dropped with the old table
+	return createTxDataIndexes(db)
+}
+
+// dbExecer is implemented by *sql.DB and *sql.Tx
+type dbExecer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+type tableColumn struct {
+	name string
+	pk   int // position in the primary key, 0 if the column is not part of it
+}
+
+func tableColumns(db *sql.DB, table string) ([]tableColumn, error) {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	columns := []tableColumn{}
+	for rows.Next() {
+		var (
+			cid        int
+			name       string
+			ctype      string
+			notNull    bool
+			defaultVal sql.NullString
+			pk         int
+		)
+		if err := rows.Scan(&cid, &name, &ctype, &notNull, &defaultVal, &pk); err != nil {
+			return nil, err
+		}
+		columns = append(columns, tableColumn{name: name, pk: pk})
+	}
+	return columns, rows.Err()
+}
+
+func columnExists(db *sql.DB, table, column string) (bool, error) {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return false, err
+	}
+	for _, c := range columns {
+		if c.name == column {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func primaryKeyColumns(db *sql.DB, table string) ([]string, error) {
+	columns, err := tableColumns(db, table)
+	if err != nil {
+		return nil, err
+	}
+	// ordered by the position in the key
+	sort.Slice(columns, func(i, j int) bool { return columns[i].pk < columns[j].pk })
+	pk := []string{}
+	for _, c := range columns {
+		if c.pk > 0 {
+			pk = append(pk, c.name)
+		}
+	}
+	return pk, nil
+}
+
+func (m *Monitor) InsertUsdPrice(ctx context.Context, denom string, price float64) error {
 	query := `INSERT INTO usd_prices (token_denom, price_usd) VALUES (?, ?);`
-	_, err := m.db.Exec(query, denom, price)
+	_, err := m.db.ExecContext(ctx, query, denom, price)
 	return err
 }
 
-func (m *Monitor) InsertBalance(balance DbBalance) error {
-	_, err := m.db.Exec(`
+func (m *Monitor) InsertBalance(ctx context.Context, balance DbBalance) error {
+	_, err := m.db.ExecContext(ctx, `
 		INSERT INTO balances (timestamp, balance, exponent, token, network, address)
 		VALUES (?, ?, ?, ?, ?, ?)
 	`, balance.Timestamp, balance.Balance, balance.Exponent, balance.Token, balance.Network, balance.Address)
 	return err
 }
 
-func (m *Monitor) InsertRawTxResponse(txResponse DbTxResponse) error {
-	_, err := m.db.Exec(`
+func (m *Monitor) InsertRawTxResponse(ctx context.Context, txResponse DbTxResponse) error {
+	_, err := m.db.ExecContext(ctx, `
 		INSERT INTO raw_tx_responses (tx_hash, height, tx_response, valid)
 		VALUES (?, ?, ?, ?)
 	`, txResponse.TxHash, txResponse.Height, txResponse.TxResponse, txResponse.Valid)
@@ -207,7 +612,7 @@ func (m *Monitor) InsertRawTxResponse(txResponse DbTxResponse) error {
 	return err
 }
 
-func (m *Monitor) InsertEthTxResponse(txResponse EthTxDetails, network string, storeRawResponse bool) error {
+func (m *Monitor) InsertEthTxResponse(ctx context.Context, txResponse EthTxDetails, network string, storeRawResponse bool) error {
 	timestamp, err := strconv.Atoi(txResponse.TimeStamp)
 	if err != nil {
 		return err
@@ -230,10 +635,16 @@ func (m *Monitor) InsertEthTxResponse(txResponse EthTxDetails, network string, s
 	actualGasUsedWei := new(big.Int)
 	actualGasUsedWei.SetString(txResponse.GasUsed, 10) // Parse string as base 10
 	actualGasUsedWei.Mul(actualGasUsedWei, gasPrice)
-	_, err = m.db.Exec(`
-		INSERT INTO eth_tx_responses (tx_hash, height, timestamp, gas_used_wei, gas_used_usd, network, valid, tx_response)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, txResponse.Hash, height, timestamp, actualGasUsedWei.String(), txResponse.GasUsedUsd, network, txResponse.IsError, rawResponse)
+	// the value (uint256) is only transferred by successful txs -- etherscan reports "1", glacier "true" for failed ones
+	value := txResponse.Value
+	if value == "" || txResponse.IsError == "1" || txResponse.IsError == "true" {
+		value = "0"
+	}
+	_, err = m.db.ExecContext(ctx, `
+		INSERT INTO eth_tx_responses (tx_hash, height, timestamp, gas_used_wei, gas_used_usd, network, valid, tx_response, from_address, input, to_address, value)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, txResponse.Hash, height, timestamp, actualGasUsedWei.String(), txResponse.GasUsedUsd, network, txResponse.IsError, rawResponse,
+		strings.ToLower(txResponse.From), strings.ToLower(txResponse.Input), strings.ToLower(txResponse.To), value)
 	return err
 }
 
@@ -249,15 +660,350 @@ func (m *Monitor) GetLatestEthHeight(network string) (int64, error) {
 	return height, nil
 }
 
-func (m *Monitor) InsertOrderFilled(order DbOrderFilled) error {
-	_, err := m.db.Exec(`
-		INSERT INTO tx_data (tx_hash, sender, amount_in, amount_out, source_domain, solver_revenue, height, code, filler, ingestion_timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, order.TxHash, order.Sender, order.AmountIn, order.AmountOut, order.SourceDomain, order.SolverRevenue, order.Height, order.Code, order.Filler, order.IngestionTimestamp)
+// InsertTokenTransfer returns false if the transfer was already stored
+func (m *Monitor) InsertTokenTransfer(ctx context.Context, t DbTokenTransfer) (bool, error) {
+	res, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO token_transfers (tx_hash, log_index, network, token, token_address, direction, counterparty, amount, height, timestamp, solver_address)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.TxHash, t.LogIndex, t.Network, t.Token, t.TokenAddress, t.Direction, t.Counterparty, t.Amount, t.Height, t.Timestamp, t.SolverAddress)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+// InsertInternalTx returns false if the internal tx was already stored
+func (m *Monitor) InsertInternalTx(ctx context.Context, t DbInternalTx) (bool, error) {
+	res, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO eth_internal_txs (tx_hash, trace_id, network, token, direction, counterparty, value, height, timestamp, type, is_error)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, t.TxHash, t.TraceId, t.Network, t.Token, t.Direction, t.Counterparty, t.Value, t.Height, t.Timestamp, t.Type, t.IsError)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
+}
+
+func (m *Monitor) GetEthCursor(network, action string) (int64, error) {
+	var height int64
+	err := m.db.QueryRow(`
+		SELECT height FROM eth_sync_cursors WHERE network = ? AND action = ?
+	`, network, action).Scan(&height)
+	if err != nil {
+		return 0, err
+	}
+	return height, nil
+}
+
+// UpsertEthCursor stores the cursor for the network and action; cursors never move backwards
+func (m *Monitor) UpsertEthCursor(ctx context.Context, network, action string, height int64) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO eth_sync_cursors (network, action, height, updated_at)
+		VALUES (?, ?, ?, CURRENT_TIMESTAMP)
+		ON CONFLICT (network, action) DO UPDATE SET
+			height = MAX(height, excluded.height),
+			updated_at = CURRENT_TIMESTAMP
+	`, network, action, height)
+	return err
+}
+
+// GetEthTxHashes returns the set of stored tx hashes for the network starting at fromHeight
+func (m *Monitor) GetEthTxHashes(network string, fromHeight int64) (map[string]bool, error) {
+	rows, err := m.db.Query(`
+		SELECT tx_hash FROM eth_tx_responses WHERE network = ? AND height >= ?
+	`, network, fromHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[string]bool{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes[hash] = true
+	}
+	return hashes, nil
+}
+
+func (m *Monitor) InsertOrderEvmLink(ctx context.Context, link OrderEvmLink) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO order_evm_links (order_tx_hash, nonce, network, eth_tx_hash, matched_by, time_diff)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`, link.OrderTxHash, link.Nonce, link.Network, link.EthTxHash, link.MatchedBy, link.TimeDiff)
+	return err
+}
+
+// InsertOrderAction stores the action, actions that are already stored are ignored
+func (m *Monitor) InsertOrderAction(ctx context.Context, a DbOrderAction) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT OR IGNORE INTO order_actions (tx_hash, msg_index, authz_msg_index, action, order_id, sender, repayment_address, domain, height, code, ingestion_timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, a.TxHash, a.MsgIndex, a.AuthzMsgIndex, a.Action, a.OrderId, a.Sender, a.RepaymentAddress, a.Domain, a.Height, a.Code, a.IngestionTimestamp)
+	return err
+}
+
+// InsertOrderSettlements marks the orders of the pending settlement as repaid by the transfer
+func (m *Monitor) InsertOrderSettlements(ctx context.Context, p PendingSettlement, repayment DbTokenTransfer) error {
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, orderId := range p.OrderIds {
+		_, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO order_settlements (order_id, initiation_tx_hash, network, repayment_tx_hash, repayment_amount, settled_at)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, orderId, p.TxHash, repayment.Network, repayment.TxHash, repayment.Amount, repayment.Timestamp)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (m *Monitor) InsertOrderFilled(ctx context.Context, order DbOrderFilled) error {
+	return m.InsertOrdersFilled(ctx, []DbOrderFilled{order})
+}
+
+// InsertOrdersFilled stores the orders and their block times in one transaction -- either all of them are stored or none
+func (m *Monitor) InsertOrdersFilled(ctx context.Context, orders []DbOrderFilled) error {
+	tx, err := m.db.BeginTx(ctx, nil)
 	if err != nil {
 		return err
 	}
-	return nil
+	defer tx.Rollback()
+
+	for _, order := range orders {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO tx_data (tx_hash, sender, amount_in, amount_out, source_domain, solver_revenue, height, code, filler, ingestion_timestamp, recipient, nonce, order_id, event_mismatch, destination_domain, timeout_timestamp, data, msg_index, authz_msg_index)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		`, order.TxHash, order.Sender, order.AmountIn, order.AmountOut, order.SourceDomain, order.SolverRevenue, order.Height, order.Code, order.Filler, order.IngestionTimestamp, order.Recipient, order.Nonce, order.OrderId, order.EventMismatch,
+			order.DestinationDomain, order.TimeoutTimestamp, order.Data, order.MsgIndex, order.AuthzMsgIndex)
+		if err != nil {
+			return err
+		}
+		if order.BlockTimestamp > 0 {
+			if err := insertBlockTime(ctx, tx, newBlockTime(order.Height, time.Unix(order.BlockTimestamp, 0))); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.Commit()
+}
+
+// GetDbOrderTxRange returns the min and max stored order heights; both are 0 if there are no orders
+func (m *Monitor) GetDbOrderTxRange() (int64, int64, error) {
+	var minHeight, maxHeight sql.NullInt64
+	err := m.db.QueryRow("SELECT MIN(height), MAX(height) FROM tx_data").Scan(&minHeight, &maxHeight)
+	if err != nil {
+		return 0, 0, err
+	}
+	return minHeight.Int64, maxHeight.Int64, nil
+}
+
+// GetDbOrderTxHashes returns the stored order tx hashes in [fromHeight, toHeight)
+func (m *Monitor) GetDbOrderTxHashes(fromHeight, toHeight int64) (map[string]bool, error) {
+	rows, err := m.db.Query(`
+		SELECT DISTINCT tx_hash FROM tx_data WHERE height >= ? AND height < ?
+	`, fromHeight, toHeight)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	hashes := map[string]bool{}
+	for rows.Next() {
+		var hash string
+		if err := rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes[hash] = true
+	}
+	return hashes, rows.Err()
+}
+
+// UpsertOrderGap stores the latest check of a gap
+func (m *Monitor) UpsertOrderGap(ctx context.Context, gap OrderGap) error {
+	_, err := m.db.ExecContext(ctx, `
+		INSERT INTO tx_data_gaps (from_height, to_height, node_count, db_count, backfilled, resolved)
+		VALUES (?, ?, ?, ?, ?, ?)
+		ON CONFLICT (from_height, to_height) DO UPDATE SET
+			node_count = excluded.node_count,
+			db_count = excluded.db_count,
+			backfilled = excluded.backfilled,
+			resolved = excluded.resolved,
+			updated_at = CURRENT_TIMESTAMP
+	`, gap.FromHeight, gap.ToHeight, gap.NodeCount, gap.DbCount, gap.Backfilled, gap.Resolved)
+	return err
+}
+
+// GetDbOrderGaps returns the detected gaps, unresolved first
+func (m *Monitor) GetDbOrderGaps() ([]OrderGap, error) {
+	rows, err := m.db.Query(`
+		SELECT from_height, to_height, node_count, db_count, backfilled, resolved, detected_at, updated_at
+		FROM tx_data_gaps
+		ORDER BY resolved ASC, from_height ASC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	gaps := []OrderGap{}
+	for rows.Next() {
+		var g OrderGap
+		if err := rows.Scan(&g.FromHeight, &g.ToHeight, &g.NodeCount, &g.DbCount, &g.Backfilled, &g.Resolved, &g.DetectedAt, &g.UpdatedAt); err != nil {
+			return nil, err
+		}
+		gaps = append(gaps, g)
+	}
+	return gaps, rows.Err()
+}
+
+// columns scanned by scanOrderFilled -- details missing on rows stored by older versions are returned as zero values
+const orderFilledColumns = `tx_hash, sender, amount_in, amount_out, source_domain, solver_revenue, height, code, filler,
+	ingestion_timestamp, COALESCE(recipient, ''), COALESCE(nonce, 0), COALESCE(order_id, ''), COALESCE(event_mismatch, ''),
+	COALESCE(destination_domain, ''), COALESCE(timeout_timestamp, 0), COALESCE(data, ''), msg_index, authz_msg_index`
+
+func scanOrderFilled(rows *sql.Rows) (DbOrderFilled, error) {
+	var o DbOrderFilled
+	err := rows.Scan(&o.TxHash, &o.Sender, &o.AmountIn, &o.AmountOut, &o.SourceDomain, &o.SolverRevenue, &o.Height, &o.Code, &o.Filler,
+		&o.IngestionTimestamp, &o.Recipient, &o.Nonce, &o.OrderId, &o.EventMismatch,
+		&o.DestinationDomain, &o.TimeoutTimestamp, &o.Data, &o.MsgIndex, &o.AuthzMsgIndex)
+	return o, err
+}
+
+// GetDbOrderMismatches returns the fill orders whose message disagrees with the events of the tx
+func (m *Monitor) GetDbOrderMismatches() ([]DbOrderFilled, error) {
+	rows, err := m.db.Query(`
+		SELECT ` + orderFilledColumns + `
+		FROM tx_data
+		WHERE event_mismatch IS NOT NULL AND event_mismatch != ''
+		ORDER BY height DESC
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	orders := []DbOrderFilled{}
+	for rows.Next() {
+		o, err := scanOrderFilled(rows)
+		if err != nil {
+			return nil, err
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// OrderFilter selects stored fill orders -- empty fields are not filtered on
+type OrderFilter struct {
+	Filler            string
+	Sender            string
+	Recipient         string
+	SourceDomain      string
+	DestinationDomain string
+	Limit             int
+	Offset            int
+}
+
+// GetDbOrders returns the fill orders matching the filter, newest first
+func (m *Monitor) GetDbOrders(filter OrderFilter) ([]DbOrderFilled, error) {
+	query := `SELECT ` + orderFilledColumns + ` FROM tx_data WHERE 1 = 1`
+	args := []interface{}{}
+	for _, f := range []struct {
+		column string
+		value  string
+	}{
+		{"filler", filter.Filler},
+		{"sender", filter.Sender},
+		{"recipient", filter.Recipient},
+		{"source_domain", filter.SourceDomain},
+		{"destination_domain", filter.DestinationDomain},
+	} {
+		if f.value == "" {
+			continue
+		}
+		query += fmt.Sprintf(" AND %s = ?", f.column)
+		args = append(args, f.value)
+	}
+	query += " ORDER BY height DESC, tx_hash, msg_index, authz_msg_index LIMIT ? OFFSET ?"
+	args = append(args, filter.Limit, filter.Offset)
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []DbOrderFilled{}
+	for rows.Next() {
+		o, err := scanOrderFilled(rows)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// GetDbRawTxResponsesMissingDetails returns up to limit raw tx responses of fills that are missing the order details.
+// Only responses with an id above afterId are returned; the second value is the id of the last returned response.
+func (m *Monitor) GetDbRawTxResponsesMissingDetails(afterId int64, limit int) ([]DbTxResponse, int64, error) {
+	rows, err := m.db.Query(`
+		SELECT id, tx_hash, height, tx_response, valid
+		FROM raw_tx_responses
+		WHERE id > ? AND tx_hash IN (SELECT tx_hash FROM tx_data WHERE destination_domain IS NULL)
+		ORDER BY id
+		LIMIT ?
+	`, afterId, limit)
+	if err != nil {
+		return nil, afterId, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	lastId := afterId
+	responses := []DbTxResponse{}
+	for rows.Next() {
+		var r DbTxResponse
+		if err := rows.Scan(&lastId, &r.TxHash, &r.Height, &r.TxResponse, &r.Valid); err != nil {
+			return nil, afterId, fmt.Errorf("scan error: %w", err)
+		}
+		responses = append(responses, r)
+	}
+	return responses, lastId, rows.Err()
+}
+
+// UpdateOrderDetails fills in the order details of a stored fill that was ingested without them.
+// Returns false if there was no matching fill missing the details.
+func (m *Monitor) UpdateOrderDetails(ctx context.Context, order DbOrderFilled) (bool, error) {
+	res, err := m.db.ExecContext(ctx, `
+		UPDATE tx_data
+		SET recipient = ?, nonce = ?, destination_domain = ?, timeout_timestamp = ?, data = ?, msg_index = ?, authz_msg_index = ?
+		WHERE tx_hash = ? AND sender = ? AND amount_in = ? AND amount_out = ? AND destination_domain IS NULL
+	`, order.Recipient, order.Nonce, order.DestinationDomain, order.TimeoutTimestamp, order.Data, order.MsgIndex, order.AuthzMsgIndex,
+		order.TxHash, order.Sender, order.AmountIn, order.AmountOut)
+	if err != nil {
+		return false, err
+	}
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return affected > 0, nil
 }
 
 func ReadOrdersFilled(db *sql.DB) []DbOrderFilled {
@@ -1,6 +1,17 @@
 package monitor
 
-import "fmt"
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
 
 type OrderDetailsInRange struct {
 	AmountRange           string  `json:"amount_range"`
@@ -14,19 +25,58 @@ type OrderDetailsInRange struct {
 	SourceDomain          uint32  `json:"source_domain"`
 }
 
+// amount_in ranges of the fill stats in micro USDC [min, max)
+var orderAmountRanges = []struct {
+	name     string
+	min, max int64
+}{
+	{"1-250", 1, 250_000_000},
+	{"250-500", 250_000_000, 500_000_000},
+	{"500-1000", 500_000_000, 1_000_000_000},
+	{"1000-2000", 1_000_000_000, 2_000_000_000},
+	{"2000-5000", 2_000_000_000, 5_000_000_000},
+	{"5000-10000", 5_000_000_000, 10_000_000_000},
+}
+
+// amountRangesSelect returns the amount ranges as rows of (range, min_val, max_val)
+func amountRangesSelect() string {
+	selects := []string{}
+	for i, r := range orderAmountRanges {
+		if i == 0 {
+			selects = append(selects, fmt.Sprintf("  SELECT '%s' as range, %d as min_val, %d as max_val", r.name, r.min, r.max))
+			continue
+		}
+		selects = append(selects, fmt.Sprintf("  UNION SELECT '%s', %d, %d", r.name, r.min, r.max))
+	}
+	return strings.Join(selects, "\n")
+}
+
+func orderAmountRangeNames() []string {
+	names := []string{}
+	for _, r := range orderAmountRanges {
+		names = append(names, r.name)
+	}
+	return names
+}
+
+// orderAmountRange returns the name of the range of the amount, empty if it is outside of all ranges
+func orderAmountRange(amount int64) string {
+	for _, r := range orderAmountRanges {
+		if amount >= r.min && amount < r.max {
+			return r.name
+		}
+	}
+	return ""
+}
+
 func (m *Monitor) GetOrderDetailsByRange(network string, startBlock uint64, filler string) ([]OrderDetailsInRange, error) {
 	useChainId, ok := NetworkToChainId[network]
 	if !ok {
 		return []OrderDetailsInRange{}, fmt.Errorf("invalid network: %s", network)
 	}
-	rows, err := m.db.Query(`
+	rows, err := m.db.Query(fmt.Sprintf(`
 WITH ranges AS (
-  SELECT '1-250' as range, 1 as min_val, 250000000 as max_val
-  UNION SELECT '250-500', 250000000, 500000000
-  UNION SELECT '500-1000', 500000000, 1000000000
-  UNION SELECT '1000-2000', 1000000000, 2000000000
-  UNION SELECT '2000-5000', 2000000000, 5000000000
-  UNION SELECT '5000-10000', 5000000000, 10000000000
+%s
 ),
 range_totals AS (
   SELECT
@@ -60,7 +110,7 @@ ORDER BY
   r.min_val,
   t.filler,
   source_domain;
-	`, useChainId, startBlock, useChainId, startBlock)
+	`, amountRangesSelect()), useChainId, startBlock, useChainId, startBlock)
 	if err != nil {
 		return []OrderDetailsInRange{}, fmt.Errorf("query error: %w", err)
 	}
@@ -97,3 +147,521 @@ ORDER BY
 
 	return orderDetails, nil
 }
+
+// GetDbBalanceSnapshots returns the first (or last if latest is set) balance snapshot per token
+// of the network in the time range
+func (m *Monitor) GetDbBalanceSnapshots(network string, from, to time.Time, latest bool) ([]DbBalance, error) {
+	agg := "MIN"
+	if latest {
+		agg = "MAX"
+	}
+	rows, err := m.db.Query(fmt.Sprintf(`
+		SELECT b.address, b.balance, b.exponent, b.token, b.network, b.timestamp
+		FROM balances b
+		JOIN (
+			SELECT token, %s(timestamp) as ts
+			FROM balances
+			WHERE network = ? AND timestamp >= ? AND timestamp <= ?
+			GROUP BY token
+		) s ON b.token = s.token AND b.timestamp = s.ts
+		WHERE b.network = ?
+		GROUP BY b.token
+	`, agg), network, from.Unix(), to.Unix(), network)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	balances := []DbBalance{}
+	for rows.Next() {
+		var b DbBalance
+		if err := rows.Scan(&b.Address, &b.Balance, &b.Exponent, &b.Token, &b.Network, &b.Timestamp); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		balances = append(balances, b)
+	}
+	return balances, nil
+}
+
+// GetDbGasSpent returns the gas paid (in wei) by address on the network for txs in (from, to].
+// txlist also returns txs sent to the address, their gas was paid by the sender.
+func (m *Monitor) GetDbGasSpent(network, address string, from, to int64) (*big.Int, error) {
+	rows, err := m.db.Query(`
+		SELECT gas_used_wei
+		FROM eth_tx_responses
+		WHERE network = ? AND from_address = ? AND timestamp > ? AND timestamp <= ? AND gas_used_wei IS NOT NULL
+	`, network, strings.ToLower(address), from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	// summed in go -- the int64 SUM of sqlite overflows and values above int64 are stored as REAL
+	gas := decimal.Zero
+	for rows.Next() {
+		var wei string
+		if err := rows.Scan(&wei); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		v, err := decimal.NewFromString(wei)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gas used: %w", err)
+		}
+		gas = gas.Add(v)
+	}
+	return gas.BigInt(), rows.Err()
+}
+
+// GetDbTxValueTotals returns the summed values (in wei) of txs sent to and from address on the network for txs in (from, to].
+// Txs stored before the value was tracked count as 0.
+func (m *Monitor) GetDbTxValueTotals(network, address string, from, to int64) (*big.Int, *big.Int, error) {
+	address = strings.ToLower(address)
+	rows, err := m.db.Query(`
+		SELECT from_address, to_address, value
+		FROM eth_tx_responses
+		WHERE network = ? AND (from_address = ? OR to_address = ?) AND timestamp > ? AND timestamp <= ? AND value IS NOT NULL
+	`, network, address, address, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	// values are uint256 strings so they are summed in go
+	in, out := new(big.Int), new(big.Int)
+	for rows.Next() {
+		var fromAddress, toAddress sql.NullString
+		var value string
+		if err := rows.Scan(&fromAddress, &toAddress, &value); err != nil {
+			return nil, nil, fmt.Errorf("scan error: %w", err)
+		}
+		v, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("failed to parse tx value: %s", value)
+		}
+		if toAddress.String == address {
+			in.Add(in, v)
+		}
+		if fromAddress.String == address {
+			out.Add(out, v)
+		}
+	}
+	return in, out, nil
+}
+
+// GetDbInternalTxTotals returns the summed values (in wei) of successful internal txs to and from
+// the solver on the network for txs in (from, to]
+func (m *Monitor) GetDbInternalTxTotals(network string, from, to int64) (*big.Int, *big.Int, error) {
+	rows, err := m.db.Query(`
+		SELECT direction, value
+		FROM eth_internal_txs
+		WHERE network = ? AND timestamp > ? AND timestamp <= ? AND is_error = 0
+	`, network, from, to)
+	if err != nil {
+		return nil, nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	// values are uint256 strings so they are summed in go
+	in, out := new(big.Int), new(big.Int)
+	for rows.Next() {
+		var direction, value string
+		if err := rows.Scan(&direction, &value); err != nil {
+			return nil, nil, fmt.Errorf("scan error: %w", err)
+		}
+		v, ok := new(big.Int).SetString(value, 10)
+		if !ok {
+			return nil, nil, fmt.Errorf("failed to parse internal tx value: %s", value)
+		}
+		if direction == TRANSFER_DIRECTION_IN {
+			in.Add(in, v)
+		} else {
+			out.Add(out, v)
+		}
+	}
+	return in, out, nil
+}
+
+// GetDbTokenTransferTotals returns summed token transfers to and from the solver on the network
+// for transfers in (from, to]
+func (m *Monitor) GetDbTokenTransferTotals(network, token string, from, to int64) (int64, int64, error) {
+	var in, out int64
+	err := m.db.QueryRow(`
+		SELECT
+			COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE 0 END), 0),
+			COALESCE(SUM(CASE WHEN direction = ? THEN amount ELSE 0 END), 0)
+		FROM token_transfers
+		WHERE network = ? AND token = ? AND timestamp > ? AND timestamp <= ?
+	`, TRANSFER_DIRECTION_IN, TRANSFER_DIRECTION_OUT, network, token, from, to).Scan(&in, &out)
+	if err != nil {
+		return 0, 0, fmt.Errorf("query error: %w", err)
+	}
+	return in, out, nil
+}
+
+// GetDbAttributedRevenue returns the revenue of orders filled by the filler with the given source domain in (from, to]
+func (m *Monitor) GetDbAttributedRevenue(filler, sourceDomain string, from, to int64) (int64, error) {
+	var revenue int64
+	err := m.db.QueryRow(`
+		SELECT COALESCE(SUM(t.solver_revenue), 0)
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		WHERE t.filler = ? AND t.source_domain = ? AND t.code = 0 AND b.timestamp > ? AND b.timestamp <= ?
+	`, filler, sourceDomain, from, to).Scan(&revenue)
+	if err != nil {
+		return 0, fmt.Errorf("query error: %w", err)
+	}
+	return revenue, nil
+}
+
+// GetDbUnlinkedOrders returns successful fills of the filler with the source domain that have no linked EVM tx.
+// Fills ingested before the order details were stored and fills without a known block time are skipped.
+func (m *Monitor) GetDbUnlinkedOrders(filler, sourceDomain string, since int64) ([]LinkableOrder, error) {
+	rows, err := m.db.Query(`
+		SELECT t.tx_hash, t.nonce, COALESCE(t.recipient, ''), b.timestamp
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		LEFT JOIN order_evm_links l ON l.order_tx_hash = t.tx_hash AND l.nonce = t.nonce
+		WHERE t.filler = ? AND t.source_domain = ? AND t.code = 0 AND t.recipient != ''
+			AND b.timestamp >= ? AND l.order_tx_hash IS NULL
+		ORDER BY b.timestamp
+	`, filler, sourceDomain, since)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []LinkableOrder{}
+	for rows.Next() {
+		var o LinkableOrder
+		if err := rows.Scan(&o.TxHash, &o.Nonce, &o.Recipient, &o.Timestamp); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// GetDbSolverEvmTxs returns the txs sent by the address on the network in [from, to] sorted by timestamp.
+// Rows stored before calldata was tracked fall back to the raw tx response if it was saved.
+func (m *Monitor) GetDbSolverEvmTxs(network, address string, from, to int64) ([]LinkableEvmTx, error) {
+	address = strings.ToLower(address)
+	rows, err := m.db.Query(`
+		SELECT tx_hash, timestamp, COALESCE(from_address, ''), COALESCE(input, ''), COALESCE(tx_response, '')
+		FROM eth_tx_responses
+		WHERE network = ? AND timestamp >= ? AND timestamp <= ?
+			AND (from_address = ? OR from_address IS NULL OR from_address = '')
+		ORDER BY timestamp
+	`, network, from, to, address)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	txs := []LinkableEvmTx{}
+	for rows.Next() {
+		var tx LinkableEvmTx
+		var fromAddress string
+		var rawResponse []byte
+		if err := rows.Scan(&tx.TxHash, &tx.Timestamp, &fromAddress, &tx.Input, &rawResponse); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		if fromAddress == "" {
+			details := EthTxDetails{}
+			if len(rawResponse) == 0 || json.Unmarshal(rawResponse, &details) != nil {
+				continue
+			}
+			fromAddress = strings.ToLower(details.From)
+			tx.Input = strings.ToLower(details.Input)
+		}
+		if fromAddress != address {
+			continue
+		}
+		txs = append(txs, tx)
+	}
+	return txs, nil
+}
+
+// GetDbOrderPnl returns successful fills of the filler in [from, to) with the gas of the linked EVM tx.
+// Gas of txs linked to multiple orders is split evenly. Amounts are returned as integer strings (gas in USD).
+func (m *Monitor) GetDbOrderPnl(filler, sourceDomain string, from, to int64) ([]OrderPnl, error) {
+	rows, err := m.db.Query(`
+		SELECT
+			t.tx_hash,
+			COALESCE(t.nonce, 0),
+			t.source_domain,
+			t.height,
+			b.timestamp,
+			t.amount_in,
+			t.amount_out,
+			t.solver_revenue,
+			COALESCE(l.eth_tx_hash, ''),
+			COALESCE(l.matched_by, ''),
+			COALESCE(e.gas_used_usd / (
+				SELECT COUNT(*) FROM order_evm_links l2
+				WHERE l2.network = l.network AND l2.eth_tx_hash = l.eth_tx_hash
+			), 0)
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		LEFT JOIN order_evm_links l ON l.order_tx_hash = t.tx_hash AND l.nonce = t.nonce
+		LEFT JOIN eth_tx_responses e ON e.network = l.network AND e.tx_hash = l.eth_tx_hash
+		WHERE t.filler = ? AND t.code = 0 AND (? = '' OR t.source_domain = ?)
+			AND b.timestamp >= ? AND b.timestamp < ?
+		ORDER BY b.timestamp
+	`, filler, sourceDomain, sourceDomain, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []OrderPnl{}
+	for rows.Next() {
+		var o OrderPnl
+		var sourceDomain string
+		var revenue int64
+		var gasUsd float64
+		err := rows.Scan(&o.TxHash, &o.Nonce, &sourceDomain, &o.Height, &o.Timestamp, &o.AmountIn, &o.AmountOut,
+			&revenue, &o.EthTxHash, &o.MatchedBy, &gasUsd)
+		if err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		o.Network = ChainIdToNetwork[sourceDomain]
+		o.SolverRevenue = strconv.FormatInt(revenue, 10)
+		o.GasUsedUsd = decimal.NewFromFloat(gasUsd).String()
+		orders = append(orders, o)
+	}
+	return orders, nil
+}
+
+// GetDbSettlementStats aggregates the successful fills of the filler per source domain by whether settlement was initiated.
+// Only fills with an order ID from the order_filled event are counted. The settlement lag is the time between the
+// fill and the first successful initiate_settlement of the order and relies on osmo_block_times for both heights.
+func (m *Monitor) GetDbSettlementStats(filler string) ([]SettlementStats, error) {
+	rows, err := m.db.Query(`
+		WITH settlements AS (
+			SELECT order_id, MIN(height) AS height
+			FROM order_actions
+			WHERE action = ? AND code = 0
+			GROUP BY order_id
+		)
+		SELECT
+			t.source_domain,
+			COUNT(*),
+			COUNT(s.order_id),
+			COALESCE(SUM(CASE WHEN s.order_id IS NULL THEN CAST(t.amount_out AS INTEGER) END), 0),
+			AVG(sb.timestamp - fb.timestamp),
+			MAX(sb.timestamp - fb.timestamp)
+		FROM tx_data t
+		LEFT JOIN settlements s ON s.order_id = t.order_id
+		LEFT JOIN osmo_block_times fb ON fb.height = t.height
+		LEFT JOIN osmo_block_times sb ON sb.height = s.height
+		WHERE t.filler = ? AND t.code = 0 AND t.order_id IS NOT NULL AND t.order_id != ''
+		GROUP BY t.source_domain
+		ORDER BY t.source_domain
+	`, CONTRACT_ACTION_INITIATE_SETTLEMENT, filler)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	stats := []SettlementStats{}
+	for rows.Next() {
+		var s SettlementStats
+		var unsettledAmount int64
+		var avgLag sql.NullFloat64
+		var maxLag sql.NullInt64
+		if err := rows.Scan(&s.SourceDomain, &s.FilledOrders, &s.SettlementInitiated, &unsettledAmount, &avgLag, &maxLag); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		s.Network = ChainIdToNetwork[s.SourceDomain]
+		s.UnsettledOrders = s.FilledOrders - s.SettlementInitiated
+		s.UnsettledAmount = strconv.FormatInt(unsettledAmount, 10)
+		s.AvgSettlementLagSeconds = int64(avgLag.Float64)
+		s.MaxSettlementLagSeconds = maxLag.Int64
+		stats = append(stats, s)
+	}
+	return stats, rows.Err()
+}
+
+// GetDbPendingSettlements returns the successful initiate_settlement txs per source domain and repayment address
+// with orders of the filler that are not settled yet. Initiations without a known block time are skipped.
+func (m *Monitor) GetDbPendingSettlements(filler string) ([]PendingSettlement, error) {
+	rows, err := m.db.Query(`
+		SELECT a.tx_hash, t.source_domain, a.repayment_address, b.timestamp, SUM(CAST(t.amount_in AS INTEGER)), GROUP_CONCAT(t.order_id)
+		FROM order_actions a
+		JOIN tx_data t ON t.order_id = a.order_id AND t.code = 0
+		JOIN osmo_block_times b ON b.height = a.height
+		LEFT JOIN order_settlements s ON s.order_id = a.order_id
+		WHERE a.action = ? AND a.code = 0 AND t.filler = ? AND s.order_id IS NULL
+		GROUP BY a.tx_hash, t.source_domain, a.repayment_address, b.timestamp
+		ORDER BY b.timestamp
+	`, CONTRACT_ACTION_INITIATE_SETTLEMENT, filler)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	pending := []PendingSettlement{}
+	for rows.Next() {
+		var p PendingSettlement
+		var orderIds string
+		if err := rows.Scan(&p.TxHash, &p.SourceDomain, &p.RepaymentAddress, &p.Timestamp, &p.AmountIn, &orderIds); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		p.OrderIds = splitOrderIds(orderIds)
+		pending = append(pending, p)
+	}
+	return pending, rows.Err()
+}
+
+// GetDbRepaymentTransfer returns the first incoming USDC transfer on the network with the amount at or after the timestamp
+// that does not settle other orders yet
+func (m *Monitor) GetDbRepaymentTransfer(network string, amount, since int64) (DbTokenTransfer, bool, error) {
+	var t DbTokenTransfer
+	err := m.db.QueryRow(`
+		SELECT tt.tx_hash, tt.network, tt.amount, tt.timestamp
+		FROM token_transfers tt
+		LEFT JOIN order_settlements s ON s.network = tt.network AND s.repayment_tx_hash = tt.tx_hash
+		WHERE tt.network = ? AND tt.token = 'USDC' AND tt.direction = ? AND tt.amount = ? AND tt.timestamp >= ?
+			AND s.order_id IS NULL
+		ORDER BY tt.timestamp
+		LIMIT 1
+	`, network, TRANSFER_DIRECTION_IN, amount, since).Scan(&t.TxHash, &t.Network, &t.Amount, &t.Timestamp)
+	if errors.Is(err, sql.ErrNoRows) {
+		return t, false, nil
+	}
+	if err != nil {
+		return t, false, fmt.Errorf("query error: %w", err)
+	}
+	return t, true, nil
+}
+
+// GetDbOutstandingFills returns the successful fills of the filler that are not settled.
+// Fills without a known block time are always included, the others only if they were filled at or after since.
+func (m *Monitor) GetDbOutstandingFills(filler string, since int64) ([]OutstandingFill, error) {
+	rows, err := m.db.Query(`
+		SELECT
+			t.source_domain,
+			CAST(t.amount_out AS INTEGER),
+			COALESCE(b.timestamp, 0),
+			EXISTS (SELECT 1 FROM order_actions a WHERE a.order_id = t.order_id AND a.action = ? AND a.code = 0)
+		FROM tx_data t
+		LEFT JOIN osmo_block_times b ON b.height = t.height
+		LEFT JOIN order_settlements s ON s.order_id = t.order_id
+		WHERE t.filler = ? AND t.code = 0 AND t.order_id IS NOT NULL AND t.order_id != ''
+			AND s.order_id IS NULL AND (b.timestamp IS NULL OR b.timestamp >= ?)
+	`, CONTRACT_ACTION_INITIATE_SETTLEMENT, filler, since)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	fills := []OutstandingFill{}
+	for rows.Next() {
+		var f OutstandingFill
+		if err := rows.Scan(&f.SourceDomain, &f.AmountOut, &f.Timestamp, &f.SettlementInitiated); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+// GetDbMarketFills returns the fills of every filler in [from, to), optionally only with the source domain
+func (m *Monitor) GetDbMarketFills(sourceDomain string, from, to int64) ([]MarketFill, error) {
+	query := `
+		SELECT t.filler, t.source_domain, CAST(t.amount_in AS INTEGER), date(b.timestamp, 'unixepoch'), t.code = 0
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		WHERE b.timestamp >= ? AND b.timestamp < ?`
+	args := []interface{}{from, to}
+	if sourceDomain != "" {
+		query += " AND t.source_domain = ?"
+		args = append(args, sourceDomain)
+	}
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	fills := []MarketFill{}
+	for rows.Next() {
+		var f MarketFill
+		if err := rows.Scan(&f.Filler, &f.SourceDomain, &f.AmountIn, &f.Day, &f.Success); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		fills = append(fills, f)
+	}
+	return fills, rows.Err()
+}
+
+// GetDbOrdersWithBalance returns the successful fills of other fillers in [from, to) with the last USDC balance
+// of the address on the network at the block time of the fill
+func (m *Monitor) GetDbOrdersWithBalance(filler, network, address string, from, to int64) ([]OrderWithBalance, error) {
+	rows, err := m.db.Query(`
+		SELECT
+			t.tx_hash, COALESCE(t.nonce, 0), t.filler, t.source_domain, t.amount_in, t.amount_out, t.solver_revenue, b.timestamp,
+			(SELECT bl.balance FROM balances bl
+				WHERE bl.address = ? AND bl.token = 'USDC' AND bl.network = ? AND bl.timestamp <= b.timestamp
+				ORDER BY bl.timestamp DESC LIMIT 1),
+			(SELECT bl.timestamp FROM balances bl
+				WHERE bl.address = ? AND bl.token = 'USDC' AND bl.network = ? AND bl.timestamp <= b.timestamp
+				ORDER BY bl.timestamp DESC LIMIT 1)
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		WHERE t.filler != ? AND t.code = 0 AND b.timestamp >= ? AND b.timestamp < ?
+		ORDER BY b.timestamp DESC
+	`, address, network, address, network, filler, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	orders := []OrderWithBalance{}
+	for rows.Next() {
+		var o OrderWithBalance
+		var balance sql.NullString
+		var balanceTimestamp sql.NullInt64
+		if err := rows.Scan(&o.TxHash, &o.Nonce, &o.Filler, &o.SourceDomain, &o.AmountIn, &o.AmountOut, &o.SolverRevenue,
+			&o.Timestamp, &balance, &balanceTimestamp); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		o.Balance = balance.String
+		o.BalanceTimestamp = balanceTimestamp.Int64
+		orders = append(orders, o)
+	}
+	return orders, rows.Err()
+}
+
+// GetDbFillTimeseries sums the successful fills of the filler in [from, to) per source domain and bucket.
+// Buckets are size seconds long and start at offset seconds from unix time 0.
+func (m *Monitor) GetDbFillTimeseries(filler string, size, offset, from, to int64) ([]TimeseriesPoint, error) {
+	rows, err := m.db.Query(`
+		SELECT
+			t.source_domain,
+			(b.timestamp - ?) / ? * ? + ? AS bucket_start,
+			COUNT(*),
+			COALESCE(SUM(CAST(t.amount_in AS INTEGER)), 0),
+			COALESCE(SUM(t.solver_revenue), 0)
+		FROM tx_data t
+		JOIN osmo_block_times b ON t.height = b.height
+		WHERE t.filler = ? AND t.code = 0 AND b.timestamp >= ? AND b.timestamp < ?
+		GROUP BY t.source_domain, bucket_start
+		ORDER BY t.source_domain, bucket_start
+	`, offset, size, size, offset, filler, from, to)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	points := []TimeseriesPoint{}
+	for rows.Next() {
+		var p TimeseriesPoint
+		if err := rows.Scan(&p.SourceDomain, &p.BucketStart, &p.Orders, &p.Volume, &p.Revenue); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		points = append(points, p)
+	}
+	return points, rows.Err()
+}
@@ -14,6 +14,7 @@ var ChainIdToNetwork = map[string]string{
 	"8453":      "base",
 	"56":        "bnb",
 	"1":         "ethereum",
+	"10":        "optimism",
 	"137":       "polygon",
 	"osmosis-1": "osmosis",
 }
@@ -24,6 +25,7 @@ var NetworkToChainId = map[string]string{
 	"base":      "8453",
 	"bnb":       "56",
 	"ethereum":  "1",
+	"optimism":  "10",
 	"polygon":   "137",
 	"osmosis":   "osmosis-1",
 }
@@ -79,6 +81,56 @@ type FillStatsResponse struct {
 	MaxRevenueOrders []MaxFillOrderResponse `json:"max_revenue_details"`
 }
 
+type TokenFlow struct {
+	Network       string `json:"network"`
+	Day           string `json:"day"`
+	Inflow        string `json:"inflow"`
+	Outflow       string `json:"outflow"`
+	NetFlow       string `json:"net_flow"`
+	TransferCount int64  `json:"transfer_count"`
+}
+
+// GetDbTokenFlows returns daily inflows and outflows of the token per network.
+// Amounts are returned as integer strings.
+func (m *Monitor) GetDbTokenFlows(token, network string, from, to time.Time) ([]TokenFlow, error) {
+	query := `
+		SELECT
+			network,
+			strftime('%Y-%m-%d', timestamp, 'unixepoch') as day,
+			SUM(CASE WHEN direction = ? THEN amount ELSE 0 END) as inflow,
+			SUM(CASE WHEN direction = ? THEN amount ELSE 0 END) as outflow,
+			COUNT(*) as transfer_count
+		FROM token_transfers
+		WHERE token = ? AND timestamp >= ? AND timestamp < ?
+	`
+	args := []interface{}{TRANSFER_DIRECTION_IN, TRANSFER_DIRECTION_OUT, token, from.Unix(), to.Unix()}
+	if network != "" {
+		query += " AND network = ?"
+		args = append(args, network)
+	}
+	query += " GROUP BY network, day ORDER BY network, day"
+
+	rows, err := m.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("query error: %w", err)
+	}
+	defer rows.Close()
+
+	flows := []TokenFlow{}
+	for rows.Next() {
+		var f TokenFlow
+		var inflow, outflow int64
+		if err := rows.Scan(&f.Network, &f.Day, &inflow, &outflow, &f.TransferCount); err != nil {
+			return nil, fmt.Errorf("scan error: %w", err)
+		}
+		f.Inflow = strconv.FormatInt(inflow, 10)
+		f.Outflow = strconv.FormatInt(outflow, 10)
+		f.NetFlow = strconv.FormatInt(inflow-outflow, 10)
+		flows = append(flows, f)
+	}
+	return flows, nil
+}
+
 func (m *Monitor) GetLatestUsdTokenPrice(token string) (float64, error) {
 	rows, err := m.db.Query(`
 		SELECT price_usd
@@ -0,0 +1,190 @@
+package monitor
+
+import (
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// circuit breaker states of an endpoint
+const (
+	ENDPOINT_STATE_CLOSED = "closed"
+	// too many failures -- the endpoint is not used until the cooldown passed
+	ENDPOINT_STATE_OPEN = "open"
+	// the cooldown passed -- a single trial request decides whether the endpoint is closed or opened again
+	ENDPOINT_STATE_HALF_OPEN = "half_open"
+)
+
+// weight of the latest request in the latency and error rate averages
+const endpointEwmaWeight = 0.2
+
+type CircuitBreakerConfig struct {
+	// consecutive failures that open the breaker, a rate limited request opens it right away
+	FailureThreshold int
+	Cooldown         time.Duration
+}
+
+var DefaultCircuitBreakerConfig = CircuitBreakerConfig{
+	FailureThreshold: 3,
+	Cooldown:         time.Minute,
+}
+
+type EndpointStatus struct {
+	Url                 string `json:"url"`
+	State               string `json:"state"`
+	Requests            int    `json:"requests"`
+	Failures            int    `json:"failures"`
+	ConsecutiveFailures int    `json:"consecutive_failures"`
+	// moving averages, latency only of successful requests
+	AvgLatencyMs float64   `json:"avg_latency_ms"`
+	ErrorRate    float64   `json:"error_rate"`
+	LastError    string    `json:"last_error,omitempty"`
+	OpenUntil    time.Time `json:"open_until,omitempty"`
+	// a half open trial request is in progress
+	trial bool
+}
+
+// score ranks endpoints by health, lower is better. Endpoints without requests rank first.
+func (e *EndpointStatus) score() float64 {
+	return e.AvgLatencyMs * (1 + 4*e.ErrorRate)
+}
+
+// EndpointPool tracks the health of interchangeable endpoints and guards each of them with a circuit breaker
+type EndpointPool struct {
+	mu        sync.Mutex
+	endpoints []*EndpointStatus
+	breaker   CircuitBreakerConfig
+	// replaced in tests
+	now func() time.Time
+}
+
+func NewEndpointPool(urls []string, breaker CircuitBreakerConfig) *EndpointPool {
+	p := &EndpointPool{breaker: breaker, now: time.Now}
+	for _, url := range urls {
+		p.endpoints = append(p.endpoints, &EndpointStatus{Url: url, State: ENDPOINT_STATE_CLOSED})
+	}
+	return p
+}
+
+func (p *EndpointPool) Urls() []string {
+	urls := []string{}
+	for _, e := range p.endpoints {
+		urls = append(urls, e.Url)
+	}
+	return urls
+}
+
+func (p *EndpointPool) endpoint(url string) *EndpointStatus {
+	for _, e := range p.endpoints {
+		if e.Url == url {
+			return e
+		}
+	}
+	return nil
+}
+
+// allow must be called with the lock held
+func (p *EndpointPool) allow(e *EndpointStatus) (bool, time.Duration) {
+	switch e.State {
+	case ENDPOINT_STATE_OPEN:
+		if wait := e.OpenUntil.Sub(p.now()); wait > 0 {
+			return false, wait
+		}
+		e.State = ENDPOINT_STATE_HALF_OPEN
+		e.trial = true
+		return true, 0
+	case ENDPOINT_STATE_HALF_OPEN:
+		if e.trial {
+			// wait for the result of the trial request
+			return false, p.breaker.Cooldown / 10
+		}
+		e.trial = true
+		return true, 0
+	}
+	return true, 0
+}
+
+// Allow reports whether a request can be sent to the endpoint. If not, it returns how long to wait before asking again.
+// Every allowed request has to be reported.
+func (p *EndpointPool) Allow(url string) (bool, time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoint(url)
+	if e == nil {
+		return false, 0
+	}
+	return p.allow(e)
+}
+
+// Available reports whether an endpoint other than the excluded urls would accept a request now
+func (p *EndpointPool) Available(exclude ...string) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, e := range p.endpoints {
+		if slices.Contains(exclude, e.Url) {
+			continue
+		}
+		if e.State == ENDPOINT_STATE_OPEN && e.OpenUntil.After(p.now()) || e.State == ENDPOINT_STATE_HALF_OPEN && e.trial {
+			continue
+		}
+		return true
+	}
+	return false
+}
+
+// Report records the result of a request to the endpoint and opens or closes its breaker
+func (p *EndpointPool) Report(url string, latency time.Duration, err error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	e := p.endpoint(url)
+	if e == nil {
+		return
+	}
+
+	e.Requests++
+	e.trial = false
+	if err == nil {
+		e.ErrorRate = e.ErrorRate * (1 - endpointEwmaWeight)
+		latencyMs := float64(latency.Microseconds()) / 1000
+		if e.AvgLatencyMs == 0 {
+			e.AvgLatencyMs = latencyMs
+		} else {
+			e.AvgLatencyMs = e.AvgLatencyMs*(1-endpointEwmaWeight) + latencyMs*endpointEwmaWeight
+		}
+		e.ConsecutiveFailures = 0
+		e.State = ENDPOINT_STATE_CLOSED
+		e.OpenUntil = time.Time{}
+		return
+	}
+
+	e.ErrorRate = e.ErrorRate*(1-endpointEwmaWeight) + endpointEwmaWeight
+	e.Failures++
+	e.ConsecutiveFailures++
+	e.LastError = err.Error()
+	if e.State == ENDPOINT_STATE_HALF_OPEN || e.ConsecutiveFailures >= p.breaker.FailureThreshold || errors.Is(err, RateLimitErr) {
+		e.State = ENDPOINT_STATE_OPEN
+		e.OpenUntil = p.now().Add(p.breaker.Cooldown)
+	}
+}
+
+// Release gives up an allowed request without a result, e.g. because it was cancelled. It isn't counted and a half open
+// endpoint accepts a new trial request.
+func (p *EndpointPool) Release(url string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if e := p.endpoint(url); e != nil {
+		e.trial = false
+	}
+}
+
+// Status returns a copy of the endpoint states
+func (p *EndpointPool) Status() []EndpointStatus {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	status := []EndpointStatus{}
+	for _, e := range p.endpoints {
+		status = append(status, *e)
+	}
+	return status
+}
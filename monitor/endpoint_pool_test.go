@@ -0,0 +1,68 @@
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEndpointPoolCircuitBreaker(t *testing.T) {
+	now := time.Date(2025, 3, 19, 12, 0, 0, 0, time.UTC)
+	p := NewEndpointPool([]string{"a", "b"}, CircuitBreakerConfig{FailureThreshold: 2, Cooldown: time.Minute})
+	p.now = func() time.Time { return now }
+
+	failed := errors.New("failed")
+	for i := 0; i < 2; i++ {
+		ok, _ := p.Allow("a")
+		require.True(t, ok)
+		p.Report("a", 0, failed)
+	}
+	ok, wait := p.Allow("a")
+	assert.False(t, ok)
+	assert.Equal(t, time.Minute, wait)
+	// open endpoints are not available
+	assert.False(t, p.Available("b"))
+	assert.True(t, p.Available())
+	ok, _ = p.Allow("b")
+	require.True(t, ok)
+	p.Report("b", 10*time.Millisecond, nil)
+
+	// a single trial request after the cooldown, a failed trial opens the breaker again
+	now = now.Add(time.Minute)
+	ok, _ = p.Allow("a")
+	require.True(t, ok)
+	ok, _ = p.Allow("a")
+	assert.False(t, ok)
+	p.Report("a", 0, failed)
+	assert.Equal(t, ENDPOINT_STATE_OPEN, p.Status()[0].State)
+
+	now = now.Add(time.Minute)
+	assert.True(t, p.Available("b"))
+	ok, _ = p.Allow("a")
+	require.True(t, ok)
+	assert.Equal(t, ENDPOINT_STATE_HALF_OPEN, p.Status()[0].State)
+	// until the trial request is reported
+	assert.False(t, p.Available("b"))
+	p.Report("a", 50*time.Millisecond, nil)
+
+	status := p.Status()
+	assert.Equal(t, ENDPOINT_STATE_CLOSED, status[0].State)
+	assert.Equal(t, 4, status[0].Requests)
+	assert.Equal(t, 3, status[0].Failures)
+	assert.Zero(t, status[0].ConsecutiveFailures)
+	assert.Equal(t, "failed", status[0].LastError)
+
+	// the faster endpoint without errors ranks first
+	assert.Less(t, status[1].score(), status[0].score())
+
+	// a rate limited request opens the breaker right away
+	ok, _ = p.Allow("b")
+	require.True(t, ok)
+	p.Report("b", 0, RateLimitErr)
+	ok, _ = p.Allow("b")
+	assert.False(t, ok)
+	assert.False(t, p.Available("a"))
+}
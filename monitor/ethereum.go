@@ -1,7 +1,10 @@
 package monitor
 
 import (
+	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"math/big"
@@ -63,172 +66,154 @@ type EthBalanceResponse struct {
 	Result  string `json:"result"` // token balance -> 18 decimals for ETH, 6 decimals for USDC
 }
 
-func (m *Monitor) RunBaseTxHistory(saveRawResponses bool) {
-	apiUrl := m.cfg.Base.ApiUrl
-	address := m.cfg.Base.Address
-	apiKey := m.cfg.Base.Key
+type etherscanAdapter struct {
+	m     *Monitor
+	chain ChainConfig
+}
 
-	txs, err := m.getEthereumTxs(apiUrl, address, apiKey, BASE_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get BASE txs")
-		return
-	}
-	latestHeight, err := m.GetLatestEthHeight(BASE_NETWORK)
-	if err != nil {
-		m.logger.Warn().Msg("failed to get latest BASE height -- starting from 0")
-	}
+func newEtherscanAdapter(m *Monitor, chain ChainConfig) ChainAdapter {
+	return &etherscanAdapter{m: m, chain: chain}
+}
 
-	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(COINGECKO_ETHEREUM_ID)
-	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get latest USD token price")
-		return
-	}
+func (a *etherscanAdapter) Network() string {
+	return a.chain.Name
+}
 
-	inserted := 0
-	failed := 0
-	for _, tx := range txs {
-		height, err := strconv.ParseInt(tx.BlockNumber, 10, 64)
-		if err != nil {
-			m.logger.Error().Err(err).Msg("failed to parse block number")
-			continue
-		}
-		if height <= latestHeight {
-			continue
-		}
+func (a *etherscanAdapter) RunBalances(ctx context.Context) error {
+	return a.m.RunEtherscanBalances(ctx, a.chain)
+}
 
-		// just report the error if it happens
-		// this will return zero decimal if there is an error so it's ok
-		gasUsedUsd, err := calculateGasUSD(priceUsd, tx.GasUsed, tx.GasPrice)
-		if err != nil {
-			m.logger.Error().Err(err).
-				Str("tx_hash", tx.Hash).
-				Str("block_number", tx.BlockNumber).
-				Str("network", BASE_NETWORK).
-				Msg("failed to calculate gas used USD")
-		}
+// the steps are independent -- a failed step doesn't stop the following ones
+func (a *etherscanAdapter) RunTxHistory(ctx context.Context, saveRawResponses bool) error {
+	return errors.Join(
+		a.m.RunEtherscanTxHistory(ctx, a.chain, saveRawResponses),
+		a.m.RunEtherscanInternalTxs(ctx, a.chain),
+		a.m.RunEtherscanTokenTransfers(ctx, a.chain),
+		a.m.RunOrderLinking(ctx, a.chain),
+	)
+}
 
-		tx.GasUsedUsd = gasUsedUsd.String()
-		tx.Network = BASE_NETWORK
-		if err := m.InsertEthTxResponse(tx, BASE_NETWORK, saveRawResponses); err != nil {
-			m.logger.Error().Err(err).
-				Str("tx_hash", tx.Hash).
-				Str("block_number", tx.BlockNumber).
-				Str("network", BASE_NETWORK).
-				Msg("failed to insert BASE tx")
-			failed++
-			continue
-		}
-		inserted++
+func (m *Monitor) RunEtherscanTxHistory(ctx context.Context, chain ChainConfig, saveRawResponses bool) error {
+	startBlock := m.getEtherscanStartBlock(chain.Name, ETHERSCAN_ACTION_TXLIST)
+	if err := m.syncEtherscanTxs(ctx, chain, ETHERSCAN_ACTION_TXLIST, startBlock, saveRawResponses); err != nil {
+		return fmt.Errorf("failed to get txs: %w", err)
 	}
-
-	totalGasUsed := m.getGasUsedForTxs(txs)
-	m.logger.Info().Int("total", len(txs)).
-		Int("new", inserted).
-		Int("failed", failed).
-		Str("total_gas_used_eth", decimal.NewFromBigInt(totalGasUsed, -18).String()).
-		Msg("finished processing BASE txs history")
+	return nil
 }
 
-func (m *Monitor) RunArbitrumTxHistory(saveRawResponses bool) {
-	apiUrl := m.cfg.Arbitrum.ApiUrl
-	address := m.cfg.Arbitrum.Address
-	apiKey := m.cfg.Arbitrum.Key
+// BackfillEtherscanTxHistory pages through the txlist history of the chain address starting at fromBlock
+// and stores txs that are not in the db yet. Windows are stored as they are fetched so an interrupted
+// backfill can be resumed.
+// If fromBlock is negative the backfill resumes from its own cursor or starts at block 0 -- the cursor of the
+// incremental sync is at the tip and would skip the whole history.
+func (m *Monitor) BackfillEtherscanTxHistory(ctx context.Context, chain ChainConfig, fromBlock int64, saveRawResponses bool) error {
+	if fromBlock < 0 {
+		cursor, err := m.GetEthCursor(chain.Name, ETHERSCAN_CURSOR_TXLIST_BACKFILL)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return fmt.Errorf("failed to get backfill cursor: %w", err)
+		}
+		fromBlock = cursor
+	}
+	return m.syncEtherscanTxs(ctx, chain, ETHERSCAN_CURSOR_TXLIST_BACKFILL, fromBlock, saveRawResponses)
+}
 
-	txs, err := m.getEthereumTxs(apiUrl, address, apiKey, ARBITRUM_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get arbitrum txs")
-		return
+// getEtherscanStartBlock returns the block to resume fetching from.
+// Falls back to the latest stored tx height for dbs created before cursors were tracked.
+func (m *Monitor) getEtherscanStartBlock(network, action string) int64 {
+	cursor, err := m.GetEthCursor(network, action)
+	if err == nil {
+		return cursor
 	}
-	latestHeight, err := m.GetLatestEthHeight(ARBITRUM_NETWORK)
-	if err != nil {
-		m.logger.Warn().Msg("failed to get latest arbitrum height -- starting from 0")
+	if !errors.Is(err, sql.ErrNoRows) {
+		m.logger.Error().Err(err).Str("network", network).Str("action", action).Msg("failed to get cursor")
 	}
 
-	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(COINGECKO_ETHEREUM_ID)
-	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get latest USD token price")
-		return
+	if action != ETHERSCAN_ACTION_TXLIST {
+		return 0
 	}
-
-	inserted := 0
-	failed := 0
-	for _, tx := range txs {
-		height, err := strconv.ParseInt(tx.BlockNumber, 10, 64)
-		if err != nil {
-			m.logger.Error().Err(err).Msg("failed to parse block number")
-			continue
-		}
-		if height <= latestHeight {
-			continue
-		}
-
-		// just report the error if it happens
-		// this will return zero decimal if there is an error so it's ok
-		gasUsedUsd, err := calculateGasUSD(priceUsd, tx.GasUsed, tx.GasPrice)
-		if err != nil {
-			m.logger.Error().Err(err).
-				Str("tx_hash", tx.Hash).
-				Str("block_number", tx.BlockNumber).
-				Str("network", ARBITRUM_NETWORK).
-				Msg("failed to calculate gas used USD")
-		}
-
-		tx.GasUsedUsd = gasUsedUsd.String()
-
-		tx.Network = ARBITRUM_NETWORK
-		if err := m.InsertEthTxResponse(tx, ARBITRUM_NETWORK, saveRawResponses); err != nil {
-			m.logger.Error().Err(err).
-				Str("tx_hash", tx.Hash).
-				Str("block_number", tx.BlockNumber).
-				Str("network", ARBITRUM_NETWORK).
-				Msg("failed to insert arbitrum tx")
-			failed++
-			continue
-		}
-		inserted++
+	latestHeight, err := m.GetLatestEthHeight(network)
+	if err != nil {
+		m.logger.Warn().Str("network", network).Msg("failed to get latest height -- starting from 0")
 	}
-
-	totalGasUsed := m.getGasUsedForTxs(txs)
-	m.logger.Info().Int("total", len(txs)).
-		Int("new", inserted).
-		Int("failed", failed).
-		Str("total_gas_used_eth", decimal.NewFromBigInt(totalGasUsed, -18).String()).
-		Msg("finished processing ARBITRUM txs history")
+	return latestHeight
 }
 
-func (m *Monitor) RunEthereumTxHistory(saveRawResponses bool) {
-	apiUrl := m.cfg.Ethereum.ApiUrl
-	address := m.cfg.Ethereum.Address
-	apiKey := m.cfg.Ethereum.Key
+// syncEtherscanTxs fetches txs starting at startBlock (inclusive) and stores the ones not in the db yet.
+// The cursor stored under cursorName is advanced after every stored window.
+func (m *Monitor) syncEtherscanTxs(ctx context.Context, chain ChainConfig, cursorName string, startBlock int64, saveRawResponses bool) error {
+	network := chain.Name
 
-	txs, err := m.getEthereumTxs(apiUrl, address, apiKey, ETHEREUM_CHAIN_ID)
+	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(chain.CoingeckoId)
 	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get ethereum txs")
-		return
-	}
-	latestHeight, err := m.GetLatestEthHeight(ETHEREUM_NETWORK)
-	if err != nil {
-		m.logger.Warn().Msg("failed to get latest ethereum height -- starting from 0")
+		return fmt.Errorf("failed to get latest USD token price: %w", err)
 	}
 
-	priceUsd, err := m.GetLatestUsdTokenPriceDecimal(COINGECKO_ETHEREUM_ID)
+	// the start block is re-fetched on every run so txs already stored from it need to be skipped
+	known, err := m.GetEthTxHashes(network, startBlock)
 	if err != nil {
-		m.logger.Error().Err(err).Msg("failed to get latest USD token price")
-		return
-	}
+		return fmt.Errorf("failed to get stored tx hashes: %w", err)
+	}
+
+	total, totalInserted, totalFailed := 0, 0, 0
+	totalGasUsed := new(big.Int)
+	// the cursor is not advanced past the first tx that failed to store so it is fetched again on the next run
+	failedBlock := ""
+	err = walkEtherscanHistory(ctx, m, chain, ETHERSCAN_ACTION_TXLIST, nil, startBlock,
+		func(tx EthTxDetails) string { return tx.BlockNumber },
+		func(txs []EthTxDetails) error {
+			total += len(txs)
+			newTxs := []EthTxDetails{}
+			for _, tx := range txs {
+				if known[tx.Hash] {
+					continue
+				}
+				known[tx.Hash] = true
+				newTxs = append(newTxs, tx)
+			}
+			inserted, failed := m.storeEtherscanTxs(ctx, chain, newTxs, priceUsd, saveRawResponses)
+			totalInserted += inserted
+			totalFailed += len(failed)
+			if len(failed) > 0 && failedBlock == "" {
+				failedBlock = failed[0].BlockNumber
+			}
+			totalGasUsed.Add(totalGasUsed, m.getGasUsedForTxs(newTxs))
+			if len(txs) == 0 {
+				return nil
+			}
+
+			cursorBlock := txs[len(txs)-1].BlockNumber
+			if failedBlock != "" {
+				cursorBlock = failedBlock
+			}
+			lastBlock, err := strconv.ParseInt(cursorBlock, 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse block number: %w", err)
+			}
+			m.logger.Debug().
+				Str("network", network).
+				Int64("to_block", lastBlock).
+				Int("new", inserted).
+				Msg("stored txs window")
+			return m.UpsertEthCursor(ctx, network, cursorName, lastBlock)
+		})
+
+	m.logger.Info().Int("total", total).
+		Int("new", totalInserted).
+		Int("failed", totalFailed).
+		Int64("start_block", startBlock).
+		Str("network", network).
+		Str("total_gas_used", decimal.NewFromBigInt(totalGasUsed, -18).String()).
+		Str("token", chain.NativeToken).
+		Msg("finished processing txs history")
+	return err
+}
 
+// storeEtherscanTxs calculates gas costs in USD and inserts the txs; returns the inserted count and the txs that failed to insert
+func (m *Monitor) storeEtherscanTxs(ctx context.Context, chain ChainConfig, txs []EthTxDetails, priceUsd decimal.Decimal, saveRawResponses bool) (int, []EthTxDetails) {
+	network := chain.Name
 	inserted := 0
-	failed := 0
+	failed := []EthTxDetails{}
 	for _, tx := range txs {
-		height, err := strconv.ParseInt(tx.BlockNumber, 10, 64)
-		if err != nil {
-			m.logger.Error().Err(err).Msg("failed to parse block number")
-			continue
-		}
-		if height <= latestHeight {
-			continue
-		}
-
 		// just report the error if it happens
 		// this will return zero decimal if there is an error so it's ok
 		gasUsedUsd, err := calculateGasUSD(priceUsd, tx.GasUsed, tx.GasPrice)
@@ -236,144 +221,60 @@ func (m *Monitor) RunEthereumTxHistory(saveRawResponses bool) {
 			m.logger.Error().Err(err).
 				Str("tx_hash", tx.Hash).
 				Str("block_number", tx.BlockNumber).
-				Str("network", ETHEREUM_NETWORK).
+				Str("network", network).
 				Msg("failed to calculate gas used USD")
 		}
 
 		tx.GasUsedUsd = gasUsedUsd.String()
-		tx.Network = ETHEREUM_NETWORK
-		if err := m.InsertEthTxResponse(tx, ETHEREUM_NETWORK, saveRawResponses); err != nil {
+		tx.Network = network
+		if err := m.InsertEthTxResponse(ctx, tx, network, saveRawResponses); err != nil {
 			m.logger.Error().Err(err).
 				Str("tx_hash", tx.Hash).
 				Str("block_number", tx.BlockNumber).
-				Str("network", ETHEREUM_NETWORK).
-				Msg("failed to insert ethereum tx")
-			failed++
+				Str("network", network).
+				Msg("failed to insert tx")
+			failed = append(failed, tx)
 			continue
 		}
 		inserted++
 	}
-
-	totalGasUsed := m.getGasUsedForTxs(txs)
-	m.logger.Info().Int("total", len(txs)).
-		Int("new", inserted).
-		Int("failed", failed).
-		Str("total_gas_used_eth", decimal.NewFromBigInt(totalGasUsed, -18).String()).
-		Msg("finished processing ETHEREUM txs history")
+	return inserted, failed
 }
 
 // ethereum balances are handled as strings and stored as strings in the db
 // sqlite cannot store 256 bit integers, so we use strings to get around that
-func (m *Monitor) RunEthereumBalances() int {
-	apiUrl := m.cfg.Ethereum.ApiUrl
-	address := m.cfg.Ethereum.Address
-	apiKey := m.cfg.Ethereum.Key
-	useTs := time.Now()
-
-	ethWei, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, "", ETHEREUM_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", ETHEREUM_NETWORK).
-			Msg("failed to get ETH balance")
-		return httpCode
-	}
-
-	usdc, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, m.cfg.Ethereum.UsdcAddress, ETHEREUM_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", ETHEREUM_NETWORK).
-			Msg("failed to get USDC balance")
-		return httpCode
-	}
-
-	if ethWei != "" {
-		ethBalance := DbBalance{
-			Timestamp: useTs.Unix(),
-			Balance:   ethWei,
-			Exponent:  18,
-			Token:     "ETH",
-			Address:   address,
-			Network:   ETHEREUM_NETWORK,
-		}
-		if err := m.InsertBalance(ethBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", ETHEREUM_NETWORK).Msg("failed to insert balance")
-		}
-
-		if ethDecimal, err := decimal.NewFromString(ethWei); err == nil {
-			m.logger.Info().
-				Str("ETH", ethDecimal.Shift(-18).String()).
-				Str("network", ETHEREUM_NETWORK).
-				Str("datetime", useTs.Format(time.RFC3339)).
-				Msg("current balance")
-		}
-	}
-
-	if usdc != "" {
-		usdcBalance := DbBalance{
-			Timestamp: useTs.Unix(),
-			Balance:   usdc,
-			Exponent:  6,
-			Token:     "USDC",
-			Address:   address,
-			Network:   ETHEREUM_NETWORK,
-		}
-		if err := m.InsertBalance(usdcBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", ETHEREUM_NETWORK).Msg("failed to insert balance")
-		}
-		if usdcDecimal, err := decimal.NewFromString(usdc); err == nil {
-			m.logger.Info().
-				Str("USDC", usdcDecimal.Shift(-6).String()).
-				Str("network", ETHEREUM_NETWORK).
-				Str("datetime", useTs.Format(time.RFC3339)).
-				Msg("current balance")
-		}
-	}
-	return httpCode
-}
-
-func (m *Monitor) RunBaseBalances() int {
-	apiUrl := m.cfg.Base.ApiUrl
-	address := m.cfg.Base.Address
-	apiKey := m.cfg.Base.Key
+func (m *Monitor) RunEtherscanBalances(ctx context.Context, chain ChainConfig) error {
+	network := chain.Name
+	address := chain.Address
 	useTs := time.Now()
 
-	ethWei, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, "", BASE_CHAIN_ID)
+	nativeWei, err := m.getEthereumBalance(ctx, chain.ApiUrl, address, chain.Key, "", chain.ChainId)
 	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", BASE_NETWORK).
-			Msg("failed to get ETH balance")
-		return httpCode
+		return fmt.Errorf("failed to get %s balance: %w", chain.NativeToken, err)
 	}
 
-	usdc, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, m.cfg.Base.UsdcAddress, BASE_CHAIN_ID)
+	usdc, err := m.getEthereumBalance(ctx, chain.ApiUrl, address, chain.Key, chain.UsdcAddress, chain.ChainId)
 	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", BASE_NETWORK).
-			Msg("failed to get USDC balance")
-		return httpCode
+		return fmt.Errorf("failed to get USDC balance: %w", err)
 	}
 
-	if ethWei != "" {
-		ethBalance := DbBalance{
+	if nativeWei != "" {
+		nativeBalance := DbBalance{
 			Timestamp: useTs.Unix(),
-			Balance:   ethWei,
+			Balance:   nativeWei,
 			Exponent:  18,
-			Token:     "ETH",
+			Token:     chain.NativeToken,
 			Address:   address,
-			Network:   BASE_NETWORK,
+			Network:   network,
 		}
-		if err := m.InsertBalance(ethBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", BASE_NETWORK).Msg("failed to insert balance")
+		if err := m.InsertBalance(ctx, nativeBalance); err != nil {
+			return fmt.Errorf("failed to insert %s balance: %w", chain.NativeToken, err)
 		}
 
-		if ethDecimal, err := decimal.NewFromString(ethWei); err == nil {
+		if nativeDecimal, err := decimal.NewFromString(nativeWei); err == nil {
 			m.logger.Info().
-				Str("ETH", ethDecimal.Shift(-18).String()).
-				Str("network", BASE_NETWORK).
+				Str(chain.NativeToken, nativeDecimal.Shift(-18).String()).
+				Str("network", network).
 				Str("datetime", useTs.Format(time.RFC3339)).
 				Msg("current balance")
 		}
@@ -386,89 +287,20 @@ func (m *Monitor) RunBaseBalances() int {
 			Exponent:  6,
 			Token:     "USDC",
 			Address:   address,
-			Network:   BASE_NETWORK,
+			Network:   network,
 		}
-		if err := m.InsertBalance(usdcBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", BASE_NETWORK).Msg("failed to insert balance")
+		if err := m.InsertBalance(ctx, usdcBalance); err != nil {
+			return fmt.Errorf("failed to insert USDC balance: %w", err)
 		}
 		if usdcDecimal, err := decimal.NewFromString(usdc); err == nil {
 			m.logger.Info().
 				Str("USDC", usdcDecimal.Shift(-6).String()).
-				Str("network", BASE_NETWORK).
+				Str("network", network).
 				Str("datetime", useTs.Format(time.RFC3339)).
 				Msg("current balance")
 		}
 	}
-	return httpCode
-}
-
-func (m *Monitor) RunArbitrumBalances() int {
-	apiUrl := m.cfg.Arbitrum.ApiUrl
-	address := m.cfg.Arbitrum.Address
-	apiKey := m.cfg.Arbitrum.Key
-	useTs := time.Now()
-
-	ethWei, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, "", ARBITRUM_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", ARBITRUM_NETWORK).
-			Msg("failed to get ETH balance")
-		return httpCode
-	}
-
-	usdc, httpCode, err := m.getEthereumBalance(apiUrl, address, apiKey, m.cfg.Arbitrum.UsdcAddress, ARBITRUM_CHAIN_ID)
-	if err != nil {
-		m.logger.Error().Err(err).
-			Str("address", address).
-			Str("network", ARBITRUM_NETWORK).
-			Msg("failed to get USDC balance")
-		return httpCode
-	}
-
-	if ethWei != "" {
-		ethBalance := DbBalance{
-			Timestamp: useTs.Unix(),
-			Balance:   ethWei,
-			Exponent:  18,
-			Token:     "ETH",
-			Address:   address,
-			Network:   ARBITRUM_NETWORK,
-		}
-		if err := m.InsertBalance(ethBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", ARBITRUM_NETWORK).Msg("failed to insert balance")
-		}
-
-		if ethDecimal, err := decimal.NewFromString(ethWei); err == nil {
-			m.logger.Info().
-				Str("ETH", ethDecimal.Shift(-18).String()).
-				Str("network", ARBITRUM_NETWORK).
-				Str("datetime", useTs.Format(time.RFC3339)).
-				Msg("current balance")
-		}
-	}
-
-	if usdc != "" {
-		usdcBalance := DbBalance{
-			Timestamp: useTs.Unix(),
-			Balance:   usdc,
-			Exponent:  6,
-			Token:     "USDC",
-			Address:   address,
-			Network:   ARBITRUM_NETWORK,
-		}
-		if err := m.InsertBalance(usdcBalance); err != nil {
-			m.logger.Error().Err(err).Str("network", ARBITRUM_NETWORK).Msg("failed to insert balance")
-		}
-		if usdcDecimal, err := decimal.NewFromString(usdc); err == nil {
-			m.logger.Info().
-				Str("USDC", usdcDecimal.Shift(-6).String()).
-				Str("network", ARBITRUM_NETWORK).
-				Str("datetime", useTs.Format(time.RFC3339)).
-				Msg("current balance")
-		}
-	}
-	return httpCode
+	return nil
 }
 
 func (m *Monitor) getGasUsedForTxs(txs []EthTxDetails) *big.Int {
@@ -487,62 +319,14 @@ func (m *Monitor) getGasUsedForTxs(txs []EthTxDetails) *big.Int {
 	return total
 }
 
-func (m *Monitor) getEthereumTxs(apiUrl string, address string, apiKey string, chainId int) ([]EthTxDetails, error) {
-	headers := map[string]string{"Accept": "application/json"}
-
-	params := url.Values{}
-	params.Add("module", "account")
-	params.Add("action", "txlist")
-	params.Add("address", address)
-	params.Add("startblock", "0")
-	params.Add("endblock", "latest")
-	params.Add("page", "1")   // fetch all for now - TODO: paginate
-	params.Add("offset", "0") // fetch all for now - TODO: paginate
-	params.Add("sort", "desc")
-	params.Add("apikey", apiKey)
-
-	if strings.Contains(apiUrl, "v2") {
-		params.Add("chainid", strconv.Itoa(chainId))
-	}
-
-	url := fmt.Sprintf("%s?%s", apiUrl, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var data EthScanTxListResponse
-	if err := json.Unmarshal(body, &data); err != nil {
-		return nil, err
-	}
-
-	m.logger.Info().Int("total", len(data.Result)).Str("source", apiUrl).Msg("fetched txs")
-	return data.Result, nil
-}
-
 // getEthereumBalance returns the balance of the given address for the given tokencontract address
 // if contract address == "" then it returns the ETH balance in wei
 // NOTE:
 // * USDC is always 6 decimals
 // * ETH is always 18 decimals
 // * different L2s use different contract addresses for USDC
-func (m *Monitor) getEthereumBalance(apiUrl, address, apiKey, contractAddress string, chainId int) (string, int, error) {
+// An error is returned for non 200 responses and for responses with status "0" (e.g. an invalid key or a rate limit).
+func (m *Monitor) getEthereumBalance(ctx context.Context, apiUrl, address, apiKey, contractAddress string, chainId int) (string, error) {
 	headers := map[string]string{"Accept": "application/json"}
 
 	params := url.Values{}
@@ -565,34 +349,39 @@ func (m *Monitor) getEthereumBalance(apiUrl, address, apiKey, contractAddress st
 	}
 
 	url := fmt.Sprintf("%s?%s", apiUrl, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
 	if err != nil {
-		return "", req.Response.StatusCode, err
+		return "", err
 	}
 
 	for key, value := range headers {
 		req.Header.Add(key, value)
 	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
+	resp, err := httpClient.Do(req)
 	if err != nil {
-		return "", resp.StatusCode, err
+		return "", err
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode != 200 {
+		return "", fmt.Errorf("%s, code: %d", HttpCodeCheck(resp.StatusCode), resp.StatusCode)
+	}
+
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", resp.StatusCode, err
+		return "", err
 	}
 
 	var data EthBalanceResponse
 	if err := json.Unmarshal(body, &data); err != nil {
-		return "", resp.StatusCode, err
+		return "", err
+	}
+	// the result holds the error message if the status is not "1"
+	if data.Status != "1" {
+		return "", fmt.Errorf("etherscan error: %s: %s", data.Message, data.Result)
 	}
 
-	return data.Result, resp.StatusCode, nil
-
+	return data.Result, nil
 }
 
 func (m *Monitor) GetEthereumTxsFromFile(path string, network string) ([]EthTxDetails, error) {
@@ -0,0 +1,179 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// account list actions
+const (
+	ETHERSCAN_ACTION_TXLIST         = "txlist"
+	ETHERSCAN_ACTION_TOKENTX        = "tokentx"
+	ETHERSCAN_ACTION_TXLISTINTERNAL = "txlistinternal"
+)
+
+// cursor of BackfillEtherscanTxHistory, kept apart from the txlist cursor of the incremental sync
+const ETHERSCAN_CURSOR_TXLIST_BACKFILL = "txlist_backfill"
+
+const (
+	// etherscan only returns the first 10k results of a query (page * offset <= 10000)
+	ETHERSCAN_MAX_RESULTS = 10000
+	ETHERSCAN_PAGE_SIZE   = 1000
+)
+
+// free tier allows 5 requests per second
+var etherscanPageSleep = 250 * time.Millisecond
+
+// list actions (txlist, tokentx, txlistinternal) return a string in "result" on errors
+// so the result is decoded only after the status is checked
+type etherscanListResponse struct {
+	Status  string          `json:"status"`
+	Message string          `json:"message"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// getEtherscanList runs a module=account list query and decodes the result into out.
+// An empty result ("No transactions found") is not an error.
+func (m *Monitor) getEtherscanList(ctx context.Context, chain ChainConfig, params url.Values, out interface{}) error {
+	headers := map[string]string{"Accept": "application/json"}
+
+	params.Set("module", "account")
+	params.Set("address", chain.Address)
+	params.Set("apikey", chain.Key)
+	if strings.Contains(chain.ApiUrl, "v2") {
+		params.Set("chainid", strconv.Itoa(chain.ChainId))
+	}
+
+	url := fmt.Sprintf("%s?%s", chain.ApiUrl, params.Encode())
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+
+	for key, value := range headers {
+		req.Header.Add(key, value)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != 200 {
+		return fmt.Errorf("%s, code: %d", HttpCodeCheck(resp.StatusCode), resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	var data etherscanListResponse
+	if err := json.Unmarshal(body, &data); err != nil {
+		return err
+	}
+
+	if data.Status != "1" {
+		if strings.HasPrefix(data.Message, "No transactions found") || strings.HasPrefix(data.Message, "No records found") {
+			return nil
+		}
+		return fmt.Errorf("etherscan error: %s: %s", data.Message, string(data.Result))
+	}
+
+	return json.Unmarshal(data.Result, out)
+}
+
+// walkEtherscanHistory pages through an account list action in ascending block order starting at startBlock.
+//
+// Etherscan only returns the first 10k results of a query, so once a window is exhausted the next
+// window starts at the last block seen. The last block of a truncated window is carried over to the
+// next window, which means handle is only ever called with complete blocks and callers can safely
+// resume from the highest stored block.
+// Extra query params (e.g. contractaddress for tokentx) can be passed in extra.
+func walkEtherscanHistory[T any](ctx context.Context, m *Monitor, chain ChainConfig, action string, extra url.Values, startBlock int64, blockOf func(T) string, handle func([]T) error) error {
+	for {
+		window := []T{}
+		truncated := false
+		for page := 1; page*ETHERSCAN_PAGE_SIZE <= ETHERSCAN_MAX_RESULTS; page++ {
+			params := url.Values{}
+			for key, values := range extra {
+				params[key] = values
+			}
+			params.Add("action", action)
+			params.Add("startblock", strconv.FormatInt(startBlock, 10))
+			params.Add("endblock", "latest")
+			params.Add("page", strconv.Itoa(page))
+			params.Add("offset", strconv.Itoa(ETHERSCAN_PAGE_SIZE))
+			params.Add("sort", "asc")
+
+			items := []T{}
+			if err := m.getEtherscanList(ctx, chain, params, &items); err != nil {
+				return fmt.Errorf("failed to fetch %s page %d from block %d: %w", action, page, startBlock, err)
+			}
+			window = append(window, items...)
+			m.logger.Debug().
+				Str("network", chain.Name).
+				Str("action", action).
+				Int64("start_block", startBlock).
+				Int("page", page).
+				Int("count", len(items)).
+				Msg("fetched etherscan page")
+
+			if err := sleepCtx(ctx, etherscanPageSleep); err != nil {
+				return err
+			}
+			if len(items) < ETHERSCAN_PAGE_SIZE {
+				break
+			}
+			truncated = page*ETHERSCAN_PAGE_SIZE == ETHERSCAN_MAX_RESULTS
+		}
+
+		if len(window) == 0 {
+			return nil
+		}
+
+		if !truncated {
+			return handle(window)
+		}
+
+		firstBlock, err := strconv.ParseInt(blockOf(window[0]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		lastBlock, err := strconv.ParseInt(blockOf(window[len(window)-1]), 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+
+		// the whole window is a single block -- nothing to carry over
+		if firstBlock == lastBlock {
+			if err := handle(window); err != nil {
+				return err
+			}
+			startBlock = lastBlock + 1
+			continue
+		}
+
+		complete := []T{}
+		for _, item := range window {
+			block, err := strconv.ParseInt(blockOf(item), 10, 64)
+			if err != nil {
+				return fmt.Errorf("failed to parse block number: %w", err)
+			}
+			if block < lastBlock {
+				complete = append(complete, item)
+			}
+		}
+		if err := handle(complete); err != nil {
+			return err
+		}
+		startBlock = lastBlock
+	}
+}
@@ -0,0 +1,274 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// etherscanRequest is the window start and page of a txlist request
+type etherscanRequest struct {
+	startBlock int
+	page       int
+}
+
+// fakeEtherscan serves list pages of items sorted by block and records the requests
+func fakeEtherscan[T any](t *testing.T, items []T, blockOf func(T) string, requests *[]etherscanRequest) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		startBlock, _ := strconv.Atoi(q.Get("startblock"))
+		page, _ := strconv.Atoi(q.Get("page"))
+		offset, _ := strconv.Atoi(q.Get("offset"))
+		if requests != nil {
+			*requests = append(*requests, etherscanRequest{startBlock, page})
+		}
+
+		matching := []T{}
+		for _, item := range items {
+			if b, _ := strconv.Atoi(blockOf(item)); b >= startBlock {
+				matching = append(matching, item)
+			}
+		}
+		from := min((page-1)*offset, len(matching))
+		to := min(page*offset, len(matching))
+		result, _ := json.Marshal(matching[from:to])
+		status, message := "1", "OK"
+		if len(result) == 2 {
+			status, message = "0", "No transactions found"
+		}
+		json.NewEncoder(w).Encode(etherscanListResponse{Status: status, Message: message, Result: result})
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestWalkEtherscanHistoryCarriesOverTruncatedBlocks(t *testing.T) {
+	etherscanPageSleep = 0
+
+	// 3 txs per block, more than fits in a single 10k window
+	txs := []EthTxDetails{}
+	for i := 0; i < 25000; i++ {
+		txs = append(txs, EthTxDetails{
+			Hash:        strconv.Itoa(i),
+			BlockNumber: strconv.Itoa(i / 3),
+		})
+	}
+	srv := fakeEtherscan(t, txs, txBlock, nil)
+
+	m := newTestMonitor()
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, ChainEntry: ChainEntry{ApiUrl: srv.URL}}
+
+	seen := map[string]bool{}
+	lastBlock := int64(-1)
+	err := walkEtherscanHistory(context.Background(), m, chain, ETHERSCAN_ACTION_TXLIST, nil, 0,
+		func(tx EthTxDetails) string { return tx.BlockNumber },
+		func(batch []EthTxDetails) error {
+			for _, tx := range batch {
+				assert.False(t, seen[tx.Hash], "tx %s handled twice", tx.Hash)
+				seen[tx.Hash] = true
+				b, _ := strconv.ParseInt(tx.BlockNumber, 10, 64)
+				assert.GreaterOrEqual(t, b, lastBlock)
+				lastBlock = b
+			}
+			return nil
+		})
+	require.NoError(t, err)
+	assert.Len(t, seen, len(txs))
+}
+
+func TestWalkEtherscanHistoryWindows(t *testing.T) {
+	etherscanPageSleep = 0
+
+	pages := func(startBlock, count int) []etherscanRequest {
+		requests := []etherscanRequest{}
+		for page := 1; page <= count; page++ {
+			requests = append(requests, etherscanRequest{startBlock, page})
+		}
+		return requests
+	}
+
+	tests := []struct {
+		name       string
+		blocks     []int
+		startBlock int64
+		// requests sent and the number of handled txs per window
+		requests []etherscanRequest
+		windows  []int
+	}{
+		{
+			name:     "empty history",
+			requests: pages(0, 1),
+		},
+		{
+			name:       "single partial page",
+			blocks:     []int{10, 11, 11, 12},
+			startBlock: 5,
+			requests:   pages(5, 1),
+			windows:    []int{4},
+		},
+		{
+			// a full last page is followed by an empty one
+			name:     "full pages",
+			blocks:   repeatBlocks(2000, 1),
+			requests: pages(0, 3),
+			windows:  []int{2000},
+		},
+		{
+			// the last block of a truncated window starts the next window
+			name:     "truncated windows",
+			blocks:   repeatBlocks(25000, 3),
+			requests: append(append(pages(0, 10), pages(3333, 10)...), pages(6666, 6)...),
+			windows:  []int{9999, 9999, 5002},
+		},
+		{
+			// a window of a single block can't be carried over, the next window starts after it
+			name:     "single block window",
+			blocks:   append(repeatBlocks(12000, 12000), 1, 1),
+			requests: append(pages(0, 10), pages(1, 1)...),
+			windows:  []int{10000, 2},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			txs := []EthTxDetails{}
+			for i, block := range tt.blocks {
+				txs = append(txs, EthTxDetails{Hash: strconv.Itoa(i), BlockNumber: strconv.Itoa(block)})
+			}
+			requests := []etherscanRequest{}
+			srv := fakeEtherscan(t, txs, txBlock, &requests)
+
+			m := newTestMonitor()
+			chain := ChainConfig{Name: ETHEREUM_NETWORK, ChainEntry: ChainEntry{ApiUrl: srv.URL}}
+			windows := []int{}
+			err := walkEtherscanHistory(context.Background(), m, chain, ETHERSCAN_ACTION_TXLIST, nil, tt.startBlock,
+				func(tx EthTxDetails) string { return tx.BlockNumber },
+				func(batch []EthTxDetails) error {
+					windows = append(windows, len(batch))
+					return nil
+				})
+			require.NoError(t, err)
+			assert.Equal(t, tt.requests, requests)
+			if tt.windows == nil {
+				assert.Empty(t, windows)
+			} else {
+				assert.Equal(t, tt.windows, windows)
+			}
+		})
+	}
+}
+
+func txBlock(tx EthTxDetails) string { return tx.BlockNumber }
+
+// repeatBlocks returns the blocks of count txs with perBlock txs in each block starting at block 0
+func repeatBlocks(count, perBlock int) []int {
+	blocks := []int{}
+	for i := 0; i < count; i++ {
+		blocks = append(blocks, i/perBlock)
+	}
+	return blocks
+}
+
+func TestBackfillEtherscanTxHistoryStartsBelowIncrementalCursor(t *testing.T) {
+	etherscanPageSleep = 0
+	ctx := context.Background()
+
+	txs := []EthTxDetails{}
+	for block := 10; block <= 100; block += 10 {
+		txs = append(txs, EthTxDetails{
+			Hash:        strconv.Itoa(block),
+			BlockNumber: strconv.Itoa(block),
+			TimeStamp:   "1742400000",
+			GasUsed:     "21000",
+			GasPrice:    "1000000000",
+		})
+	}
+	requests := []etherscanRequest{}
+	srv := fakeEtherscan(t, txs, txBlock, &requests)
+
+	m := newTestMonitorWithDB(t, nil)
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, CoingeckoId: COINGECKO_ETHEREUM_ID, ChainEntry: ChainEntry{ApiUrl: srv.URL}}
+	require.NoError(t, m.InsertUsdPrice(ctx, COINGECKO_ETHEREUM_ID, 2000))
+
+	// the incremental sync is already at the tip
+	require.NoError(t, m.UpsertEthCursor(ctx, ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLIST, 100))
+
+	require.NoError(t, m.BackfillEtherscanTxHistory(ctx, chain, -1, false))
+	require.NotEmpty(t, requests)
+	assert.Equal(t, 0, requests[0].startBlock)
+
+	hashes, err := m.GetEthTxHashes(ETHEREUM_NETWORK, 0)
+	require.NoError(t, err)
+	assert.Len(t, hashes, len(txs))
+
+	backfill, err := m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_CURSOR_TXLIST_BACKFILL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), backfill)
+	incremental, err := m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLIST)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), incremental)
+
+	// resumes from its own cursor
+	requests = requests[:0]
+	require.NoError(t, m.BackfillEtherscanTxHistory(ctx, chain, -1, false))
+	assert.Equal(t, 100, requests[0].startBlock)
+}
+
+func TestSyncEtherscanTxsHoldsCursorAtFailure(t *testing.T) {
+	etherscanPageSleep = 0
+	ctx := context.Background()
+
+	txs := []EthTxDetails{}
+	for block := 10; block <= 40; block += 10 {
+		txs = append(txs, EthTxDetails{
+			Hash:        strconv.Itoa(block),
+			BlockNumber: strconv.Itoa(block),
+			TimeStamp:   "1742400000",
+			GasUsed:     "21000",
+			GasPrice:    "1000000000",
+		})
+	}
+	requests := []etherscanRequest{}
+	srv := fakeEtherscan(t, txs, txBlock, &requests)
+
+	m := newTestMonitorWithDB(t, nil)
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, CoingeckoId: COINGECKO_ETHEREUM_ID, ChainEntry: ChainEntry{ApiUrl: srv.URL}}
+	require.NoError(t, m.InsertUsdPrice(ctx, COINGECKO_ETHEREUM_ID, 2000))
+
+	_, err := m.db.Exec(`
+		CREATE TRIGGER fail_tx BEFORE INSERT ON eth_tx_responses
+		WHEN NEW.tx_hash = '20'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.RunEtherscanTxHistory(ctx, chain, false))
+	cursor, err := m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLIST)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), cursor)
+	// the txs after the failed one are stored anyway
+	hashes, err := m.GetEthTxHashes(ETHEREUM_NETWORK, 0)
+	require.NoError(t, err)
+	assert.Len(t, hashes, 3)
+
+	// the next run starts at the failed tx
+	_, err = m.db.Exec("DROP TRIGGER fail_tx")
+	require.NoError(t, err)
+	requests = requests[:0]
+	require.NoError(t, m.RunEtherscanTxHistory(ctx, chain, false))
+	require.NotEmpty(t, requests)
+	assert.Equal(t, 20, requests[0].startBlock)
+
+	hashes, err = m.GetEthTxHashes(ETHEREUM_NETWORK, 0)
+	require.NoError(t, err)
+	assert.True(t, hashes["20"])
+	cursor, err = m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLIST)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), cursor)
+}
@@ -0,0 +1,192 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+const (
+	// height window compared against the node in a single query
+	GAP_CHECK_RANGE = 100_000
+	// windows with missing orders are bisected down to this size before they are backfilled
+	GAP_MIN_RANGE = 1_000
+
+	GAPS_JOB              = "gaps"
+	DEFAULT_GAPS_SCHEDULE = "@daily"
+)
+
+// OrderGap is a height range [FromHeight, ToHeight) where the node reports more order_filled txs than tx_data holds
+type OrderGap struct {
+	FromHeight int64 `json:"from_height"`
+	ToHeight   int64 `json:"to_height"`
+	NodeCount  int   `json:"node_count"`
+	DbCount    int   `json:"db_count"`
+	// orders inserted by the last backfill
+	Backfilled int       `json:"backfilled"`
+	Resolved   bool      `json:"resolved"`
+	DetectedAt time.Time `json:"detected_at,omitempty"`
+	UpdatedAt  time.Time `json:"updated_at,omitempty"`
+}
+
+func rangeQuery(contractAddress string, fromHeight, toHeight int64) string {
+	return fmt.Sprintf("%s AND tx.height>=%d AND tx.height<%d", ordersQuery(contractAddress), fromHeight, toHeight)
+}
+
+// countNodeOrderTxs returns the number of order_filled txs the node has indexed in [fromHeight, toHeight)
+func (m *Monitor) countNodeOrderTxs(ctx context.Context, contractAddress string, fromHeight, toHeight int64) (int, error) {
+	data, err := m.getOrdersPage(ctx, rangeQuery(contractAddress, fromHeight, toHeight), 1, 1)
+	if err != nil {
+		return 0, err
+	}
+	return int(data.Total), nil
+}
+
+// FindOrderGaps compares the node and db tx counts of [fromHeight, toHeight) in GAP_CHECK_RANGE windows.
+// Windows with missing txs are bisected to narrow down the ranges that need to be refetched.
+// Ranges where the db holds more txs than the node (e.g. pruned tx index) are not gaps.
+func (m *Monitor) FindOrderGaps(ctx context.Context, contractAddress string, fromHeight, toHeight int64) ([]OrderGap, error) {
+	gaps := []OrderGap{}
+	var check func(from, to int64) error
+	check = func(from, to int64) error {
+		if err := sleepCtx(ctx, osmosisOrdersPageSleep); err != nil {
+			return err
+		}
+		nodeCount, err := m.countNodeOrderTxs(ctx, contractAddress, from, to)
+		if err != nil {
+			return fmt.Errorf("failed to count node txs in %d-%d: %w", from, to, err)
+		}
+		hashes, err := m.GetDbOrderTxHashes(from, to)
+		if err != nil {
+			return fmt.Errorf("failed to count db txs in %d-%d: %w", from, to, err)
+		}
+		if nodeCount <= len(hashes) {
+			return nil
+		}
+		if to-from <= GAP_MIN_RANGE {
+			gaps = append(gaps, OrderGap{FromHeight: from, ToHeight: to, NodeCount: nodeCount, DbCount: len(hashes)})
+			return nil
+		}
+		mid := from + (to-from)/2
+		if err := check(from, mid); err != nil {
+			return err
+		}
+		return check(mid, to)
+	}
+
+	for from := fromHeight; from < toHeight; from += GAP_CHECK_RANGE {
+		if err := check(from, min(from+GAP_CHECK_RANGE, toHeight)); err != nil {
+			return gaps, err
+		}
+	}
+	return gaps, nil
+}
+
+// BackfillOrderGap pages through the order_filled txs of the gap and inserts the orders missing from tx_data.
+// Returns the number of inserted orders.
+func (m *Monitor) BackfillOrderGap(ctx context.Context, contractAddress string, gap OrderGap, saveRawResponses bool) (int, error) {
+	stored, err := m.GetDbOrderTxHashes(gap.FromHeight, gap.ToHeight)
+	if err != nil {
+		return 0, err
+	}
+
+	query := rangeQuery(contractAddress, gap.FromHeight, gap.ToHeight)
+	inserted := 0
+	for page := 1; ; page++ {
+		if err := sleepCtx(ctx, osmosisOrdersPageSleep); err != nil {
+			return inserted, err
+		}
+		data, err := m.getOrdersPage(ctx, query, page, osmosisOrdersPageLimit)
+		if err != nil {
+			return inserted, fmt.Errorf("failed to fetch gap %d-%d page %d: %w", gap.FromHeight, gap.ToHeight, page, err)
+		}
+
+		orders, responses := m.decodeOrderTxResponses(data.TxResponses)
+		if saveRawResponses {
+			for _, r := range responses {
+				if stored[r.TxHash] {
+					continue
+				}
+				if err := m.InsertRawTxResponse(ctx, *r); err != nil {
+					m.logger.Error().Err(err).Str("tx_hash", r.TxHash).Msg("failed to insert raw tx response from osmosis")
+				}
+			}
+		}
+		// the tx counts as stored once any of its orders is stored -- all of them are inserted together
+		byTx := map[string][]DbOrderFilled{}
+		txHashes := []string{}
+		for _, o := range orders {
+			if stored[o.TxHash] {
+				continue
+			}
+			if _, ok := byTx[o.TxHash]; !ok {
+				txHashes = append(txHashes, o.TxHash)
+			}
+			byTx[o.TxHash] = append(byTx[o.TxHash], o)
+		}
+		for _, txHash := range txHashes {
+			txOrders := byTx[txHash]
+			if err := m.InsertOrdersFilled(ctx, txOrders); err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", txHash).Int64("height", txOrders[0].Height).Msg("failed to insert backfilled orders")
+				continue
+			}
+			inserted += len(txOrders)
+		}
+
+		if len(data.TxResponses) < osmosisOrdersPageLimit || uint64(page*osmosisOrdersPageLimit) >= data.Total {
+			break
+		}
+	}
+	return inserted, nil
+}
+
+// RunGapDetection checks the stored order heights for gaps, backfills them and records the result in tx_data_gaps.
+// A gap stays unresolved if the node still reports more txs after the backfill (e.g. txs that can't be decoded).
+func (m *Monitor) RunGapDetection(ctx context.Context, saveRawResponses bool) error {
+	contractAddress := m.cfg.Osmosis.ContractAddress
+	minHeight, maxHeight, err := m.GetDbOrderTxRange()
+	if err != nil {
+		return err
+	}
+	if maxHeight == 0 {
+		m.logger.Info().Msg("no stored orders -- skipping gap detection")
+		return nil
+	}
+
+	gaps, err := m.FindOrderGaps(ctx, contractAddress, minHeight, maxHeight+1)
+	if err != nil {
+		return err
+	}
+	m.logger.Info().Int("gaps", len(gaps)).Int64("from_height", minHeight).Int64("to_height", maxHeight).Msg("checked orders for gaps")
+
+	for _, gap := range gaps {
+		inserted, err := m.BackfillOrderGap(ctx, contractAddress, gap, saveRawResponses)
+		if err != nil {
+			return err
+		}
+		stored, err := m.GetDbOrderTxHashes(gap.FromHeight, gap.ToHeight)
+		if err != nil {
+			return err
+		}
+		gap.Backfilled = inserted
+		gap.DbCount = len(stored)
+		gap.Resolved = gap.DbCount >= gap.NodeCount
+		if err := m.UpsertOrderGap(ctx, gap); err != nil {
+			return err
+		}
+
+		logEvent := m.logger.Info()
+		if !gap.Resolved {
+			logEvent = m.logger.Warn()
+		}
+		logEvent.
+			Int64("from_height", gap.FromHeight).
+			Int64("to_height", gap.ToHeight).
+			Int("node_count", gap.NodeCount).
+			Int("db_count", gap.DbCount).
+			Int("backfilled", inserted).
+			Bool("resolved", gap.Resolved).
+			Msg("backfilled orders gap")
+	}
+	return nil
+}
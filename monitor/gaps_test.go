@@ -0,0 +1,97 @@
+package monitor
+
+import (
+	"context"
+	"net/http/httptest"
+	"testing"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunGapDetection(t *testing.T) {
+	osmosisOrdersPageLimit = 2
+	osmosisOrdersPageSleep = 0
+	defer func() { osmosisOrdersPageLimit = 100 }()
+
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+
+	srv := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return fixture.TxResponses }))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	// the newest, oldest and one order in between are stored
+	orders, _ := m.decodeOrderTxResponses(fixture.TxResponses)
+	require.Len(t, orders, len(fixture.TxResponses))
+	for _, i := range []int{0, 3, 6} {
+		require.NoError(t, m.InsertOrderFilled(ctx, orders[i]))
+	}
+
+	require.NoError(t, m.RunGapDetection(ctx, false))
+
+	minHeight, maxHeight, err := m.GetDbOrderTxRange()
+	require.NoError(t, err)
+	stored, err := m.GetDbOrderTxHashes(minHeight, maxHeight+1)
+	require.NoError(t, err)
+	for _, o := range orders {
+		assert.True(t, stored[o.TxHash], "order %s not backfilled", o.TxHash)
+	}
+
+	gaps, err := m.GetDbOrderGaps()
+	require.NoError(t, err)
+	require.NotEmpty(t, gaps)
+	backfilled := 0
+	for _, g := range gaps {
+		assert.True(t, g.Resolved)
+		assert.LessOrEqual(t, g.ToHeight-g.FromHeight, int64(GAP_MIN_RANGE))
+		backfilled += g.Backfilled
+	}
+	assert.Equal(t, 4, backfilled)
+
+	// nothing left to backfill
+	require.NoError(t, m.RunGapDetection(ctx, false))
+	after, err := m.GetDbOrderGaps()
+	require.NoError(t, err)
+	assert.Equal(t, len(gaps), len(after))
+}
+
+func TestBackfillOrderGapStoresTxTogether(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+
+	var txResponse sdktypes.TxResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "test_multi_execs_authz.json"), &txResponse))
+	batchFillOrder(t, m, &txResponse, 4012)
+	srv := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return []*sdktypes.TxResponse{&txResponse} }))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	// the second fill of the tx fails
+	_, err := m.db.Exec(`
+		CREATE TRIGGER fail_second_fill BEFORE INSERT ON tx_data
+		WHEN EXISTS (SELECT 1 FROM tx_data WHERE tx_hash = NEW.tx_hash)
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END
+	`)
+	require.NoError(t, err)
+
+	gap := OrderGap{FromHeight: txResponse.Height, ToHeight: txResponse.Height + 1}
+	inserted, err := m.BackfillOrderGap(ctx, m.cfg.Osmosis.ContractAddress, gap, false)
+	require.NoError(t, err)
+	assert.Zero(t, inserted)
+	stored, err := m.GetDbOrders(OrderFilter{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, stored)
+
+	// the tx isn't counted as stored and is backfilled on the next run
+	_, err = m.db.Exec("DROP TRIGGER fail_second_fill")
+	require.NoError(t, err)
+	inserted, err = m.BackfillOrderGap(ctx, m.cfg.Osmosis.ContractAddress, gap, false)
+	require.NoError(t, err)
+	assert.Equal(t, 2, inserted)
+}
@@ -1,5 +1,18 @@
 package monitor
 
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// requests that take longer are cancelled so a stuck endpoint can't wedge a tick
+const DEFAULT_HTTP_TIMEOUT = 30 * time.Second
+
+var httpClient = &http.Client{Timeout: DEFAULT_HTTP_TIMEOUT}
+
 func HttpCodeCheck(httpCode int) string {
 	// 429 Too Many Requests
 	if httpCode == 429 {
@@ -15,3 +28,23 @@ func HttpCodeCheck(httpCode int) string {
 	}
 	return ""
 }
+
+// sleepCtx sleeps for d; returns the context error if the context is cancelled first
+func sleepCtx(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
+
+// formatUsdc formats an amount of micro USDC in USDC, or as the integer amount if asInteger is set
+func formatUsdc(amount int64, asInteger bool) string {
+	if asInteger {
+		return decimal.NewFromInt(amount).String()
+	}
+	return decimal.NewFromInt(amount).Shift(-6).String()
+}
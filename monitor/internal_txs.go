@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"math/big"
+	"strconv"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+// EthInternalTx is a single result of the etherscan txlistinternal action.
+// Internal txs are contract initiated native token transfers (refunds, payouts...).
+type EthInternalTx struct {
+	BlockNumber     string `json:"blockNumber"`
+	TimeStamp       string `json:"timeStamp"`
+	Hash            string `json:"hash"`
+	From            string `json:"from"`
+	To              string `json:"to"`
+	Value           string `json:"value"`
+	ContractAddress string `json:"contractAddress"`
+	Type            string `json:"type"`
+	Gas             string `json:"gas"`
+	GasUsed         string `json:"gasUsed"`
+	TraceId         string `json:"traceId"`
+	IsError         string `json:"isError"`
+	ErrCode         string `json:"errCode"`
+}
+
+// RunEtherscanInternalTxs stores internal txs to and from the chain address
+// starting at the stored txlistinternal cursor.
+func (m *Monitor) RunEtherscanInternalTxs(ctx context.Context, chain ChainConfig) error {
+	startBlock := m.getEtherscanStartBlock(chain.Name, ETHERSCAN_ACTION_TXLISTINTERNAL)
+	if err := m.syncEtherscanInternalTxs(ctx, chain, startBlock); err != nil {
+		return fmt.Errorf("failed to get internal txs: %w", err)
+	}
+	return nil
+}
+
+func (m *Monitor) syncEtherscanInternalTxs(ctx context.Context, chain ChainConfig, startBlock int64) error {
+	network := chain.Name
+	total, totalInserted, totalFailed, totalSkipped := 0, 0, 0, 0
+	totalIn := new(big.Int)
+	totalOut := new(big.Int)
+
+	// the cursor is not advanced past the first internal tx that failed to store so it is fetched again on the next run.
+	// Internal txs that can't be converted would fail again and are skipped.
+	failedBlock := ""
+
+	handle := func(txs []EthInternalTx) error {
+		total += len(txs)
+		for _, t := range txs {
+			internalTx, err := toDbInternalTx(chain, t)
+			if err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", t.Hash).Str("network", network).Msg("failed to convert internal tx -- skipping")
+				totalSkipped++
+				continue
+			}
+			inserted, err := m.InsertInternalTx(ctx, internalTx)
+			if err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", t.Hash).Str("network", network).Msg("failed to insert internal tx")
+				totalFailed++
+				if failedBlock == "" {
+					failedBlock = t.BlockNumber
+				}
+				continue
+			}
+			if !inserted {
+				continue
+			}
+			totalInserted++
+			if value, ok := new(big.Int).SetString(internalTx.Value, 10); ok && !internalTx.IsError {
+				if internalTx.Direction == TRANSFER_DIRECTION_IN {
+					totalIn.Add(totalIn, value)
+				} else {
+					totalOut.Add(totalOut, value)
+				}
+			}
+		}
+		if len(txs) == 0 {
+			return nil
+		}
+		cursorBlock := txs[len(txs)-1].BlockNumber
+		if failedBlock != "" {
+			cursorBlock = failedBlock
+		}
+		lastBlock, err := strconv.ParseInt(cursorBlock, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		return m.UpsertEthCursor(ctx, network, ETHERSCAN_ACTION_TXLISTINTERNAL, lastBlock)
+	}
+
+	err := walkEtherscanHistory(ctx, m, chain, ETHERSCAN_ACTION_TXLISTINTERNAL, nil, startBlock,
+		func(t EthInternalTx) string { return t.BlockNumber },
+		handle)
+
+	m.logger.Info().Int("total", total).
+		Int("new", totalInserted).
+		Int("failed", totalFailed).
+		Int("skipped", totalSkipped).
+		Int64("start_block", startBlock).
+		Str("network", network).
+		Str("total_in", decimal.NewFromBigInt(totalIn, -18).String()).
+		Str("total_out", decimal.NewFromBigInt(totalOut, -18).String()).
+		Str("token", chain.NativeToken).
+		Msg("finished processing internal txs")
+	return err
+}
+
+func toDbInternalTx(chain ChainConfig, t EthInternalTx) (DbInternalTx, error) {
+	height, err := strconv.ParseInt(t.BlockNumber, 10, 64)
+	if err != nil {
+		return DbInternalTx{}, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	timestamp, err := strconv.ParseInt(t.TimeStamp, 10, 64)
+	if err != nil {
+		return DbInternalTx{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	if _, ok := new(big.Int).SetString(t.Value, 10); !ok {
+		return DbInternalTx{}, fmt.Errorf("failed to parse value: %s", t.Value)
+	}
+
+	direction := TRANSFER_DIRECTION_OUT
+	counterparty := t.To
+	if strings.EqualFold(t.To, chain.Address) {
+		direction = TRANSFER_DIRECTION_IN
+		counterparty = t.From
+	}
+
+	return DbInternalTx{
+		TxHash:       t.Hash,
+		TraceId:      t.TraceId,
+		Network:      chain.Name,
+		Token:        chain.NativeToken,
+		Direction:    direction,
+		Counterparty: strings.ToLower(counterparty),
+		Value:        t.Value,
+		Height:       height,
+		Timestamp:    timestamp,
+		Type:         t.Type,
+		IsError:      t.IsError == "1",
+	}, nil
+}
@@ -0,0 +1,207 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToDbInternalTx(t *testing.T) {
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, NativeToken: "ETH", ChainEntry: ChainEntry{Address: testSolverAddress}}
+	other := "0x2222222222222222222222222222222222222222"
+	internalTx := func(from, to, value, isError string) EthInternalTx {
+		return EthInternalTx{
+			BlockNumber: "100",
+			TimeStamp:   "1742400000",
+			Hash:        "0xabc",
+			From:        from,
+			To:          to,
+			Value:       value,
+			Type:        "call",
+			TraceId:     "0_1",
+			IsError:     isError,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		tx           EthInternalTx
+		direction    string
+		counterparty string
+		isError      bool
+		wantErr      bool
+	}{
+		{
+			name:         "refund to the solver",
+			tx:           internalTx(other, testSolverAddress, "1000000000000000000", "0"),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+		},
+		{
+			name:         "payout from the solver",
+			tx:           internalTx(testSolverAddress, "0xAbCdEf0000000000000000000000000000000000", "5", "0"),
+			direction:    TRANSFER_DIRECTION_OUT,
+			counterparty: "0xabcdef0000000000000000000000000000000000",
+		},
+		{
+			name:         "failed call",
+			tx:           internalTx(other, testSolverAddress, "5", "1"),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+			isError:      true,
+		},
+		{
+			name:         "missing isError",
+			tx:           internalTx(other, testSolverAddress, "5", ""),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+		},
+		{
+			// values are uint256 and don't fit into int64
+			name:         "value above int64",
+			tx:           internalTx(other, testSolverAddress, "100000000000000000000000", "0"),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+		},
+		{
+			name:    "invalid value",
+			tx:      internalTx(other, testSolverAddress, "0x10", "0"),
+			wantErr: true,
+		},
+		{
+			name: "invalid timestamp",
+			tx: func() EthInternalTx {
+				tx := internalTx(other, testSolverAddress, "5", "0")
+				tx.TimeStamp = ""
+				return tx
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toDbInternalTx(chain, tt.tx)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.direction, got.Direction)
+			assert.Equal(t, tt.counterparty, got.Counterparty)
+			assert.Equal(t, tt.isError, got.IsError)
+			assert.Equal(t, tt.tx.Value, got.Value)
+			assert.Equal(t, "ETH", got.Token)
+			assert.Equal(t, "0_1", got.TraceId)
+			assert.Equal(t, int64(100), got.Height)
+		})
+	}
+}
+
+func TestInternalTxTotals(t *testing.T) {
+	etherscanPageSleep = 0
+	ctx := context.Background()
+
+	other := "0x2222222222222222222222222222222222222222"
+	txs := []EthInternalTx{
+		{From: other, To: testSolverAddress, Value: "100000000000000000000000", IsError: "0"},
+		{From: other, To: testSolverAddress, Value: "5", IsError: "0"},
+		// failed calls don't move funds
+		{From: other, To: testSolverAddress, Value: "1000", IsError: "1"},
+		{From: testSolverAddress, To: other, Value: "7", IsError: "0"},
+		{From: testSolverAddress, To: other, Value: "1000", IsError: "1"},
+	}
+	for i := range txs {
+		txs[i].BlockNumber = strconv.Itoa(10 * (i + 1))
+		txs[i].TimeStamp = strconv.Itoa(1742400000 + i)
+		txs[i].Hash = "0x" + strconv.Itoa(i)
+		txs[i].Type = "call"
+	}
+	srv := fakeEtherscan(t, txs, func(tx EthInternalTx) string { return tx.BlockNumber }, nil)
+
+	m := newTestMonitorWithDB(t, nil)
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, NativeToken: "ETH", ChainEntry: ChainEntry{ApiUrl: srv.URL, Address: testSolverAddress}}
+	require.NoError(t, m.RunEtherscanInternalTxs(ctx, chain))
+	// stored once
+	require.NoError(t, m.RunEtherscanInternalTxs(ctx, chain))
+
+	cursor, err := m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLISTINTERNAL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(50), cursor)
+
+	in, out, err := m.GetDbInternalTxTotals(ETHEREUM_NETWORK, 0, 1742400000+int64(len(txs)))
+	require.NoError(t, err)
+	assert.Equal(t, "100000000000000000000005", in.String())
+	assert.Equal(t, "7", out.String())
+
+	// (from, to] excludes the first tx
+	in, out, err = m.GetDbInternalTxTotals(ETHEREUM_NETWORK, 1742400000, 1742400000+int64(len(txs)))
+	require.NoError(t, err)
+	assert.Equal(t, "5", in.String())
+	assert.Equal(t, "7", out.String())
+
+	in, out, err = m.GetDbInternalTxTotals(BASE_NETWORK, 0, 1742400000+int64(len(txs)))
+	require.NoError(t, err)
+	assert.Zero(t, in.Sign())
+	assert.Zero(t, out.Sign())
+}
+
+func TestSyncEtherscanInternalTxsHoldsCursorAtFailure(t *testing.T) {
+	etherscanPageSleep = 0
+	ctx := context.Background()
+
+	other := "0x2222222222222222222222222222222222222222"
+	txs := []EthInternalTx{}
+	for block := 10; block <= 40; block += 10 {
+		txs = append(txs, EthInternalTx{
+			BlockNumber: strconv.Itoa(block),
+			TimeStamp:   "1742400000",
+			Hash:        "0x" + strconv.Itoa(block),
+			From:        other,
+			To:          testSolverAddress,
+			Value:       "5",
+			Type:        "call",
+			IsError:     "0",
+		})
+	}
+	// can't be converted and is skipped
+	txs[3].Value = "0x10"
+
+	requests := []etherscanRequest{}
+	srv := fakeEtherscan(t, txs, func(tx EthInternalTx) string { return tx.BlockNumber }, &requests)
+
+	m := newTestMonitorWithDB(t, nil)
+	chain := ChainConfig{Name: ETHEREUM_NETWORK, NativeToken: "ETH", ChainEntry: ChainEntry{ApiUrl: srv.URL, Address: testSolverAddress}}
+	_, err := m.db.Exec(`
+		CREATE TRIGGER fail_internal_tx BEFORE INSERT ON eth_internal_txs
+		WHEN NEW.tx_hash = '0x20'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.RunEtherscanInternalTxs(ctx, chain))
+	cursor, err := m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLISTINTERNAL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), cursor)
+	in, _, err := m.GetDbInternalTxTotals(ETHEREUM_NETWORK, 0, 1742400000)
+	require.NoError(t, err)
+	assert.Equal(t, "10", in.String())
+
+	// the next run starts at the failed internal tx
+	_, err = m.db.Exec("DROP TRIGGER fail_internal_tx")
+	require.NoError(t, err)
+	requests = requests[:0]
+	require.NoError(t, m.RunEtherscanInternalTxs(ctx, chain))
+	require.NotEmpty(t, requests)
+	assert.Equal(t, 20, requests[0].startBlock)
+
+	// the skipped internal tx doesn't hold the cursor
+	cursor, err = m.GetEthCursor(ETHEREUM_NETWORK, ETHERSCAN_ACTION_TXLISTINTERNAL)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), cursor)
+	in, _, err = m.GetDbInternalTxTotals(ETHEREUM_NETWORK, 0, 1742400000)
+	require.NoError(t, err)
+	assert.Equal(t, "15", in.String())
+}
@@ -0,0 +1,246 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	LATEST_BLOCK_QUERY = "/cosmos/base/tendermint/v1beta1/blocks/latest"
+	// latest heights of the endpoints are checked again before a call once they are older than this
+	LCD_HEIGHT_CHECK_INTERVAL = 15 * time.Second
+	// a height check gives up on endpoints that didn't respond within this
+	LCD_HEIGHT_CHECK_TIMEOUT = 5 * time.Second
+	// endpoints more blocks behind the highest endpoint are lagging and only used if no endpoint is up to date
+	LCD_MAX_BLOCK_LAG = 10
+)
+
+// LcdEndpointStatus is the health of an endpoint and its latest height (0 if unknown)
+type LcdEndpointStatus struct {
+	EndpointStatus
+	LatestHeight int64 `json:"latest_height"`
+}
+
+// LcdClient sends queries to the most up-to-date healthy endpoint of a list of interchangeable LCDs
+// and fails over to the next endpoint if a query fails.
+type LcdClient struct {
+	pool   *EndpointPool
+	logger *zerolog.Logger
+
+	mu        sync.Mutex
+	heights   map[string]int64
+	checkedAt time.Time
+	// closed once the first height check finished
+	firstCheck     chan struct{}
+	firstCheckOnce sync.Once
+}
+
+func NewLcdClient(urls []string, logger *zerolog.Logger) *LcdClient {
+	return &LcdClient{
+		pool:       NewEndpointPool(urls, DefaultCircuitBreakerConfig),
+		logger:     logger,
+		heights:    map[string]int64{},
+		firstCheck: make(chan struct{}),
+	}
+}
+
+// Get queries path (with the query string) on the endpoints in the order of orderedUrls until one of them responds with 200.
+// If the breakers of all endpoints are open, the first endpoint is queried anyway -- with a single LCD there is nothing
+// to fail over to. Returns the error of the last queried endpoint if all of them failed.
+func (c *LcdClient) Get(ctx context.Context, path string) ([]byte, error) {
+	c.refreshHeights(ctx)
+
+	urls := c.orderedUrls()
+	if len(urls) == 0 {
+		return nil, fmt.Errorf("no lcd endpoint configured for %s", path)
+	}
+	var lastErr error
+	queried := false
+	for _, url := range urls {
+		if ok, _ := c.pool.Allow(url); !ok {
+			continue
+		}
+		queried = true
+		body, err := c.get(ctx, url, path)
+		if err == nil || ctx.Err() != nil {
+			return body, err
+		}
+		c.logger.Warn().Err(err).Str("url", url).Str("path", path).Msg("lcd query failed -- trying next endpoint")
+		lastErr = err
+	}
+	if !queried {
+		return c.get(ctx, urls[0], path)
+	}
+	return nil, lastErr
+}
+
+func (c *LcdClient) get(ctx context.Context, url, path string) ([]byte, error) {
+	start := time.Now()
+	body, err := lcdGet(ctx, url+path)
+	if ctx.Err() != nil {
+		// the endpoint didn't fail, the caller gave up
+		c.pool.Release(url)
+		return nil, ctx.Err()
+	}
+	c.pool.Report(url, time.Since(start), err)
+	return body, err
+}
+
+// orderedUrls returns the up to date endpoints by health score followed by the lagging endpoints by height.
+// Endpoints without a known height count as lagging.
+func (c *LcdClient) orderedUrls() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	maxHeight := int64(0)
+	for _, h := range c.heights {
+		maxHeight = max(maxHeight, h)
+	}
+	status := c.pool.Status()
+	upToDate := func(e EndpointStatus) bool {
+		return maxHeight == 0 || c.heights[e.Url] >= maxHeight-LCD_MAX_BLOCK_LAG
+	}
+	sort.SliceStable(status, func(i, j int) bool {
+		a, b := status[i], status[j]
+		if upToDate(a) != upToDate(b) {
+			return upToDate(a)
+		}
+		if !upToDate(a) && c.heights[a.Url] != c.heights[b.Url] {
+			return c.heights[a.Url] > c.heights[b.Url]
+		}
+		return a.score() < b.score()
+	})
+
+	urls := []string{}
+	for _, e := range status {
+		urls = append(urls, e.Url)
+	}
+	return urls
+}
+
+// refreshHeights starts a check of the latest height of every endpoint in the background once the last check is older
+// than LCD_HEIGHT_CHECK_INTERVAL. Only the first check is waited for, so that the endpoints are ordered by height from the
+// first query on. A single endpoint is never checked, there is nothing to fail over to.
+func (c *LcdClient) refreshHeights(ctx context.Context) {
+	urls := c.pool.Urls()
+	if len(urls) < 2 {
+		return
+	}
+	c.mu.Lock()
+	if time.Since(c.checkedAt) >= LCD_HEIGHT_CHECK_INTERVAL {
+		c.checkedAt = time.Now()
+		go func() {
+			// not bound to ctx, the check outlives the query that started it
+			checkCtx, cancel := context.WithTimeout(context.Background(), LCD_HEIGHT_CHECK_TIMEOUT)
+			defer cancel()
+			c.checkHeights(checkCtx, urls)
+			c.firstCheckOnce.Do(func() { close(c.firstCheck) })
+		}()
+	}
+	c.mu.Unlock()
+
+	select {
+	case <-c.firstCheck:
+	case <-ctx.Done():
+	}
+}
+
+// checkHeights queries the latest height of the endpoints whose breaker allows it. Endpoints that fail or time out have
+// an unknown height until the next check.
+func (c *LcdClient) checkHeights(ctx context.Context, urls []string) {
+	var wg sync.WaitGroup
+	for _, url := range urls {
+		if ok, _ := c.pool.Allow(url); !ok {
+			continue
+		}
+		wg.Add(1)
+		go func(url string) {
+			defer wg.Done()
+			start := time.Now()
+			height, err := latestHeight(ctx, url)
+			c.pool.Report(url, time.Since(start), err)
+			if err != nil {
+				c.logger.Warn().Err(err).Str("url", url).Msg("failed to get latest lcd height")
+				height = 0
+			}
+			c.mu.Lock()
+			c.heights[url] = height
+			c.mu.Unlock()
+		}(url)
+	}
+	wg.Wait()
+}
+
+// Status returns the health and the latest height of every endpoint
+func (c *LcdClient) Status() []LcdEndpointStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	status := []LcdEndpointStatus{}
+	for _, e := range c.pool.Status() {
+		status = append(status, LcdEndpointStatus{EndpointStatus: e, LatestHeight: c.heights[e.Url]})
+	}
+	return status
+}
+
+func latestHeight(ctx context.Context, apiUrl string) (int64, error) {
+	body, err := lcdGet(ctx, apiUrl+LATEST_BLOCK_QUERY)
+	if err != nil {
+		return 0, err
+	}
+	var data ShortBlockResp
+	if err := json.Unmarshal(body, &data); err != nil {
+		return 0, err
+	}
+	height, err := strconv.ParseInt(data.Block.Header.Height, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid latest height %q: %w", data.Block.Header.Height, err)
+	}
+	return height, nil
+}
+
+// lcdGet returns the body of a 200 response. Rate limited requests return RateLimitErr.
+func lcdGet(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Add("Accept", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return nil, RateLimitErr
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s, code: %d", HttpCodeCheck(resp.StatusCode), resp.StatusCode)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// LcdUrls returns api_url followed by api_urls without duplicates
+func (c OsmosisConfig) LcdUrls() []string {
+	urls := []string{}
+	for _, url := range append([]string{c.ApiUrl}, c.ApiUrls...) {
+		url = strings.TrimRight(strings.TrimSpace(url), "/")
+		if url == "" || slices.Contains(urls, url) {
+			continue
+		}
+		urls = append(urls, url)
+	}
+	return urls
+}
@@ -0,0 +1,145 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeLcd serves the latest height and responds to other paths with the body or the failure code
+func fakeLcd(t *testing.T, height int64, code int, body string, queries *atomic.Int32) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == LATEST_BLOCK_QUERY {
+			var resp ShortBlockResp
+			resp.Block.Header.Height = strconv.FormatInt(height, 10)
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		queries.Add(1)
+		if code != http.StatusOK {
+			w.WriteHeader(code)
+			return
+		}
+		w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestLcdClientFailover(t *testing.T) {
+	m := newTestMonitor()
+	var laggingQueries, failingQueries, healthyQueries atomic.Int32
+	lagging := fakeLcd(t, 100, http.StatusOK, "lagging", &laggingQueries)
+	failing := fakeLcd(t, 200, http.StatusServiceUnavailable, "", &failingQueries)
+	healthy := fakeLcd(t, 195, http.StatusOK, "healthy", &healthyQueries)
+
+	c := NewLcdClient([]string{lagging.URL, failing.URL, healthy.URL}, m.logger)
+	// the failing endpoint has the better score and is queried first
+	c.pool.Report(failing.URL, time.Millisecond, nil)
+	c.pool.Report(healthy.URL, 100*time.Millisecond, nil)
+	for i := 0; i < DefaultCircuitBreakerConfig.FailureThreshold+1; i++ {
+		body, err := c.Get(context.Background(), "/cosmos/tx/v1beta1/txs")
+		require.NoError(t, err)
+		assert.Equal(t, "healthy", string(body))
+	}
+	// the lagging endpoint is never used while an up to date one works and the failing one is skipped once its breaker opened
+	assert.Zero(t, laggingQueries.Load())
+	assert.Equal(t, int32(DefaultCircuitBreakerConfig.FailureThreshold), failingQueries.Load())
+
+	status := c.Status()
+	require.Len(t, status, 3)
+	assert.Equal(t, int64(100), status[0].LatestHeight)
+	assert.Equal(t, ENDPOINT_STATE_OPEN, status[1].State)
+	assert.Equal(t, DefaultCircuitBreakerConfig.FailureThreshold, status[1].Failures)
+	assert.Equal(t, int64(195), status[2].LatestHeight)
+	assert.Equal(t, ENDPOINT_STATE_CLOSED, status[2].State)
+}
+
+func TestLcdClientSingleEndpoint(t *testing.T) {
+	m := newTestMonitor()
+	var queries atomic.Int32
+	srv := fakeLcd(t, 0, http.StatusBadGateway, "", &queries)
+
+	// a single endpoint is queried even with an open breaker
+	c := NewLcdClient([]string{srv.URL}, m.logger)
+	for i := 0; i < DefaultCircuitBreakerConfig.FailureThreshold+2; i++ {
+		_, err := c.Get(context.Background(), "/cosmos/bank/v1beta1/balances/osmo1")
+		assert.Error(t, err)
+	}
+	assert.Equal(t, int32(DefaultCircuitBreakerConfig.FailureThreshold+2), queries.Load())
+}
+
+func TestLcdClientCancelledQueryReleasesTrial(t *testing.T) {
+	m := newTestMonitor()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	t.Cleanup(srv.Close)
+
+	c := NewLcdClient([]string{srv.URL}, m.logger)
+	c.pool.Report(srv.URL, 0, RateLimitErr)
+	c.pool.now = func() time.Time { return time.Now().Add(DefaultCircuitBreakerConfig.Cooldown) }
+
+	// the trial request of the half open endpoint is cancelled
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	_, err := c.Get(ctx, "/cosmos/tx/v1beta1/txs")
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	ok, _ := c.pool.Allow(srv.URL)
+	assert.True(t, ok)
+	assert.Equal(t, 1, c.Status()[0].Requests)
+}
+
+func TestLcdClientHeightCheckDoesNotBlock(t *testing.T) {
+	m := newTestMonitor()
+	var queries atomic.Int32
+	healthy := fakeLcd(t, 100, http.StatusOK, "healthy", &queries)
+	var hang atomic.Bool
+	unblock := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if hang.Load() {
+			select {
+			case <-r.Context().Done():
+			case <-unblock:
+			}
+			return
+		}
+		var resp ShortBlockResp
+		resp.Block.Header.Height = "100"
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(slow.Close)
+	t.Cleanup(func() { close(unblock) })
+
+	c := NewLcdClient([]string{healthy.URL, slow.URL}, m.logger)
+	c.pool.Report(healthy.URL, time.Millisecond, nil)
+	c.pool.Report(slow.URL, time.Second, nil)
+	_, err := c.Get(context.Background(), "/cosmos/tx/v1beta1/txs")
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), c.Status()[1].LatestHeight)
+
+	// the next check doesn't wait for the endpoint that stopped responding
+	hang.Store(true)
+	c.mu.Lock()
+	c.checkedAt = time.Now().Add(-LCD_HEIGHT_CHECK_INTERVAL)
+	c.mu.Unlock()
+	start := time.Now()
+	body, err := c.Get(context.Background(), "/cosmos/tx/v1beta1/txs")
+	require.NoError(t, err)
+	assert.Equal(t, "healthy", string(body))
+	assert.Less(t, time.Since(start), time.Second)
+}
+
+func TestLcdUrls(t *testing.T) {
+	cfg := OsmosisConfig{ChainEntry: ChainEntry{ApiUrl: "https://lcd.osmosis.zone/"}, ApiUrls: []string{"https://lcd.osmosis.zone", " https://osmosis-rest.publicnode.com", ""}}
+	assert.Equal(t, []string{"https://lcd.osmosis.zone", "https://osmosis-rest.publicnode.com"}, cfg.LcdUrls())
+}
@@ -1,10 +1,12 @@
 package monitor
 
 import (
+	"context"
+
 	_ "github.com/mattn/go-sqlite3"
 )
 
-func (m *Monitor) LoadFromFile(path string, saveRawResponses bool) {
+func (m *Monitor) LoadFromFile(ctx context.Context, path string, saveRawResponses bool) {
 	m.logger.Info().Str("file", path).Msg("loading orders from file")
 	orders, responses, err := m.OrdersFromFile(path)
 	if err != nil {
@@ -32,7 +34,7 @@ func (m *Monitor) LoadFromFile(path string, saveRawResponses bool) {
 				Msg("skipping order filled from osmosis")
 			continue
 		}
-		err := m.InsertOrderFilled(o)
+		err := m.InsertOrderFilled(ctx, o)
 		if err != nil {
 			m.logger.Error().Err(err).
 				Str("tx_hash", o.TxHash).
@@ -44,13 +46,13 @@ func (m *Monitor) LoadFromFile(path string, saveRawResponses bool) {
 	}
 	if saveRawResponses {
 		for _, r := range responses {
-			m.InsertRawTxResponse(*r)
+			m.InsertRawTxResponse(ctx, *r)
 		}
 	}
 	m.logger.Info().Int("orders", len(orders)).Int("responses", len(responses)).Msg("wrote orders from file")
 }
 
-func (m *Monitor) LoadMissingOrderFromFile(path string) {
+func (m *Monitor) LoadMissingOrderFromFile(ctx context.Context, path string) {
 	m.logger.Info().Str("file", path).Msg("loading missing orders from file")
 
 	// get all tx_hashes from the db
@@ -85,7 +87,7 @@ func (m *Monitor) LoadMissingOrderFromFile(path string) {
 				Str("tx_hash", o.TxHash).
 				Int64("height", o.Height).
 				Msg("inserting order filled from osmosis")
-			err := m.InsertOrderFilled(o)
+			err := m.InsertOrderFilled(ctx, o)
 			if err != nil {
 				m.logger.Error().Err(err).
 					Str("tx_hash", o.TxHash).
@@ -0,0 +1,184 @@
+package monitor
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// amount range of orders above the largest fill stats range
+const ORDER_AMOUNT_RANGE_ABOVE = "10000+"
+
+// MarketFill is a fill attempt of any filler
+type MarketFill struct {
+	Filler       string
+	SourceDomain string
+	AmountIn     int64
+	Day          string // YYYY-MM-DD (UTC)
+	// failed fills are attempts that lost the order (e.g. it was already filled)
+	Success bool
+}
+
+// FillerShare is the part of the orders of a segment filled by the filler
+type FillerShare struct {
+	Filler      string `json:"filler"`
+	Orders      int64  `json:"orders"`
+	FailedFills int64  `json:"failed_fills"`
+	Volume      string `json:"volume"`
+	// orders / total orders of the segment
+	MarketShare float64 `json:"market_share"`
+	VolumeShare float64 `json:"volume_share"`
+	// orders / (orders + failed fills)
+	WinRate float64 `json:"win_rate"`
+}
+
+// MarketSegment are the orders of a source domain, amount range or day
+type MarketSegment struct {
+	Segment     string        `json:"segment"`
+	Network     string        `json:"network,omitempty"`
+	TotalOrders int64         `json:"total_orders"`
+	TotalVolume string        `json:"total_volume"`
+	Fillers     []FillerShare `json:"fillers"`
+}
+
+type MarketStats struct {
+	From          time.Time       `json:"from"`
+	To            time.Time       `json:"to"`
+	TotalOrders   int64           `json:"total_orders"`
+	TotalVolume   string          `json:"total_volume"`
+	Fillers       []FillerShare   `json:"fillers"`
+	SourceDomains []MarketSegment `json:"source_domains"`
+	AmountRanges  []MarketSegment `json:"amount_ranges"`
+	Days          []MarketSegment `json:"days"`
+}
+
+// GetMarketStats returns the market share of every filler over all orders filled in [from, to)
+// and per source domain, amount range and day
+func (m *Monitor) GetMarketStats(sourceDomain string, from, to time.Time, asInteger bool) (MarketStats, error) {
+	fills, err := m.GetDbMarketFills(sourceDomain, from.Unix(), to.Unix())
+	if err != nil {
+		return MarketStats{}, err
+	}
+	stats := aggregateMarket(fills, asInteger)
+	stats.From, stats.To = from, to
+	return stats, nil
+}
+
+type fillerTotals struct {
+	orders, failed, volume int64
+}
+
+type segmentTotals struct {
+	orders, volume int64
+	fillers        map[string]*fillerTotals
+}
+
+func (s *segmentTotals) add(f MarketFill) {
+	totals, ok := s.fillers[f.Filler]
+	if !ok {
+		totals = &fillerTotals{}
+		s.fillers[f.Filler] = totals
+	}
+	if !f.Success {
+		totals.failed++
+		return
+	}
+	totals.orders++
+	totals.volume += f.AmountIn
+	s.orders++
+	s.volume += f.AmountIn
+}
+
+func newSegmentTotals() *segmentTotals {
+	return &segmentTotals{fillers: map[string]*fillerTotals{}}
+}
+
+func addToSegment(segments map[string]*segmentTotals, key string, f MarketFill) {
+	if key == "" {
+		return
+	}
+	if _, ok := segments[key]; !ok {
+		segments[key] = newSegmentTotals()
+	}
+	segments[key].add(f)
+}
+
+func aggregateMarket(fills []MarketFill, asInteger bool) MarketStats {
+	total := newSegmentTotals()
+	domains, ranges, days := map[string]*segmentTotals{}, map[string]*segmentTotals{}, map[string]*segmentTotals{}
+	for _, f := range fills {
+		amountRange := orderAmountRange(f.AmountIn)
+		if amountRange == "" && f.AmountIn > 0 {
+			amountRange = ORDER_AMOUNT_RANGE_ABOVE
+		}
+		total.add(f)
+		addToSegment(domains, f.SourceDomain, f)
+		addToSegment(ranges, amountRange, f)
+		addToSegment(days, f.Day, f)
+	}
+
+	shares := func(s *segmentTotals) []FillerShare {
+		fillers := []FillerShare{}
+		for filler, t := range s.fillers {
+			fillers = append(fillers, FillerShare{
+				Filler:      filler,
+				Orders:      t.orders,
+				FailedFills: t.failed,
+				Volume:      formatUsdc(t.volume, asInteger),
+				MarketShare: ratio(t.orders, s.orders),
+				VolumeShare: ratio(t.volume, s.volume),
+				WinRate:     ratio(t.orders, t.orders+t.failed),
+			})
+		}
+		sort.Slice(fillers, func(i, j int) bool {
+			if fillers[i].Orders != fillers[j].Orders {
+				return fillers[i].Orders > fillers[j].Orders
+			}
+			return fillers[i].Filler < fillers[j].Filler
+		})
+		return fillers
+	}
+	segments := func(totals map[string]*segmentTotals, order []string, network bool) []MarketSegment {
+		result := []MarketSegment{}
+		for _, key := range order {
+			s, ok := totals[key]
+			if !ok {
+				continue
+			}
+			segment := MarketSegment{Segment: key, TotalOrders: s.orders, TotalVolume: formatUsdc(s.volume, asInteger), Fillers: shares(s)}
+			if network {
+				segment.Network = ChainIdToNetwork[key]
+			}
+			result = append(result, segment)
+		}
+		return result
+	}
+
+	rangeOrder := append(orderAmountRangeNames(), ORDER_AMOUNT_RANGE_ABOVE)
+
+	return MarketStats{
+		TotalOrders:   total.orders,
+		TotalVolume:   formatUsdc(total.volume, asInteger),
+		Fillers:       shares(total),
+		SourceDomains: segments(domains, sortedMapKeys(domains), true),
+		AmountRanges:  segments(ranges, rangeOrder, false),
+		Days:          segments(days, sortedMapKeys(days), false),
+	}
+}
+
+func sortedMapKeys[V any](m map[string]V) []string {
+	keys := []string{}
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// ratio rounded to 4 decimals, 0 if total is 0
+func ratio(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)/float64(total)*10000) / 10000
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregateMarket(t *testing.T) {
+	fills := []MarketFill{
+		{Filler: "osmo1us", SourceDomain: "42161", AmountIn: 100_000_000, Day: "2025-03-19", Success: true},
+		{Filler: "osmo1us", SourceDomain: "42161", AmountIn: 300_000_000, Day: "2025-03-19", Success: false},
+		{Filler: "osmo1them", SourceDomain: "42161", AmountIn: 300_000_000, Day: "2025-03-19", Success: true},
+		{Filler: "osmo1them", SourceDomain: "1", AmountIn: 20_000_000_000, Day: "2025-03-20", Success: true},
+	}
+
+	stats := aggregateMarket(fills, false)
+	assert.Equal(t, int64(3), stats.TotalOrders)
+	assert.Equal(t, "20400", stats.TotalVolume)
+	assert.Equal(t, []FillerShare{
+		{Filler: "osmo1them", Orders: 2, Volume: "20300", MarketShare: 0.6667, VolumeShare: 0.9951, WinRate: 1},
+		{Filler: "osmo1us", Orders: 1, FailedFills: 1, Volume: "100", MarketShare: 0.3333, VolumeShare: 0.0049, WinRate: 0.5},
+	}, stats.Fillers)
+
+	require.Len(t, stats.SourceDomains, 2)
+	assert.Equal(t, "1", stats.SourceDomains[0].Segment)
+	assert.Equal(t, ETHEREUM_NETWORK, stats.SourceDomains[0].Network)
+	assert.Equal(t, "42161", stats.SourceDomains[1].Segment)
+	assert.Equal(t, int64(2), stats.SourceDomains[1].TotalOrders)
+
+	// we lost the only order in the 250-500 range
+	ranges := map[string]MarketSegment{}
+	for _, r := range stats.AmountRanges {
+		ranges[r.Segment] = r
+	}
+	assert.Equal(t, []string{"1-250", "250-500", ORDER_AMOUNT_RANGE_ABOVE}, []string{stats.AmountRanges[0].Segment, stats.AmountRanges[1].Segment, stats.AmountRanges[2].Segment})
+	assert.Equal(t, int64(1), ranges["250-500"].TotalOrders)
+	assert.Equal(t, []FillerShare{
+		{Filler: "osmo1them", Orders: 1, Volume: "300", MarketShare: 1, VolumeShare: 1, WinRate: 1},
+		{Filler: "osmo1us", FailedFills: 1, Volume: "0", WinRate: 0},
+	}, ranges["250-500"].Fillers)
+
+	require.Len(t, stats.Days, 2)
+	assert.Equal(t, "2025-03-19", stats.Days[0].Segment)
+	assert.Equal(t, int64(2), stats.Days[0].TotalOrders)
+}
+
+func TestGetMarketStats(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+	orders := seedFixtureOrders(t, m)
+
+	from := time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC)
+	stats, err := m.GetMarketStats("", from, from.AddDate(0, 0, 1), true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(orders)), stats.TotalOrders)
+	require.Len(t, stats.Days, 1)
+	assert.Equal(t, "2025-03-19", stats.Days[0].Segment)
+
+	// same amount ranges as the fill stats
+	details, err := m.GetOrderDetailsByRange(ARBITRUM_NETWORK, 0, "")
+	require.NoError(t, err)
+	for _, d := range details {
+		assert.NotEmpty(t, d.AmountRange)
+	}
+
+	// fills outside of the range are not counted
+	stats, err = m.GetMarketStats("", from.AddDate(0, 0, 1), from.AddDate(0, 0, 2), true)
+	require.NoError(t, err)
+	assert.Zero(t, stats.TotalOrders)
+	assert.Empty(t, stats.Fillers)
+}
@@ -0,0 +1,171 @@
+package monitor
+
+import (
+	"errors"
+	"math/big"
+	"time"
+)
+
+// whether the solver could have filled an order filled by another filler
+const (
+	MISSED_ORDER_WITH_CAPITAL     = "with_capital"
+	MISSED_ORDER_WITHOUT_CAPITAL  = "without_capital"
+	MISSED_ORDER_UNKNOWN_BALANCE  = "unknown_balance"
+	DEFAULT_MISSED_ORDERS_LIMIT   = 100
+	MISSED_ORDERS_BALANCE_MAX_AGE = time.Hour
+)
+
+var MissingSolverAddressErr = errors.New("osmosis solver and balance addresses are required")
+
+// OrderWithBalance is a fill of another filler and the USDC balance of the solver at the block time of the fill
+type OrderWithBalance struct {
+	TxHash        string `json:"tx_hash"`
+	Nonce         uint32 `json:"nonce"`
+	Filler        string `json:"filler"`
+	SourceDomain  string `json:"source_domain"`
+	AmountIn      string `json:"amount_in"`
+	AmountOut     string `json:"amount_out"`
+	SolverRevenue int64  `json:"solver_revenue"`
+	Timestamp     int64  `json:"timestamp"`
+	// empty if no balance was stored before the fill
+	Balance          string `json:"balance"`
+	BalanceTimestamp int64  `json:"balance_timestamp"`
+}
+
+// capital classifies the order by the balance snapshot. Snapshots older than maxAge are unknown balances.
+func (o OrderWithBalance) capital(maxAge time.Duration) string {
+	if o.Balance == "" || o.Timestamp-o.BalanceTimestamp > int64(maxAge.Seconds()) {
+		return MISSED_ORDER_UNKNOWN_BALANCE
+	}
+	balance, ok := new(big.Int).SetString(o.Balance, 10)
+	amountOut, ok2 := new(big.Int).SetString(o.AmountOut, 10)
+	if !ok || !ok2 {
+		return MISSED_ORDER_UNKNOWN_BALANCE
+	}
+	if balance.Cmp(amountOut) >= 0 {
+		return MISSED_ORDER_WITH_CAPITAL
+	}
+	return MISSED_ORDER_WITHOUT_CAPITAL
+}
+
+// MissedOrdersSegment counts the orders filled by others in a source domain or amount range.
+// Volume and revenue are the amount_in and revenue of the orders the solver had the capital for.
+type MissedOrdersSegment struct {
+	Segment        string `json:"segment,omitempty"`
+	Network        string `json:"network,omitempty"`
+	Orders         int64  `json:"orders"`
+	WithCapital    int64  `json:"with_capital"`
+	WithoutCapital int64  `json:"without_capital"`
+	UnknownBalance int64  `json:"unknown_balance"`
+	MissedVolume   string `json:"missed_volume"`
+	MissedRevenue  string `json:"missed_revenue"`
+}
+
+type MissedOrdersReport struct {
+	Filler string    `json:"filler"`
+	From   time.Time `json:"from"`
+	To     time.Time `json:"to"`
+	MissedOrdersSegment
+	SourceDomains []MissedOrdersSegment `json:"source_domains"`
+	AmountRanges  []MissedOrdersSegment `json:"amount_ranges"`
+	// newest orders the solver had the capital for
+	MissedOrders []OrderWithBalance `json:"missed_orders"`
+}
+
+// GetMissedOrdersReport checks for every order filled by another filler in [from, to) whether the solver had
+// enough USDC on osmosis to fill it, using the last balance snapshot before the fill
+func (m *Monitor) GetMissedOrdersReport(from, to time.Time, limit int, asInteger bool) (MissedOrdersReport, error) {
+	filler := m.cfg.Osmosis.SolverAddress
+	address := m.cfg.Osmosis.Address
+	if filler == "" || address == "" {
+		return MissedOrdersReport{}, MissingSolverAddressErr
+	}
+
+	orders, err := m.GetDbOrdersWithBalance(filler, OSMOSIS_NETWORK, address, from.Unix(), to.Unix())
+	if err != nil {
+		return MissedOrdersReport{}, err
+	}
+	report := aggregateMissedOrders(orders, limit, asInteger)
+	report.Filler, report.From, report.To = filler, from, to
+	return report, nil
+}
+
+type missedTotals struct {
+	orders, withCapital, withoutCapital, unknown int64
+	volume, revenue                              int64
+}
+
+func (t *missedTotals) add(capital string, o OrderWithBalance) {
+	t.orders++
+	switch capital {
+	case MISSED_ORDER_WITH_CAPITAL:
+		t.withCapital++
+		amountIn, _ := new(big.Int).SetString(o.AmountIn, 10)
+		if amountIn != nil {
+			t.volume += amountIn.Int64()
+		}
+		t.revenue += o.SolverRevenue
+	case MISSED_ORDER_WITHOUT_CAPITAL:
+		t.withoutCapital++
+	default:
+		t.unknown++
+	}
+}
+
+func aggregateMissedOrders(orders []OrderWithBalance, limit int, asInteger bool) MissedOrdersReport {
+	segment := func(name string, t *missedTotals) MissedOrdersSegment {
+		return MissedOrdersSegment{
+			Segment:        name,
+			Orders:         t.orders,
+			WithCapital:    t.withCapital,
+			WithoutCapital: t.withoutCapital,
+			UnknownBalance: t.unknown,
+			MissedVolume:   formatUsdc(t.volume, asInteger),
+			MissedRevenue:  formatUsdc(t.revenue, asInteger),
+		}
+	}
+
+	total := &missedTotals{}
+	domains, ranges := map[string]*missedTotals{}, map[string]*missedTotals{}
+	report := MissedOrdersReport{MissedOrders: []OrderWithBalance{}}
+	for _, o := range orders {
+		capital := o.capital(MISSED_ORDERS_BALANCE_MAX_AGE)
+		total.add(capital, o)
+
+		if _, ok := domains[o.SourceDomain]; !ok {
+			domains[o.SourceDomain] = &missedTotals{}
+		}
+		domains[o.SourceDomain].add(capital, o)
+
+		amountIn, _ := new(big.Int).SetString(o.AmountIn, 10)
+		amountRange := ORDER_AMOUNT_RANGE_ABOVE
+		if amountIn != nil && amountIn.IsInt64() {
+			if r := orderAmountRange(amountIn.Int64()); r != "" {
+				amountRange = r
+			}
+		}
+		if _, ok := ranges[amountRange]; !ok {
+			ranges[amountRange] = &missedTotals{}
+		}
+		ranges[amountRange].add(capital, o)
+
+		if capital == MISSED_ORDER_WITH_CAPITAL && len(report.MissedOrders) < limit {
+			report.MissedOrders = append(report.MissedOrders, o)
+		}
+	}
+
+	report.MissedOrdersSegment = segment("", total)
+	report.SourceDomains = []MissedOrdersSegment{}
+	for _, sourceDomain := range sortedMapKeys(domains) {
+		s := segment(sourceDomain, domains[sourceDomain])
+		s.Network = ChainIdToNetwork[sourceDomain]
+		report.SourceDomains = append(report.SourceDomains, s)
+	}
+	report.AmountRanges = []MissedOrdersSegment{}
+	for _, r := range append(orderAmountRangeNames(), ORDER_AMOUNT_RANGE_ABOVE) {
+		if t, ok := ranges[r]; ok {
+			report.AmountRanges = append(report.AmountRanges, segment(r, t))
+		}
+	}
+	return report
+}
@@ -0,0 +1,73 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMissedOrdersCapital(t *testing.T) {
+	order := OrderWithBalance{AmountOut: "1000000", Timestamp: 10_000, Balance: "1000000", BalanceTimestamp: 9_000}
+	assert.Equal(t, MISSED_ORDER_WITH_CAPITAL, order.capital(time.Hour))
+
+	order.Balance = "999999"
+	assert.Equal(t, MISSED_ORDER_WITHOUT_CAPITAL, order.capital(time.Hour))
+
+	// the snapshot is too old to tell
+	order.BalanceTimestamp = 10_000 - 3601
+	assert.Equal(t, MISSED_ORDER_UNKNOWN_BALANCE, order.capital(time.Hour))
+
+	order.Balance = ""
+	assert.Equal(t, MISSED_ORDER_UNKNOWN_BALANCE, order.capital(time.Hour))
+}
+
+func TestGetMissedOrdersReport(t *testing.T) {
+	cfg := &Config{}
+	m := newTestMonitorWithDB(t, cfg)
+	ctx := context.Background()
+
+	_, err := m.GetMissedOrdersReport(time.Now(), time.Now(), 10, true)
+	assert.ErrorIs(t, err, MissingSolverAddressErr)
+	cfg.Osmosis.Address = "osmo1balance"
+
+	orders := seedFixtureOrders(t, m)
+
+	// we are the filler of the first fill, the others were filled by someone else
+	cfg.Osmosis.SolverAddress = orders[0].Filler
+	missed := []DbOrderFilled{}
+	for _, o := range orders {
+		if o.Filler != orders[0].Filler {
+			missed = append(missed, o)
+		}
+	}
+	require.NotEmpty(t, missed)
+
+	// enough USDC for the newest missed order only, balance snapshot just before it
+	newest := missed[0]
+	var newestTs int64
+	require.NoError(t, m.db.QueryRow(`SELECT timestamp FROM osmo_block_times WHERE height = ?`, newest.Height).Scan(&newestTs))
+	amountOut, err := strconv.ParseInt(newest.AmountOut, 10, 64)
+	require.NoError(t, err)
+	m.InsertBalance(ctx, DbBalance{Timestamp: newestTs - 60, Balance: strconv.FormatInt(amountOut, 10), Exponent: 6, Token: "USDC", Address: "osmo1balance", Network: OSMOSIS_NETWORK})
+
+	from := time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC)
+	report, err := m.GetMissedOrdersReport(from, from.AddDate(0, 0, 1), 10, true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(missed)), report.Orders)
+	assert.Equal(t, int64(1), report.WithCapital)
+	assert.Equal(t, int64(len(missed)-1), report.WithoutCapital+report.UnknownBalance)
+	assert.Equal(t, newest.AmountIn, report.MissedVolume)
+	assert.Equal(t, strconv.FormatInt(newest.SolverRevenue, 10), report.MissedRevenue)
+	require.Len(t, report.MissedOrders, 1)
+	assert.Equal(t, newest.TxHash, report.MissedOrders[0].TxHash)
+
+	domains := int64(0)
+	for _, d := range report.SourceDomains {
+		domains += d.Orders
+	}
+	assert.Equal(t, report.Orders, domains)
+}
@@ -1,13 +1,13 @@
 package monitor
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"fmt"
 	"os"
 	"strings"
 	"sync"
-	"time"
 
 	"cosmossdk.io/x/tx/decode"
 	wasmtypes "github.com/CosmWasm/wasmd/x/wasm/types"
@@ -27,6 +27,9 @@ type ChainEntry struct {
 	ApiUrl      string `json:"api_url,omitempty" yaml:"api_url,omitempty" toml:"api_url,omitempty"`
 	UsdcAddress string `json:"usdc_address,omitempty" yaml:"usdc_address,omitempty" toml:"usdc_address,omitempty"`
 	Address     string `json:"address,omitempty" yaml:"address,omitempty" toml:"address,omitempty"`
+	// interval ("@every 10m") or cron expression -- defaults to the -interval flag
+	BalancesSchedule  string `json:"balances_schedule,omitempty" yaml:"balances_schedule,omitempty" toml:"balances_schedule,omitempty"`
+	TxHistorySchedule string `json:"tx_history_schedule,omitempty" yaml:"tx_history_schedule,omitempty" toml:"tx_history_schedule,omitempty"`
 }
 
 type SolverConfig struct {
@@ -37,6 +40,12 @@ type SolverConfig struct {
 type OsmosisConfig struct {
 	ChainEntry
 	SolverConfig
+	// optional rpc websocket (wss://rpc.osmosis.zone/websocket) -- orders are subscribed to and the LCD is only polled to catch up
+	WebsocketUrl string `json:"websocket_url,omitempty" yaml:"websocket_url,omitempty" toml:"websocket_url,omitempty"`
+	// additional LCDs -- queries go to the most up-to-date healthy one of api_url and api_urls
+	ApiUrls []string `json:"api_urls,omitempty" yaml:"api_urls,omitempty" toml:"api_urls,omitempty"`
+	// LCDs used to backfill block times (-get-blocks) -- defaults to a list of public LCDs
+	BlockTimeUrls []string `json:"block_time_urls,omitempty" yaml:"block_time_urls,omitempty" toml:"block_time_urls,omitempty"`
 }
 
 type Config struct {
@@ -45,6 +54,9 @@ type Config struct {
 	Base      ChainEntry    `json:"base,omitempty" yaml:"base,omitempty" toml:"base,omitempty"`
 	Osmosis   OsmosisConfig `json:"osmosis,omitempty" yaml:"osmosis,omitempty" toml:"osmosis,omitempty"`
 	Avalanche ChainEntry    `json:"avalanche,omitempty" yaml:"avalanche,omitempty" toml:"avalanche,omitempty"`
+	// additional networks -- legacy blocks above are merged into this list when the config is loaded
+	Chains    []ChainConfig   `json:"chains,omitempty" yaml:"chains,omitempty" toml:"chains,omitempty"`
+	Schedules SchedulesConfig `json:"schedules,omitempty" yaml:"schedules,omitempty" toml:"schedules,omitempty"`
 }
 
 func MustLoadConfig(path string) *Config {
@@ -58,6 +70,14 @@ func MustLoadConfig(path string) *Config {
 	if err = toml.Unmarshal(file, cfg); err != nil {
 		panic(err)
 	}
+
+	if err = cfg.resolveChains(); err != nil {
+		panic(err)
+	}
+
+	if err = cfg.validateSchedules(); err != nil {
+		panic(err)
+	}
 	return cfg
 }
 
@@ -69,14 +89,20 @@ type Monitor struct {
 	db                *sql.DB
 	cfg               *Config
 	logger            *zerolog.Logger
-	apiUrl            string
+	osmosis           *LcdClient
+	chains            []ChainAdapter
+	supervisor        *Supervisor
+	// serialise the jobs of a network, see chainLock
+	chainLocksMu sync.Mutex
+	chainLocks   map[string]*sync.Mutex
 }
 
-func NewMonitor(db *sql.DB, cfg *Config, logger *zerolog.Logger, apiUrl string) *Monitor {
+// apiUrls are the osmosis LCDs, see OsmosisConfig.LcdUrls
+func NewMonitor(db *sql.DB, cfg *Config, logger *zerolog.Logger, apiUrls []string) *Monitor {
 	InitDB(db)
 
 	enc := MakeEncodingConfig()
-	return &Monitor{
+	m := &Monitor{
 		Codec:             enc.Marshaler,
 		interfaceRegistry: enc.InterfaceRegistry,
 		amino:             enc.Amino,
@@ -84,94 +110,75 @@ func NewMonitor(db *sql.DB, cfg *Config, logger *zerolog.Logger, apiUrl string)
 		db:                db,
 		cfg:               cfg,
 		logger:            logger,
-		apiUrl:            apiUrl,
+		osmosis:           NewLcdClient(apiUrls, logger),
 	}
+	m.chains = m.buildChainAdapters()
+	m.supervisor = NewSupervisor(DefaultBackoffConfig, logger)
+	for _, j := range m.pollJobs(false) {
+		m.supervisor.Register(j.Name)
+	}
+	return m
 }
 
-func (m *Monitor) RunAll(wg *sync.WaitGroup, saveRawResponses bool) {
-	ethRetry := 0
-	arbitrumRetry := 0
-	baseRetry := 0
-	maxRetry := 5
-	limitSleep := 60 * time.Second
+func (m *Monitor) Config() *Config {
+	return m.cfg
+}
 
-	wg.Add(6)
-	go func() {
-		defer wg.Done()
-		m.RunOrders(saveRawResponses)
-	}()
-	go func() {
-		defer wg.Done()
-		m.RunOsmosisBalances()
-	}()
-	go func() {
-		defer wg.Done()
-		m.RunAvalancheBalances()
-		time.Sleep(limitSleep)
-		m.RunAvalancheTxHistory(saveRawResponses)
-	}()
-	go func() {
-		defer wg.Done()
-		for {
-			code := m.RunEthereumBalances()
-			if code != 200 {
-				logMsg := HttpCodeCheck(code)
-				m.logger.Error().Msgf("%s, code: %d", logMsg, code)
-				time.Sleep(limitSleep)
-				ethRetry++
-				if ethRetry > maxRetry {
-					m.logger.Error().Msg("Ethereum balances RPC query retries exceeded, exiting")
-					os.Exit(1)
-				}
-				continue
-			}
-			break
-		}
-		m.RunEthereumTxHistory(saveRawResponses)
-	}()
+// OsmosisEndpoints exposes the health of the osmosis LCDs
+func (m *Monitor) OsmosisEndpoints() []LcdEndpointStatus {
+	return m.osmosis.Status()
+}
+
+// Supervisor exposes the worker states
+func (m *Monitor) Supervisor() *Supervisor {
+	return m.supervisor
+}
+
+// fetches osmosis fill orders -- network workers are named <network>_balances and <network>_tx_history
+const ORDERS_WORKER = "orders"
+
+// runExclusive starts fn unless the previous run of the worker is still in progress, in which case the tick is skipped
+func (m *Monitor) runExclusive(wg *sync.WaitGroup, worker string, fn func()) {
+	if !m.supervisor.TryLock(worker) {
+		m.logger.Warn().Str("worker", worker).Msg("previous run still in progress -- skipping tick")
+		return
+	}
+	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		for {
-			code := m.RunArbitrumBalances()
-			if code != 200 {
-				logMsg := HttpCodeCheck(code)
-				m.logger.Error().Msgf("%s, code: %d", logMsg, code)
-				time.Sleep(limitSleep)
-				arbitrumRetry++
-				if arbitrumRetry > maxRetry {
-					m.logger.Error().Msg("Arbitrum balances RPC query retries exceeded, exiting")
-					os.Exit(1)
-				}
-				continue
-			}
-			break
-		}
-		m.RunArbitrumTxHistory(saveRawResponses)
+		defer m.supervisor.Unlock(worker)
+		fn()
 	}()
-	go func() {
-		defer wg.Done()
-		for {
-			code := m.RunBaseBalances()
-			if code != 200 {
-				logMsg := HttpCodeCheck(code)
-				m.logger.Error().Msgf("%s, code: %d", logMsg, code)
-				time.Sleep(limitSleep)
-				baseRetry++
-				if baseRetry > maxRetry {
-					m.logger.Error().Msg("Base balances RPC query retries exceeded, exiting")
-					os.Exit(1)
-				}
-				continue
-			}
-			break
+}
+
+// RunAll starts every polling job once; cancelling the context stops pending requests and retries
+func (m *Monitor) RunAll(ctx context.Context, wg *sync.WaitGroup, saveRawResponses bool) {
+	for _, job := range m.pollJobs(saveRawResponses) {
+		j := job
+		m.runExclusive(wg, j.Name, func() { j.Run(ctx) })
+	}
+}
+
+// executeMsg is a wasm execute message of a tx and its index inside the authz.MsgExec (-1 for wasmExec)
+type executeMsg struct {
+	sender        string
+	body          []byte
+	authzMsgIndex int
+}
+
+// containsAction is true if the body of the message mentions one of the actions
+func containsAction(body []byte, actions []string) bool {
+	for _, action := range actions {
+		if strings.Contains(string(body), action) {
+			return true
 		}
-		m.RunBaseTxHistory(saveRawResponses)
-	}()
+	}
+	return false
 }
 
 // message can be authz.MsgExec or wasmtypes.MsgExecuteContract
-// the function will return the inner FillOrderEnvelope message
-func (m *Monitor) getFillOrderBodyBytes(msg []byte) ([]byte, error) {
+// the function will return the inner execute messages of the given actions -- an authz.MsgExec can batch multiple executes
+func (m *Monitor) getExecuteMsgs(msg []byte, actions ...string) ([]executeMsg, error) {
 	// authzExec := authz.MsgExec{}
 	wasmExec := wasmtypes.MsgExecuteContract{}
 
@@ -180,12 +187,12 @@ func (m *Monitor) getFillOrderBodyBytes(msg []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to unmarshal wasm: %w", err)
 	}
 
-	// this was wasmExec but it can be the wrong type (we only want fillOrder)
+	// this was wasmExec but it can be the wrong type (e.g. we only want fillOrder)
 	if len(wasmExec.Msg.Bytes()) > 0 {
-		if strings.Contains(string(wasmExec.Msg.Bytes()), "fill_order") {
-			return wasmExec.Msg.Bytes(), nil
+		if containsAction(wasmExec.Msg.Bytes(), actions) {
+			return []executeMsg{{sender: wasmExec.Sender, body: wasmExec.Msg.Bytes(), authzMsgIndex: -1}}, nil
 		}
-		return nil, fmt.Errorf("wasmExec but not fillOrder")
+		return nil, fmt.Errorf("wasmExec but not %s", strings.Join(actions, ", "))
 	}
 
 	// for some reason there's no error but the length on the wasm exec was 0
@@ -195,53 +202,60 @@ func (m *Monitor) getFillOrderBodyBytes(msg []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to unmarshal authz: %w", err)
 	}
 
-	if len(authzExec.Msgs) > 0 {
-		// loop over all messages and set the first message that matches
-		// fillOrder transaction as the wasmExec to be returned
-		for _, msg := range authzExec.Msgs {
-			temp := wasmtypes.MsgExecuteContract{}
-			if strings.Contains(string(msg.Value), "fill_order") {
-				if err := m.Codec.Unmarshal(msg.Value, &temp); err != nil {
-					return nil, fmt.Errorf("found fillOrder but failed to unmarshal %w", err)
-				}
-				// fillOrder was found and processed
-				wasmExec = temp
-				break
-			}
+	// collect every message that matches one of the actions
+	execs := []executeMsg{}
+	for i, msg := range authzExec.Msgs {
+		if !containsAction(msg.Value, actions) {
 			continue
 		}
-		if wasmExec.Msg == nil || len(wasmExec.Msg.Bytes()) == 0 {
-			return nil, fmt.Errorf("failed to unmarshal wasm inside authz - no fillOrder found")
+		temp := wasmtypes.MsgExecuteContract{}
+		if err := m.Codec.Unmarshal(msg.Value, &temp); err != nil {
+			return nil, fmt.Errorf("found %s but failed to unmarshal %w", strings.Join(actions, ", "), err)
+		}
+		if temp.Msg == nil || len(temp.Msg.Bytes()) == 0 {
+			continue
 		}
+		execs = append(execs, executeMsg{sender: temp.Sender, body: temp.Msg.Bytes(), authzMsgIndex: i})
+	}
+	if len(execs) == 0 {
+		return nil, fmt.Errorf("failed to unmarshal wasm inside authz - no %s found", strings.Join(actions, ", "))
 	}
 
-	return wasmExec.Msg.Bytes(), nil
+	return execs, nil
 }
 
-func (m *Monitor) DecodeTxResponse(r *sdktypes.TxResponse) []FillOrderEnvelope {
-	fillOrders := []FillOrderEnvelope{}
+// decodeTxMessages calls fn with the raw value and index of every message of the tx
+func (m *Monitor) decodeTxMessages(r *sdktypes.TxResponse, fn func(i int, msg []byte)) {
 	decodedTx, err := m.decoder.Decode(r.Tx.Value)
 	if err != nil {
-		return fillOrders
+		return
 	}
 
-	for _, msg := range decodedTx.Messages {
+	for i, msg := range decodedTx.Messages {
 		anyMsg, err := anyutil.New(msg)
 		if err != nil {
 			continue
 		}
+		fn(i, anyMsg.Value)
+	}
+}
 
-		fillOrderBody, err := m.getFillOrderBodyBytes(anyMsg.Value)
+func (m *Monitor) DecodeTxResponse(r *sdktypes.TxResponse) []FillOrderEnvelope {
+	fillOrders := []FillOrderEnvelope{}
+	m.decodeTxMessages(r, func(i int, msg []byte) {
+		execs, err := m.getExecuteMsgs(msg, CONTRACT_ACTION_FILL_ORDER)
 		if err != nil {
-			continue
+			return
 		}
 
-		fill := FillOrderEnvelope{}
-		if err := json.Unmarshal(fillOrderBody, &fill); err != nil {
-			// types don't match -- skip
-			continue
+		for _, exec := range execs {
+			fill := FillOrderEnvelope{MsgIndex: i, AuthzMsgIndex: exec.authzMsgIndex}
+			if err := json.Unmarshal(exec.body, &fill); err != nil || fill.FillOrder == nil || fill.FillOrder.Order == nil {
+				// types don't match -- skip
+				continue
+			}
+			fillOrders = append(fillOrders, fill)
 		}
-		fillOrders = append(fillOrders, fill)
-	}
+	})
 	return fillOrders
 }
@@ -1,14 +1,22 @@
 package monitor
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	wasmtypes "github.com/CosmWasm/wasmd/x/wasm/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
 	types "github.com/cosmos/cosmos-sdk/types"
 	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/cosmos/cosmos-sdk/x/authz"
 	"github.com/rs/zerolog"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 const (
@@ -43,6 +51,39 @@ func newTestMonitor() *Monitor {
 	}
 }
 
+// newTestMonitorWithDB returns a test monitor backed by an in-memory sqlite db
+func newTestMonitorWithDB(t *testing.T, cfg *Config) *Monitor {
+	t.Helper()
+	db, err := sql.Open("sqlite3", ":memory:")
+	if err != nil {
+		t.Fatalf("failed to open db: %v", err)
+	}
+	// every connection to :memory: gets its own db
+	db.SetMaxOpenConns(1)
+	t.Cleanup(func() { db.Close() })
+	InitDB(db)
+
+	if cfg == nil {
+		cfg = &Config{}
+	}
+	m := newTestMonitor()
+	m.db = db
+	m.cfg = cfg
+	return m
+}
+
+// seedFixtureOrders stores the fills of decoding_test_fixture.json and returns them in the order of the fixture
+func seedFixtureOrders(t *testing.T, m *Monitor) []DbOrderFilled {
+	t.Helper()
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+	orders, _ := m.decodeOrderTxResponses(data.TxResponses)
+	for _, o := range orders {
+		require.NoError(t, m.InsertOrderFilled(context.Background(), o))
+	}
+	return orders
+}
+
 func TestDecodeTxResponses(t *testing.T) {
 	m := newTestMonitor()
 
@@ -100,3 +141,163 @@ func TestDecodeTxResponsesAuthz(t *testing.T) {
 		assert.Equal(t, "101500000", msg.FillOrder.Order.AmountIn)
 	}
 }
+
+func TestMigrateDB(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	// tx_data as created by older versions
+	_, err = db.Exec(`CREATE TABLE tx_data (tx_hash TEXT PRIMARY KEY, sender TEXT, amount_in INTEGER, amount_out INTEGER,
+		source_domain TEXT, solver_revenue INTEGER, code INTEGER, height INTEGER, filler TEXT,
+		ingestion_timestamp DATETIME DEFAULT CURRENT_TIMESTAMP)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO tx_data (tx_hash, sender, amount_in, amount_out, source_domain, solver_revenue, code, height, filler)
+		VALUES ('A', 'sender', 1000000, 939000, '42161', 61000, 0, 100, 'osmo1filler')`)
+	require.NoError(t, err)
+
+	InitDB(db)
+	// running the migrations again is a no-op
+	InitDB(db)
+
+	exists, err := columnExists(db, "tx_data", "nonce")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = columnExists(db, "tx_data", "event_mismatch")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	exists, err = columnExists(db, "tx_data", "destination_domain")
+	require.NoError(t, err)
+	assert.True(t, exists)
+
+	// fills are keyed by message and nonce and the existing rows are kept
+	pk, err := primaryKeyColumns(db, "tx_data")
+	require.NoError(t, err)
+	assert.Equal(t, txDataKey, pk)
+
+	var count, msgIndex, nonce int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*), MAX(msg_index), MAX(nonce) FROM tx_data WHERE tx_hash = 'A' AND amount_in = 1000000").Scan(&count, &msgIndex, &nonce))
+	assert.Equal(t, 1, count)
+	assert.Zero(t, msgIndex)
+	assert.Zero(t, nonce)
+
+	exists, err = columnExists(db, "tx_data", "filler")
+	require.NoError(t, err)
+	assert.True(t, exists)
+	var indexes int
+	require.NoError(t, db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type = 'index' AND tbl_name = 'tx_data' AND name LIKE 'idx_%'").Scan(&indexes))
+	assert.Equal(t, 2, indexes)
+}
+
+func TestMigrateTxDataNonce(t *testing.T) {
+	db, err := sql.Open("sqlite3", ":memory:")
+	require.NoError(t, err)
+	db.SetMaxOpenConns(1)
+	defer db.Close()
+
+	// tx_data keyed by (tx_hash, msg_index, nonce) with fills stored before nonces were tracked
+	_, err = db.Exec("CREATE TABLE tx_data " + strings.Replace(strings.Replace(txDataSchema,
+		"nonce INTEGER NOT NULL DEFAULT 0", "nonce INTEGER", 1),
+		"PRIMARY KEY (tx_hash, msg_index, authz_msg_index, nonce)", "PRIMARY KEY (tx_hash, msg_index, nonce)", 1))
+	require.NoError(t, err)
+	// a NULL nonce doesn't conflict so the same fill could be stored twice
+	for i := 0; i < 2; i++ {
+		_, err = db.Exec(`INSERT INTO tx_data (tx_hash, amount_in, height, msg_index) VALUES ('A', 1000000, 100, 0)`)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(`INSERT INTO tx_data (tx_hash, amount_in, height, msg_index, nonce) VALUES ('B', 1000000, 100, 0, 7)`)
+	require.NoError(t, err)
+
+	InitDB(db)
+
+	pk, err := primaryKeyColumns(db, "tx_data")
+	require.NoError(t, err)
+	assert.Equal(t, txDataKey, pk)
+
+	nonces := map[string]int{}
+	rows, err := db.Query("SELECT tx_hash, nonce FROM tx_data")
+	require.NoError(t, err)
+	for rows.Next() {
+		var hash string
+		var nonce int
+		require.NoError(t, rows.Scan(&hash, &nonce))
+		assert.NotContains(t, nonces, hash)
+		nonces[hash] = nonce
+	}
+	require.NoError(t, rows.Err())
+	rows.Close()
+	assert.Equal(t, map[string]int{"A": 0, "B": 7}, nonces)
+
+	// the fills of two execs at the same message index are different fills
+	for _, authzMsgIndex := range []int{0, 1} {
+		_, err = db.Exec(`INSERT INTO tx_data (tx_hash, msg_index, authz_msg_index, nonce) VALUES ('C', 1, ?, 5)`, authzMsgIndex)
+		require.NoError(t, err)
+	}
+	_, err = db.Exec(`INSERT INTO tx_data (tx_hash, msg_index, authz_msg_index) VALUES ('C', 1, 0)`)
+	require.NoError(t, err)
+	_, err = db.Exec(`INSERT INTO tx_data (tx_hash, msg_index, authz_msg_index) VALUES ('C', 1, 0)`)
+	assert.Error(t, err)
+}
+
+// batchFillOrder appends a copy of the fill_order message of the authz exec with the given nonce,
+// as if the filler batched two fills into the same exec
+func batchFillOrder(t *testing.T, m *Monitor, txResponse *types.TxResponse, nonce uint32) {
+	t.Helper()
+	var sdkTx tx.Tx
+	require.NoError(t, m.Codec.Unmarshal(txResponse.Tx.Value, &sdkTx))
+
+	var exec authz.MsgExec
+	require.NoError(t, m.Codec.Unmarshal(sdkTx.Body.Messages[1].Value, &exec))
+	var fill wasmtypes.MsgExecuteContract
+	require.NoError(t, m.Codec.Unmarshal(exec.Msgs[1].Value, &fill))
+
+	var body FillOrderEnvelope
+	require.NoError(t, json.Unmarshal(fill.Msg, &body))
+	body.FillOrder.Order.Nonce = nonce
+	msg, err := json.Marshal(body)
+	require.NoError(t, err)
+	fill.Msg = msg
+
+	fillAny, err := codectypes.NewAnyWithValue(&fill)
+	require.NoError(t, err)
+	exec.Msgs = append(exec.Msgs, fillAny)
+	execAny, err := codectypes.NewAnyWithValue(&exec)
+	require.NoError(t, err)
+	sdkTx.Body.Messages[1] = execAny
+
+	bz, err := m.Codec.Marshal(&sdkTx)
+	require.NoError(t, err)
+	txResponse.Tx.Value = bz
+}
+
+func TestInsertMultipleFillsPerTx(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+
+	var txResponse types.TxResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "test_multi_execs_authz.json"), &txResponse))
+	batchFillOrder(t, m, &txResponse, 4012)
+
+	fills := m.DecodeTxResponse(&txResponse)
+	require.Len(t, fills, 2)
+	assert.Equal(t, uint32(4011), fills[0].FillOrder.Order.Nonce)
+	assert.Equal(t, uint32(4012), fills[1].FillOrder.Order.Nonce)
+	assert.Equal(t, 1, fills[1].MsgIndex)
+	assert.Equal(t, 2, fills[1].AuthzMsgIndex)
+
+	orders, _ := m.decodeOrderTxResponses([]*types.TxResponse{&txResponse})
+	require.Len(t, orders, 2)
+	for _, o := range orders {
+		require.NoError(t, m.InsertOrderFilled(context.Background(), o))
+	}
+	// the same fill can't be stored twice
+	assert.Error(t, m.InsertOrderFilled(context.Background(), orders[0]))
+
+	stored, err := m.GetDbOrders(OrderFilter{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, stored, 2)
+	assert.Equal(t, stored[0].TxHash, stored[1].TxHash)
+	assert.ElementsMatch(t, []uint32{4011, 4012}, []uint32{stored[0].Nonce, stored[1].Nonce})
+}
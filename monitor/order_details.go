@@ -0,0 +1,55 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+)
+
+// raw tx responses decoded per batch when backfilling order details
+const ORDER_DETAILS_BATCH_SIZE = 500
+
+// BackfillOrderDetails decodes the stored raw tx responses of fills ingested before the destination domain,
+// timeout and data (and on older rows the recipient and nonce) were stored and updates the fills.
+// Fills without a stored raw tx response are left as is. Returns the number of updated fills.
+func (m *Monitor) BackfillOrderDetails(ctx context.Context) (int, error) {
+	updated := 0
+	afterId := int64(0)
+	for {
+		if err := ctx.Err(); err != nil {
+			return updated, err
+		}
+		responses, lastId, err := m.GetDbRawTxResponsesMissingDetails(afterId, ORDER_DETAILS_BATCH_SIZE)
+		if err != nil {
+			return updated, err
+		}
+		if len(responses) == 0 {
+			break
+		}
+		afterId = lastId
+
+		txResponses := []*sdktypes.TxResponse{}
+		for _, r := range responses {
+			var txResponse sdktypes.TxResponse
+			if err := m.Codec.UnmarshalJSON(r.TxResponse, &txResponse); err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", r.TxHash).Msg("failed to unmarshal raw tx response -- skipping")
+				continue
+			}
+			txResponses = append(txResponses, &txResponse)
+		}
+
+		orders, _ := m.decodeOrderTxResponses(txResponses)
+		for _, o := range orders {
+			ok, err := m.UpdateOrderDetails(ctx, o)
+			if err != nil {
+				return updated, fmt.Errorf("failed to update order details of %s: %w", o.TxHash, err)
+			}
+			if ok {
+				updated++
+			}
+		}
+		m.logger.Info().Int64("last_id", lastId).Int("updated", updated).Msg("backfilled order details batch")
+	}
+	return updated, nil
+}
@@ -0,0 +1,82 @@
+package monitor
+
+import (
+	"context"
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBackfillOrderDetails(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+	ctx := context.Background()
+
+	orders := seedFixtureOrders(t, m)
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+	_, responses := m.decodeOrderTxResponses(data.TxResponses)
+	// only some of the raw responses were saved
+	for _, r := range responses[:3] {
+		require.NoError(t, m.InsertRawTxResponse(ctx, *r))
+	}
+	// fills as stored by older versions
+	_, err := m.db.Exec(`UPDATE tx_data SET recipient = NULL, nonce = 0, destination_domain = NULL, timeout_timestamp = NULL, data = NULL`)
+	require.NoError(t, err)
+
+	updated, err := m.BackfillOrderDetails(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 3, updated)
+
+	// running it again is a no-op
+	updated, err = m.BackfillOrderDetails(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, updated)
+
+	stored, err := m.GetDbOrders(OrderFilter{DestinationDomain: "875", Limit: 100})
+	require.NoError(t, err)
+	require.Len(t, stored, 3)
+	byHash := map[string]DbOrderFilled{}
+	for _, o := range orders {
+		byHash[o.TxHash] = o
+	}
+	for _, o := range stored {
+		expected := byHash[o.TxHash]
+		assert.Equal(t, expected.Recipient, o.Recipient)
+		assert.Equal(t, expected.Nonce, o.Nonce)
+		assert.Equal(t, expected.TimeoutTimestamp, o.TimeoutTimestamp)
+		assert.Equal(t, expected.Data, o.Data)
+	}
+}
+
+func TestGetDbOrdersFilters(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+	orders := seedFixtureOrders(t, m)
+	require.NotEmpty(t, orders)
+	for _, o := range orders {
+		assert.Equal(t, "875", o.DestinationDomain)
+		assert.NotZero(t, o.TimeoutTimestamp)
+	}
+
+	all, err := m.GetDbOrders(OrderFilter{Limit: 100})
+	require.NoError(t, err)
+	assert.Len(t, all, len(orders))
+
+	recipient := orders[0].Recipient
+	byRecipient, err := m.GetDbOrders(OrderFilter{Recipient: recipient, Limit: 100})
+	require.NoError(t, err)
+	require.NotEmpty(t, byRecipient)
+	for _, o := range byRecipient {
+		assert.Equal(t, recipient, o.Recipient)
+	}
+
+	none, err := m.GetDbOrders(OrderFilter{DestinationDomain: "1", Limit: 100})
+	require.NoError(t, err)
+	assert.Empty(t, none)
+
+	paged, err := m.GetDbOrders(OrderFilter{Limit: 2, Offset: 1})
+	require.NoError(t, err)
+	require.Len(t, paged, 2)
+	assert.Equal(t, all[1].TxHash, paged[0].TxHash)
+}
@@ -0,0 +1,152 @@
+package monitor
+
+import (
+	"strconv"
+	"strings"
+
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+)
+
+// reasons stored in tx_data.event_mismatch -- multiple reasons are comma separated
+const (
+	ORDER_MISMATCH_TX_FAILED   = "tx_failed"
+	ORDER_MISMATCH_NO_EVENT    = "missing_order_filled_event"
+	ORDER_MISMATCH_NO_TRANSFER = "missing_fill_transfer"
+	ORDER_MISMATCH_FILLER      = "filler"
+	ORDER_MISMATCH_AMOUNT_OUT  = "amount_out"
+)
+
+// OrderFilledEvent is the order_filled wasm event of the fast transfer contract
+// and the transfer of the filler to the contract emitted by the same message
+type OrderFilledEvent struct {
+	OrderId  string
+	Contract string
+	// sender and amount of the transfer to the contract
+	Filler    string
+	AmountOut string
+	Denom     string
+	MsgIndex  int
+	// -1 if the message was not executed with authz
+	AuthzMsgIndex int
+}
+
+type msgEventKey struct {
+	msgIndex      int
+	authzMsgIndex int
+}
+
+type transferEvent struct {
+	sender    string
+	recipient string
+	amount    string
+	denom     string
+}
+
+func eventAttributes(e abcitypes.Event) map[string]string {
+	attrs := map[string]string{}
+	for _, a := range e.Attributes {
+		// the first value wins -- wasm events can repeat keys (e.g. action)
+		if _, ok := attrs[a.Key]; !ok {
+			attrs[a.Key] = a.Value
+		}
+	}
+	return attrs
+}
+
+func eventMsgKey(attrs map[string]string) (msgEventKey, bool) {
+	msgIndex, err := strconv.Atoi(attrs["msg_index"])
+	if err != nil {
+		return msgEventKey{}, false
+	}
+	key := msgEventKey{msgIndex: msgIndex, authzMsgIndex: -1}
+	if v, ok := attrs["authz_msg_index"]; ok {
+		authzMsgIndex, err := strconv.Atoi(v)
+		if err != nil {
+			return msgEventKey{}, false
+		}
+		key.authzMsgIndex = authzMsgIndex
+	}
+	return key, true
+}
+
+// splitCoin splits "939000ibc/498A..." into amount and denom
+func splitCoin(coin string) (string, string) {
+	i := 0
+	for i < len(coin) && coin[i] >= '0' && coin[i] <= '9' {
+		i++
+	}
+	return coin[:i], coin[i:]
+}
+
+// parseOrderFilledEvents returns the order_filled events of the tx keyed by the message that emitted them.
+// The filler and amount are taken from the transfer to the contract emitted by the same message.
+func parseOrderFilledEvents(events []abcitypes.Event) map[msgEventKey]OrderFilledEvent {
+	transfers := map[msgEventKey][]transferEvent{}
+	fills := map[msgEventKey]OrderFilledEvent{}
+	for _, e := range events {
+		attrs := eventAttributes(e)
+		key, ok := eventMsgKey(attrs)
+		if !ok {
+			continue
+		}
+
+		switch e.Type {
+		case "transfer":
+			amount, denom := splitCoin(attrs["amount"])
+			transfers[key] = append(transfers[key], transferEvent{
+				sender:    attrs["sender"],
+				recipient: attrs["recipient"],
+				amount:    amount,
+				denom:     denom,
+			})
+		case "wasm":
+			if attrs["action"] != "order_filled" {
+				continue
+			}
+			fills[key] = OrderFilledEvent{
+				OrderId:       attrs["order_id"],
+				Contract:      attrs["_contract_address"],
+				MsgIndex:      key.msgIndex,
+				AuthzMsgIndex: key.authzMsgIndex,
+			}
+		}
+	}
+
+	for key, fill := range fills {
+		for _, t := range transfers[key] {
+			if t.recipient == fill.Contract {
+				fill.Filler = t.sender
+				fill.AmountOut = t.amount
+				fill.Denom = t.denom
+				break
+			}
+		}
+		fills[key] = fill
+	}
+	return fills
+}
+
+// crossCheckOrderEvents compares the decoded fill message with the events emitted by the tx.
+// Returns the order ID from the events and the mismatch reasons (empty if the fill matches the events).
+func crossCheckOrderEvents(fill FillOrderEnvelope, code uint32, events map[msgEventKey]OrderFilledEvent) (string, string) {
+	if code != 0 {
+		return "", ORDER_MISMATCH_TX_FAILED
+	}
+
+	event, ok := events[msgEventKey{msgIndex: fill.MsgIndex, authzMsgIndex: fill.AuthzMsgIndex}]
+	if !ok {
+		return "", ORDER_MISMATCH_NO_EVENT
+	}
+	if event.Filler == "" {
+		return event.OrderId, ORDER_MISMATCH_NO_TRANSFER
+	}
+
+	reasons := []string{}
+	if event.Filler != fill.FillOrder.Filler {
+		reasons = append(reasons, ORDER_MISMATCH_FILLER)
+	}
+	if event.AmountOut != fill.FillOrder.Order.AmountOut {
+		reasons = append(reasons, ORDER_MISMATCH_AMOUNT_OUT)
+	}
+	return event.OrderId, strings.Join(reasons, ",")
+}
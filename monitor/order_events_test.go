@@ -0,0 +1,93 @@
+package monitor
+
+import (
+	"context"
+
+	"testing"
+
+	"github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrossCheckOrderEvents(t *testing.T) {
+	m := newTestMonitor()
+
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+
+	for _, txResponse := range data.TxResponses {
+		events := parseOrderFilledEvents(txResponse.Events)
+		for _, fill := range m.DecodeTxResponse(txResponse) {
+			orderId, mismatch := crossCheckOrderEvents(fill, txResponse.Code, events)
+			assert.NotEmpty(t, orderId, "tx %s", txResponse.TxHash)
+			assert.Empty(t, mismatch, "tx %s", txResponse.TxHash)
+		}
+	}
+
+	txResponse := data.TxResponses[0]
+	events := parseOrderFilledEvents(txResponse.Events)
+	fill := m.DecodeTxResponse(txResponse)[0]
+	orderId, _ := crossCheckOrderEvents(fill, txResponse.Code, events)
+	assert.Equal(t, "10e4e25480d9020f78aebe22becd4d205c08f9343d2fbfca9b58fd63f9e9c576", orderId)
+
+	tampered := fill
+	tampered.FillOrder.Order.AmountOut = "1"
+	tampered.FillOrder.Filler = "osmo1notthefiller"
+	_, mismatch := crossCheckOrderEvents(tampered, txResponse.Code, events)
+	assert.Equal(t, ORDER_MISMATCH_FILLER+","+ORDER_MISMATCH_AMOUNT_OUT, mismatch)
+
+	_, mismatch = crossCheckOrderEvents(fill, 5, events)
+	assert.Equal(t, ORDER_MISMATCH_TX_FAILED, mismatch)
+
+	_, mismatch = crossCheckOrderEvents(fill, txResponse.Code, parseOrderFilledEvents(nil))
+	assert.Equal(t, ORDER_MISMATCH_NO_EVENT, mismatch)
+}
+
+func TestCrossCheckOrderEventsAuthz(t *testing.T) {
+	m := newTestMonitor()
+
+	var txResponse types.TxResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "test_multi_execs_authz.json"), &txResponse))
+
+	events := parseOrderFilledEvents(txResponse.Events)
+	fills := m.DecodeTxResponse(&txResponse)
+	require.NotEmpty(t, fills)
+
+	orderIds := map[string]bool{}
+	for _, fill := range fills {
+		orderId, mismatch := crossCheckOrderEvents(fill, txResponse.Code, events)
+		assert.Empty(t, mismatch)
+		orderIds[orderId] = true
+	}
+	// every message of the tx fills a different order
+	assert.Len(t, orderIds, len(fills))
+
+	event, ok := events[msgEventKey{msgIndex: 1, authzMsgIndex: 1}]
+	require.True(t, ok)
+	assert.Equal(t, "0ac5fce41d023076c495e68f80ae811f152b920d8d95e1f59d64aa70a7ef46b5", event.OrderId)
+}
+
+func TestGetDbOrderMismatches(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+
+	var data tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &data))
+
+	orders, _ := m.decodeOrderTxResponses(data.TxResponses[:2])
+	require.Len(t, orders, 2)
+	assert.Equal(t, "10e4e25480d9020f78aebe22becd4d205c08f9343d2fbfca9b58fd63f9e9c576", orders[0].OrderId)
+
+	orders[1].EventMismatch = ORDER_MISMATCH_AMOUNT_OUT
+	for _, o := range orders {
+		require.NoError(t, m.InsertOrderFilled(context.Background(), o))
+	}
+
+	mismatches, err := m.GetDbOrderMismatches()
+	require.NoError(t, err)
+	require.Len(t, mismatches, 1)
+	assert.Equal(t, orders[1].TxHash, mismatches[0].TxHash)
+	assert.Equal(t, orders[1].OrderId, mismatches[0].OrderId)
+	assert.Equal(t, ORDER_MISMATCH_AMOUNT_OUT, mismatches[0].EventMismatch)
+}
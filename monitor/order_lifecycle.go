@@ -0,0 +1,216 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+)
+
+// age buckets of outstanding fills, fills without a known block time are in ORDER_AGE_UNKNOWN
+const ORDER_AGE_UNKNOWN = "unknown"
+
+var orderAgeBuckets = []struct {
+	name   string
+	maxAge time.Duration
+}{
+	{"<1h", time.Hour},
+	{"1h-6h", 6 * time.Hour},
+	{"6h-24h", 24 * time.Hour},
+	{"1d-7d", 7 * 24 * time.Hour},
+	{">7d", 0},
+}
+
+func orderAgeBucket(age time.Duration) string {
+	for _, b := range orderAgeBuckets {
+		if b.maxAge == 0 || age < b.maxAge {
+			return b.name
+		}
+	}
+	return ORDER_AGE_UNKNOWN
+}
+
+// PendingSettlement are the orders of one source domain settled by the same initiate_settlement tx
+type PendingSettlement struct {
+	TxHash           string
+	SourceDomain     string
+	RepaymentAddress string // 32 byte hex address on the source domain
+	Timestamp        int64  // osmosis block time of the initiate_settlement tx
+	AmountIn         int64  // repayment expected on the source domain
+	OrderIds         []string
+}
+
+// OutstandingFill is a successful fill that was not repaid yet.
+// A fill goes from filled to settlement initiated (initiate_settlement on osmosis) to settled (repaid on the source domain).
+type OutstandingFill struct {
+	SourceDomain        string
+	AmountOut           int64
+	Timestamp           int64 // 0 if the block time is not known
+	SettlementInitiated bool
+}
+
+type ExposureBucket struct {
+	Bucket string `json:"bucket"`
+	Orders int64  `json:"orders"`
+	Amount string `json:"amount"`
+}
+
+// DomainExposure is the outstanding amount of the filler for orders with the same source domain
+type DomainExposure struct {
+	SourceDomain string `json:"source_domain"`
+	Network      string `json:"network"`
+	Orders       int64  `json:"orders"`
+	Amount       string `json:"amount"`
+	// outstanding amount by state
+	FilledAmount              string           `json:"filled_amount"`
+	SettlementInitiatedAmount string           `json:"settlement_initiated_amount"`
+	AgeBuckets                []ExposureBucket `json:"age_buckets"`
+}
+
+type ExposureStats struct {
+	Filler  string           `json:"filler"`
+	Orders  int64            `json:"orders"`
+	Amount  string           `json:"amount"`
+	Domains []DomainExposure `json:"domains"`
+}
+
+// MatchOrderSettlements marks the orders of a successful initiate_settlement as settled once the repayment
+// arrives on the source domain. The repayment is an incoming USDC transfer of the chain address on the source network
+// after the initiation whose amount equals the total amount_in of the orders. Each transfer settles a single initiation.
+// Only the token transfers of the solver are tracked, so only its initiations repaid to the chain address are matched.
+// Returns the number of settled orders.
+func (m *Monitor) MatchOrderSettlements(ctx context.Context) (int, error) {
+	solverAddress := m.cfg.Osmosis.SolverAddress
+	if solverAddress == "" {
+		return 0, nil
+	}
+	pending, err := m.GetDbPendingSettlements(solverAddress)
+	if err != nil {
+		return 0, err
+	}
+
+	repaymentAddresses := map[string]string{}
+	for _, c := range m.cfg.Chains {
+		if c.Address != "" {
+			repaymentAddresses[c.Name] = evmRepaymentAddress(c.Address)
+		}
+	}
+
+	settled := 0
+	for _, p := range pending {
+		network, ok := ChainIdToNetwork[p.SourceDomain]
+		if !ok || repaymentAddresses[network] != p.RepaymentAddress {
+			continue
+		}
+		transfer, found, err := m.GetDbRepaymentTransfer(network, p.AmountIn, p.Timestamp)
+		if err != nil {
+			return settled, err
+		}
+		if !found {
+			continue
+		}
+		if err := m.InsertOrderSettlements(ctx, p, transfer); err != nil {
+			return settled, fmt.Errorf("failed to insert settlement %s: %w", p.TxHash, err)
+		}
+		settled += len(p.OrderIds)
+	}
+	return settled, nil
+}
+
+// evmRepaymentAddress left pads the EVM address to the 32 byte repayment address of initiate_settlement
+func evmRepaymentAddress(address string) string {
+	return strings.Repeat("0", 24) + normalizeHex(address)
+}
+
+// GetExposure aggregates the outstanding fills of the filler filled since the given time per source domain and age.
+// Repayments are only tracked for the solver, the fills of other fillers are no longer outstanding once their
+// settlement was initiated.
+func (m *Monitor) GetExposure(filler string, since time.Time, asInteger bool) (ExposureStats, error) {
+	fills, err := m.GetDbOutstandingFills(filler, since.Unix())
+	if err != nil {
+		return ExposureStats{}, err
+	}
+	if filler != m.cfg.Osmosis.SolverAddress {
+		fills = slices.DeleteFunc(fills, func(f OutstandingFill) bool { return f.SettlementInitiated })
+	}
+	return aggregateExposure(filler, fills, time.Now(), asInteger), nil
+}
+
+func aggregateExposure(filler string, fills []OutstandingFill, now time.Time, asInteger bool) ExposureStats {
+	type domainTotals struct {
+		orders, amount, filled, initiated int64
+		buckets                           map[string]*ExposureBucket
+		bucketAmounts                     map[string]int64
+	}
+
+	domains := map[string]*domainTotals{}
+	total, totalAmount := int64(0), int64(0)
+	for _, f := range fills {
+		d, ok := domains[f.SourceDomain]
+		if !ok {
+			d = &domainTotals{buckets: map[string]*ExposureBucket{}, bucketAmounts: map[string]int64{}}
+			domains[f.SourceDomain] = d
+		}
+		d.orders++
+		d.amount += f.AmountOut
+		if f.SettlementInitiated {
+			d.initiated += f.AmountOut
+		} else {
+			d.filled += f.AmountOut
+		}
+
+		bucket := ORDER_AGE_UNKNOWN
+		if f.Timestamp > 0 {
+			bucket = orderAgeBucket(now.Sub(time.Unix(f.Timestamp, 0)))
+		}
+		if _, ok := d.buckets[bucket]; !ok {
+			d.buckets[bucket] = &ExposureBucket{Bucket: bucket}
+		}
+		d.buckets[bucket].Orders++
+		d.bucketAmounts[bucket] += f.AmountOut
+
+		total++
+		totalAmount += f.AmountOut
+	}
+
+	stats := ExposureStats{Filler: filler, Orders: total, Amount: formatUsdc(totalAmount, asInteger), Domains: []DomainExposure{}}
+	for sourceDomain, d := range domains {
+		exposure := DomainExposure{
+			SourceDomain:              sourceDomain,
+			Network:                   ChainIdToNetwork[sourceDomain],
+			Orders:                    d.orders,
+			Amount:                    formatUsdc(d.amount, asInteger),
+			FilledAmount:              formatUsdc(d.filled, asInteger),
+			SettlementInitiatedAmount: formatUsdc(d.initiated, asInteger),
+			AgeBuckets:                []ExposureBucket{},
+		}
+		// buckets from the newest to the oldest fills
+		for _, name := range append(orderAgeBucketNames(), ORDER_AGE_UNKNOWN) {
+			if b, ok := d.buckets[name]; ok {
+				b.Amount = formatUsdc(d.bucketAmounts[name], asInteger)
+				exposure.AgeBuckets = append(exposure.AgeBuckets, *b)
+			}
+		}
+		stats.Domains = append(stats.Domains, exposure)
+	}
+	sort.Slice(stats.Domains, func(i, j int) bool { return stats.Domains[i].SourceDomain < stats.Domains[j].SourceDomain })
+	return stats
+}
+
+func orderAgeBucketNames() []string {
+	names := []string{}
+	for _, b := range orderAgeBuckets {
+		names = append(names, b.name)
+	}
+	return names
+}
+
+// splitOrderIds splits the GROUP_CONCAT of order ids
+func splitOrderIds(ids string) []string {
+	if ids == "" {
+		return []string{}
+	}
+	return strings.Split(ids, ",")
+}
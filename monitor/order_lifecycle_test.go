@@ -0,0 +1,193 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchOrderSettlements(t *testing.T) {
+	m := newTestMonitorWithDB(t, &Config{})
+	ctx := context.Background()
+
+	orders := seedFixtureOrders(t, m)
+
+	// the filler settles two of its orders with the same source domain in one tx
+	var settled []DbOrderFilled
+	for i := 0; i < len(orders) && settled == nil; i++ {
+		for _, o := range orders[i+1:] {
+			if o.Filler == orders[i].Filler && o.SourceDomain == orders[i].SourceDomain {
+				settled = []DbOrderFilled{orders[i], o}
+				break
+			}
+		}
+	}
+	require.Len(t, settled, 2)
+	network := ChainIdToNetwork[settled[0].SourceDomain]
+	require.NotEmpty(t, network)
+	solverAddress := "0xA1b2c3D4e5F60718293a4B5c6D7e8f9001122334"
+	m.cfg.Chains = []ChainConfig{{Name: network, ChainEntry: ChainEntry{Address: solverAddress}}}
+
+	initiatedAt, initiatedTimestamp := orders[0].Height+100, orders[0].BlockTimestamp+100
+	require.NoError(t, m.storeBlockTime(ctx, &BlockTime{Height: initiatedAt, Timestamp: initiatedTimestamp}))
+	require.NoError(t, m.storeBlockTime(ctx, &BlockTime{Height: initiatedAt - 1, Timestamp: initiatedTimestamp - 1}))
+	repayment := int64(0)
+	for _, o := range settled {
+		require.NoError(t, m.InsertOrderAction(ctx, DbOrderAction{
+			TxHash:           "SETTLE",
+			Action:           CONTRACT_ACTION_INITIATE_SETTLEMENT,
+			OrderId:          o.OrderId,
+			RepaymentAddress: evmRepaymentAddress(solverAddress),
+			Height:           initiatedAt,
+		}))
+		amountIn, err := strconv.ParseInt(o.AmountIn, 10, 64)
+		require.NoError(t, err)
+		repayment += amountIn
+	}
+	// an earlier initiation repaid to another address is never matched to the transfers of the chain address
+	require.NoError(t, m.InsertOrderAction(ctx, DbOrderAction{
+		TxHash:           "FOREIGN",
+		Action:           CONTRACT_ACTION_INITIATE_SETTLEMENT,
+		OrderId:          settled[0].OrderId,
+		RepaymentAddress: evmRepaymentAddress("0x2222222222222222222222222222222222222222"),
+		Height:           initiatedAt - 1,
+	}))
+	amountIn, err := strconv.ParseInt(settled[0].AmountIn, 10, 64)
+	require.NoError(t, err)
+	_, err = m.InsertTokenTransfer(ctx, DbTokenTransfer{
+		TxHash:    "0xforeign",
+		Network:   network,
+		Token:     "USDC",
+		Direction: TRANSFER_DIRECTION_IN,
+		Amount:    amountIn,
+		Timestamp: initiatedTimestamp,
+	})
+	require.NoError(t, err)
+
+	// the transfer before the initiation can't be the repayment
+	for i, ts := range []int64{initiatedTimestamp - 10, initiatedTimestamp + 600} {
+		_, err := m.InsertTokenTransfer(ctx, DbTokenTransfer{
+			TxHash:    "0xrepayment" + strconv.Itoa(i),
+			Network:   network,
+			Token:     "USDC",
+			Direction: TRANSFER_DIRECTION_IN,
+			Amount:    repayment,
+			Timestamp: ts,
+		})
+		require.NoError(t, err)
+	}
+
+	// the transfers are only tracked for the solver
+	matched, err := m.MatchOrderSettlements(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, matched)
+	m.cfg.Osmosis.SolverAddress = "osmo1other"
+	matched, err = m.MatchOrderSettlements(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, matched)
+
+	m.cfg.Osmosis.SolverAddress = settled[0].Filler
+	exposure, err := m.GetExposure(settled[0].Filler, time.Unix(0, 0), true)
+	require.NoError(t, err)
+	outstanding := exposure.Orders
+	matched, err = m.MatchOrderSettlements(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, 2, matched)
+
+	var repaymentTx, initiationTx string
+	require.NoError(t, m.db.QueryRow(`SELECT repayment_tx_hash, initiation_tx_hash FROM order_settlements WHERE order_id = ?`, settled[0].OrderId).Scan(&repaymentTx, &initiationTx))
+	assert.Equal(t, "0xrepayment1", repaymentTx)
+	assert.Equal(t, "SETTLE", initiationTx)
+
+	// settled orders are not outstanding anymore
+	matched, err = m.MatchOrderSettlements(ctx)
+	require.NoError(t, err)
+	assert.Zero(t, matched)
+	exposure, err = m.GetExposure(settled[0].Filler, time.Unix(0, 0), true)
+	require.NoError(t, err)
+	assert.Equal(t, outstanding-2, exposure.Orders)
+}
+
+func TestExposureOfOtherFillers(t *testing.T) {
+	m := newTestMonitorWithDB(t, &Config{})
+	ctx := context.Background()
+
+	orders := seedFixtureOrders(t, m)
+	filler := orders[0].Filler
+	m.cfg.Osmosis.SolverAddress = "osmo1solver"
+	require.NotEqual(t, m.cfg.Osmosis.SolverAddress, filler)
+
+	exposure, err := m.GetExposure(filler, time.Unix(0, 0), true)
+	require.NoError(t, err)
+	outstanding := exposure.Orders
+	require.NotZero(t, outstanding)
+
+	require.NoError(t, m.InsertOrderAction(ctx, DbOrderAction{
+		TxHash:  "SETTLE",
+		Action:  CONTRACT_ACTION_INITIATE_SETTLEMENT,
+		OrderId: orders[0].OrderId,
+		Height:  orders[0].Height + 100,
+	}))
+
+	// the repayment of another filler is never matched, the initiated settlement ends its exposure
+	exposure, err = m.GetExposure(filler, time.Unix(0, 0), true)
+	require.NoError(t, err)
+	assert.Equal(t, outstanding-1, exposure.Orders)
+	for _, d := range exposure.Domains {
+		assert.Equal(t, "0", d.SettlementInitiatedAmount)
+	}
+
+	// the solver's fill stays outstanding until it is repaid
+	m.cfg.Osmosis.SolverAddress = filler
+	exposure, err = m.GetExposure(filler, time.Unix(0, 0), true)
+	require.NoError(t, err)
+	assert.Equal(t, outstanding, exposure.Orders)
+}
+
+func TestAggregateExposure(t *testing.T) {
+	now := time.Date(2025, 3, 20, 12, 0, 0, 0, time.UTC)
+	fills := []OutstandingFill{
+		{SourceDomain: "42161", AmountOut: 1_500_000, Timestamp: now.Add(-10 * time.Minute).Unix()},
+		{SourceDomain: "42161", AmountOut: 2_000_000, Timestamp: now.Add(-30 * time.Minute).Unix(), SettlementInitiated: true},
+		{SourceDomain: "42161", AmountOut: 500_000, Timestamp: now.Add(-48 * time.Hour).Unix()},
+		{SourceDomain: "1", AmountOut: 10_000_000, Timestamp: now.Add(-30 * 24 * time.Hour).Unix()},
+		{SourceDomain: "1", AmountOut: 1_000_000},
+	}
+
+	stats := aggregateExposure("osmo1filler", fills, now, false)
+	assert.Equal(t, ExposureStats{
+		Filler: "osmo1filler",
+		Orders: 5,
+		Amount: "15",
+		Domains: []DomainExposure{
+			{
+				SourceDomain:              "1",
+				Network:                   ETHEREUM_NETWORK,
+				Orders:                    2,
+				Amount:                    "11",
+				FilledAmount:              "11",
+				SettlementInitiatedAmount: "0",
+				AgeBuckets: []ExposureBucket{
+					{Bucket: ">7d", Orders: 1, Amount: "10"},
+					{Bucket: ORDER_AGE_UNKNOWN, Orders: 1, Amount: "1"},
+				},
+			},
+			{
+				SourceDomain:              "42161",
+				Network:                   ARBITRUM_NETWORK,
+				Orders:                    3,
+				Amount:                    "4",
+				FilledAmount:              "2",
+				SettlementInitiatedAmount: "2",
+				AgeBuckets: []ExposureBucket{
+					{Bucket: "<1h", Orders: 2, Amount: "3.5"},
+					{Bucket: "1d-7d", Orders: 1, Amount: "0.5"},
+				},
+			},
+		},
+	}, stats)
+}
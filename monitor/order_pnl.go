@@ -0,0 +1,302 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+const (
+	// EVM txs of the solver are linked to fills that happened in [fill - before, fill + after]
+	ORDER_LINK_WINDOW_BEFORE = 10 * time.Minute
+	ORDER_LINK_WINDOW_AFTER  = 6 * time.Hour
+	// unlinked fills older than this are not retried by the periodic job
+	ORDER_LINK_MAX_AGE = 7 * 24 * time.Hour
+)
+
+// how an order was linked to an EVM tx -- ordered by confidence.
+// Nonces are small integers that show up in unrelated calldata, so a nonce alone never links an order.
+const (
+	ORDER_MATCHED_BY_RECIPIENT       = "recipient"
+	ORDER_MATCHED_BY_NONCE_RECIPIENT = "nonce+recipient"
+)
+
+var orderMatchScore = map[string]int{
+	ORDER_MATCHED_BY_RECIPIENT:       1,
+	ORDER_MATCHED_BY_NONCE_RECIPIENT: 2,
+}
+
+// LinkableOrder is a successful fill that is not linked to an EVM tx yet
+type LinkableOrder struct {
+	TxHash    string
+	Nonce     uint32
+	Recipient string // bytes32 hex
+	Timestamp int64  // osmosis block time of the fill
+}
+
+// LinkableEvmTx is a tx sent by the solver address on an EVM network
+type LinkableEvmTx struct {
+	TxHash    string
+	Timestamp int64
+	Input     string // lowercase hex calldata
+}
+
+type OrderEvmLink struct {
+	OrderTxHash string
+	Nonce       uint32
+	Network     string
+	EthTxHash   string
+	MatchedBy   string
+	TimeDiff    int64 // seconds between the fill and the EVM tx
+}
+
+// OrderPnl is the net profit of a single fill.
+// Gas of an EVM tx that is linked to multiple orders is split evenly between them.
+type OrderPnl struct {
+	TxHash        string `json:"tx_hash"`
+	Nonce         uint32 `json:"nonce"`
+	Network       string `json:"network"`
+	Height        int64  `json:"height"`
+	Timestamp     int64  `json:"timestamp"`
+	AmountIn      string `json:"amount_in"`
+	AmountOut     string `json:"amount_out"`
+	SolverRevenue string `json:"solver_revenue"` // USDC
+	EthTxHash     string `json:"eth_tx_hash,omitempty"`
+	MatchedBy     string `json:"matched_by,omitempty"`
+	GasUsedUsd    string `json:"gas_used_usd"`
+	NetProfitUsd  string `json:"net_profit_usd"`
+}
+
+type NetworkPnl struct {
+	Network           string `json:"network"`
+	OrderCount        int64  `json:"order_count"`
+	LinkedOrderCount  int64  `json:"linked_order_count"`
+	TotalRevenue      string `json:"total_revenue"` // USDC
+	TotalGasUsedUsd   string `json:"total_gas_used_usd"`
+	TotalNetProfitUsd string `json:"total_net_profit_usd"`
+}
+
+// RunOrderLinking links recent fills with the chain as source domain to the EVM txs of the solver
+func (m *Monitor) RunOrderLinking(ctx context.Context, chain ChainConfig) error {
+	linked, err := m.LinkOrdersToEvmTxs(ctx, chain, time.Now().Add(-ORDER_LINK_MAX_AGE))
+	if err != nil {
+		return fmt.Errorf("failed to link orders to EVM txs: %w", err)
+	}
+	m.logger.Info().Int("linked", linked).Str("network", chain.Name).Msg("finished linking orders to EVM txs")
+	return nil
+}
+
+// LinkOrdersToEvmTxs links the unlinked fills of the solver filled since the given time.
+// Fills are matched to EVM txs by the order recipient (and nonce) found in the tx calldata
+// within the timing window around the fill. Returns the number of linked orders.
+func (m *Monitor) LinkOrdersToEvmTxs(ctx context.Context, chain ChainConfig, since time.Time) (int, error) {
+	if chain.ChainId == 0 || chain.Address == "" {
+		return 0, fmt.Errorf("chain id and address are required")
+	}
+	sourceDomain := strconv.Itoa(chain.ChainId)
+
+	orders, err := m.GetDbUnlinkedOrders(m.cfg.Osmosis.SolverAddress, sourceDomain, since.Unix())
+	if err != nil {
+		return 0, err
+	}
+	if len(orders) == 0 {
+		return 0, nil
+	}
+
+	minTs, maxTs := orders[0].Timestamp, orders[0].Timestamp
+	for _, o := range orders {
+		minTs = min(minTs, o.Timestamp)
+		maxTs = max(maxTs, o.Timestamp)
+	}
+	txs, err := m.GetDbSolverEvmTxs(chain.Name, chain.Address,
+		minTs-int64(ORDER_LINK_WINDOW_BEFORE.Seconds()),
+		maxTs+int64(ORDER_LINK_WINDOW_AFTER.Seconds()))
+	if err != nil {
+		return 0, err
+	}
+
+	linked := 0
+	for _, o := range orders {
+		tx, matchedBy, ok := matchOrderEvmTx(o, txs)
+		if !ok {
+			continue
+		}
+		err := m.InsertOrderEvmLink(ctx, OrderEvmLink{
+			OrderTxHash: o.TxHash,
+			Nonce:       o.Nonce,
+			Network:     chain.Name,
+			EthTxHash:   tx.TxHash,
+			MatchedBy:   matchedBy,
+			TimeDiff:    tx.Timestamp - o.Timestamp,
+		})
+		if err != nil {
+			m.logger.Error().Err(err).Str("tx_hash", o.TxHash).Str("network", chain.Name).Msg("failed to insert order link")
+			continue
+		}
+		linked++
+	}
+	return linked, nil
+}
+
+// matchOrderEvmTx returns the best matching tx in the timing window of the order. The calldata has to contain
+// the recipient, the nonce only ranks the candidates. Txs are expected to be sorted by timestamp.
+// Ties are broken by the time distance to the fill.
+func matchOrderEvmTx(order LinkableOrder, txs []LinkableEvmTx) (LinkableEvmTx, string, bool) {
+	from := order.Timestamp - int64(ORDER_LINK_WINDOW_BEFORE.Seconds())
+	to := order.Timestamp + int64(ORDER_LINK_WINDOW_AFTER.Seconds())
+	start := sort.Search(len(txs), func(i int) bool { return txs[i].Timestamp >= from })
+
+	nonceWord := fmt.Sprintf("%064x", order.Nonce)
+	recipient := strings.ToLower(strings.TrimPrefix(order.Recipient, "0x"))
+
+	var best LinkableEvmTx
+	bestMatch := ""
+	for _, tx := range txs[start:] {
+		if tx.Timestamp > to {
+			break
+		}
+
+		if len(recipient) != 64 || !strings.Contains(tx.Input, recipient) {
+			continue
+		}
+		matchedBy := ORDER_MATCHED_BY_RECIPIENT
+		if containsCalldataWord(tx.Input, nonceWord) {
+			matchedBy = ORDER_MATCHED_BY_NONCE_RECIPIENT
+		}
+
+		if bestMatch != "" {
+			if orderMatchScore[matchedBy] < orderMatchScore[bestMatch] {
+				continue
+			}
+			if orderMatchScore[matchedBy] == orderMatchScore[bestMatch] &&
+				absInt64(tx.Timestamp-order.Timestamp) >= absInt64(best.Timestamp-order.Timestamp) {
+				continue
+			}
+		}
+		best = tx
+		bestMatch = matchedBy
+	}
+	return best, bestMatch, bestMatch != ""
+}
+
+// containsCalldataWord checks whether the abi encoded args of the calldata contain the 32 byte word
+func containsCalldataWord(input, word string) bool {
+	input = strings.TrimPrefix(input, "0x")
+	// skip the 4 byte method selector
+	if len(input) < 8 {
+		return false
+	}
+	args := input[8:]
+	for i := 0; i+64 <= len(args); i += 64 {
+		if args[i:i+64] == word {
+			return true
+		}
+	}
+	return false
+}
+
+func absInt64(v int64) int64 {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// GetOrderPnl returns the net profit of successful fills of the filler in [from, to).
+// Network (optional) filters by the source domain of the orders. All amounts are returned as decimals.
+func (m *Monitor) GetOrderPnl(filler, network string, from, to time.Time) ([]OrderPnl, error) {
+	sourceDomain := ""
+	if network != "" {
+		chainId, ok := NetworkToChainId[network]
+		if !ok {
+			return nil, fmt.Errorf("invalid network: %s", network)
+		}
+		sourceDomain = chainId
+	}
+
+	orders, err := m.GetDbOrderPnl(filler, sourceDomain, from.Unix(), to.Unix())
+	if err != nil {
+		return nil, err
+	}
+	for i := range orders {
+		revenue, err := decimal.NewFromString(orders[i].SolverRevenue)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse revenue: %w", err)
+		}
+		gas, err := decimal.NewFromString(orders[i].GasUsedUsd)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse gas: %w", err)
+		}
+		amountIn, err := decimal.NewFromString(orders[i].AmountIn)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse amount in: %w", err)
+		}
+		amountOut, err := decimal.NewFromString(orders[i].AmountOut)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse amount out: %w", err)
+		}
+		// USDC is treated as 1 USD
+		orders[i].NetProfitUsd = revenue.Shift(-6).Sub(gas).String()
+		orders[i].SolverRevenue = revenue.Shift(-6).String()
+		orders[i].AmountIn = amountIn.Shift(-6).String()
+		orders[i].AmountOut = amountOut.Shift(-6).String()
+	}
+	return orders, nil
+}
+
+// AggregateNetworkPnl sums the order profits returned by GetOrderPnl per network
+func AggregateNetworkPnl(orders []OrderPnl) ([]NetworkPnl, error) {
+	type totals struct {
+		orders, linked    int64
+		revenue, gas, net decimal.Decimal
+	}
+	byNetwork := map[string]*totals{}
+	networks := []string{}
+	for _, o := range orders {
+		t, ok := byNetwork[o.Network]
+		if !ok {
+			t = &totals{revenue: decimal.Zero, gas: decimal.Zero, net: decimal.Zero}
+			byNetwork[o.Network] = t
+			networks = append(networks, o.Network)
+		}
+		revenue, err := decimal.NewFromString(o.SolverRevenue)
+		if err != nil {
+			return nil, err
+		}
+		gas, err := decimal.NewFromString(o.GasUsedUsd)
+		if err != nil {
+			return nil, err
+		}
+		net, err := decimal.NewFromString(o.NetProfitUsd)
+		if err != nil {
+			return nil, err
+		}
+		t.orders++
+		if o.EthTxHash != "" {
+			t.linked++
+		}
+		t.revenue = t.revenue.Add(revenue)
+		t.gas = t.gas.Add(gas)
+		t.net = t.net.Add(net)
+	}
+
+	sort.Strings(networks)
+	result := []NetworkPnl{}
+	for _, n := range networks {
+		t := byNetwork[n]
+		result = append(result, NetworkPnl{
+			Network:           n,
+			OrderCount:        t.orders,
+			LinkedOrderCount:  t.linked,
+			TotalRevenue:      t.revenue.String(),
+			TotalGasUsedUsd:   t.gas.String(),
+			TotalNetProfitUsd: t.net.String(),
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,156 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testRecipient = "7f636add968fb18ade66c2dd509689bc30d50b5750f667f796c687b3f0a0018b"
+
+func testCalldata(words ...string) string {
+	input := "0xabcdef01"
+	for _, w := range words {
+		input += w
+	}
+	return input
+}
+
+func TestMatchOrderEvmTx(t *testing.T) {
+	order := LinkableOrder{TxHash: "order", Nonce: 5475, Recipient: testRecipient, Timestamp: 1_000_000}
+	nonceWord := fmt.Sprintf("%064x", 5475)
+	otherWord := fmt.Sprintf("%064x", 1)
+
+	txs := []LinkableEvmTx{
+		// outside of the window
+		{TxHash: "too-early", Timestamp: order.Timestamp - 3600, Input: testCalldata(nonceWord, testRecipient)},
+		{TxHash: "recipient-far", Timestamp: order.Timestamp + 3000, Input: testCalldata(testRecipient)},
+		{TxHash: "recipient-near", Timestamp: order.Timestamp + 1000, Input: testCalldata(otherWord, testRecipient)},
+		// a nonce alone doesn't link the order
+		{TxHash: "nonce-only", Timestamp: order.Timestamp + 100, Input: testCalldata(otherWord, nonceWord)},
+		{TxHash: "unrelated", Timestamp: order.Timestamp + 10, Input: testCalldata(otherWord)},
+	}
+	tx, matchedBy, ok := matchOrderEvmTx(order, txs)
+	require.True(t, ok)
+	assert.Equal(t, "recipient-near", tx.TxHash)
+	assert.Equal(t, ORDER_MATCHED_BY_RECIPIENT, matchedBy)
+
+	_, _, ok = matchOrderEvmTx(order, txs[3:])
+	assert.False(t, ok)
+
+	// nonce and recipient beat a closer recipient only match
+	txs = append(txs, LinkableEvmTx{TxHash: "both", Timestamp: order.Timestamp + 7200, Input: testCalldata(testRecipient, nonceWord)})
+	tx, matchedBy, ok = matchOrderEvmTx(order, txs)
+	require.True(t, ok)
+	assert.Equal(t, "both", tx.TxHash)
+	assert.Equal(t, ORDER_MATCHED_BY_NONCE_RECIPIENT, matchedBy)
+
+	// nonce is only matched on word boundaries
+	misaligned := LinkableEvmTx{TxHash: "misaligned", Timestamp: order.Timestamp, Input: testCalldata(testRecipient, "00"+nonceWord+"000000000000000000000000000000000000000000000000000000000000")}
+	_, matchedBy, ok = matchOrderEvmTx(order, []LinkableEvmTx{misaligned})
+	require.True(t, ok)
+	assert.Equal(t, ORDER_MATCHED_BY_RECIPIENT, matchedBy)
+
+	// orders without a stored recipient are not linked
+	_, _, ok = matchOrderEvmTx(LinkableOrder{Nonce: 5475, Timestamp: order.Timestamp}, txs)
+	assert.False(t, ok)
+}
+
+func TestOrderPnl(t *testing.T) {
+	ctx := context.Background()
+	solver := "osmo1solver"
+	cfg := &Config{Chains: []ChainConfig{{
+		ChainEntry: ChainEntry{Address: "0xSolver"},
+		Name:       ARBITRUM_NETWORK,
+		Kind:       CHAIN_KIND_ETHERSCAN,
+	}}}
+	cfg.Osmosis.SolverAddress = solver
+	require.NoError(t, cfg.resolveChains())
+	m := newTestMonitorWithDB(t, cfg)
+
+	otherRecipient := fmt.Sprintf("%064x", 0xbeef)
+	unsettledRecipient := fmt.Sprintf("%064x", 0xcafe)
+	// two orders settled in a single EVM tx and one order without a matching tx
+	orders := []DbOrderFilled{
+		{TxHash: "A", AmountIn: "10000000", AmountOut: "9000000", SolverRevenue: 1000000, Height: 1, Nonce: 11, Recipient: testRecipient},
+		{TxHash: "B", AmountIn: "20000000", AmountOut: "19000000", SolverRevenue: 1000000, Height: 2, Nonce: 12, Recipient: otherRecipient},
+		{TxHash: "C", AmountIn: "5000000", AmountOut: "4500000", SolverRevenue: 500000, Height: 3, Nonce: 13, Recipient: unsettledRecipient},
+	}
+	for i, o := range orders {
+		o.Filler = solver
+		o.SourceDomain = NetworkToChainId[ARBITRUM_NETWORK]
+		require.NoError(t, m.InsertOrderFilled(ctx, o))
+		require.NoError(t, m.storeBlockTime(ctx, &BlockTime{Height: o.Height, Timestamp: int64(1_000_000 + i*60)}))
+	}
+
+	require.NoError(t, m.InsertEthTxResponse(ctx, EthTxDetails{
+		Hash:        "0xsettle",
+		From:        "0xSOLVER",
+		Input:       testCalldata(fmt.Sprintf("%064x", 11), testRecipient, fmt.Sprintf("%064x", 12), otherRecipient),
+		BlockNumber: "10",
+		TimeStamp:   "1000300",
+		GasUsed:     "1",
+		GasPrice:    "1",
+		GasUsedUsd:  "0.4",
+	}, ARBITRUM_NETWORK, false))
+	// nonce of C but not its recipient
+	require.NoError(t, m.InsertEthTxResponse(ctx, EthTxDetails{
+		Hash:        "0xnonce",
+		From:        "0xSOLVER",
+		Input:       testCalldata(fmt.Sprintf("%064x", 13)),
+		BlockNumber: "11",
+		TimeStamp:   "1000300",
+		GasUsed:     "1",
+		GasPrice:    "1",
+		GasUsedUsd:  "1",
+	}, ARBITRUM_NETWORK, false))
+	// same calldata but sent by someone else
+	require.NoError(t, m.InsertEthTxResponse(ctx, EthTxDetails{
+		Hash:        "0xother",
+		From:        "0xother",
+		Input:       testCalldata(fmt.Sprintf("%064x", 13), unsettledRecipient),
+		BlockNumber: "11",
+		TimeStamp:   "1000300",
+		GasUsed:     "1",
+		GasPrice:    "1",
+		GasUsedUsd:  "1",
+	}, ARBITRUM_NETWORK, false))
+
+	chain, _ := cfg.ChainByName(ARBITRUM_NETWORK)
+	linked, err := m.LinkOrdersToEvmTxs(ctx, chain, time.Unix(0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 2, linked)
+
+	// already linked orders are not linked again
+	linked, err = m.LinkOrdersToEvmTxs(ctx, chain, time.Unix(0, 0))
+	require.NoError(t, err)
+	assert.Equal(t, 0, linked)
+
+	pnl, err := m.GetOrderPnl(solver, "", time.Unix(0, 0), time.Unix(2_000_000, 0))
+	require.NoError(t, err)
+	require.Len(t, pnl, 3)
+
+	assert.Equal(t, "0xsettle", pnl[0].EthTxHash)
+	assert.Equal(t, ORDER_MATCHED_BY_NONCE_RECIPIENT, pnl[0].MatchedBy)
+	assert.Equal(t, "0.2", pnl[0].GasUsedUsd)
+	assert.Equal(t, "0.8", pnl[0].NetProfitUsd)
+	assert.Equal(t, "0xsettle", pnl[1].EthTxHash)
+	assert.Equal(t, "", pnl[2].EthTxHash)
+	assert.Equal(t, "0.5", pnl[2].NetProfitUsd)
+
+	networks, err := AggregateNetworkPnl(pnl)
+	require.NoError(t, err)
+	require.Len(t, networks, 1)
+	assert.Equal(t, NetworkPnl{
+		Network:           ARBITRUM_NETWORK,
+		OrderCount:        3,
+		LinkedOrderCount:  2,
+		TotalRevenue:      "2.5",
+		TotalGasUsedUsd:   "0.4",
+		TotalNetProfitUsd: "2.1",
+	}, networks[0])
+}
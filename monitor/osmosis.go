@@ -1,12 +1,11 @@
 package monitor
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math/big"
-	"net/http"
 	"net/url"
 	"os"
 	"slices"
@@ -21,6 +20,8 @@ import (
 	"github.com/shopspring/decimal"
 )
 
+const OSMOSIS_NETWORK = "osmosis"
+
 type TxsFile struct {
 	TxResponses []interface{} `json:"tx_responses"`
 	Txs         []interface{} `json:"txs"`
@@ -28,9 +29,8 @@ type TxsFile struct {
 
 type CosmosBalances []sdktypes.Coin
 
-func (m *Monitor) GetAllOsmosisOrders(contract_address string, apiUrl string, outputFile string) {
-	headers := map[string]string{"Accept": "application/json"}
-	baseURL := fmt.Sprintf("%s/cosmos/tx/v1beta1/txs", apiUrl)
+// GetAllOsmosisOrders saves all fill order txs to outputFile, pages are queried on the osmosis LCDs of the monitor
+func (m *Monitor) GetAllOsmosisOrders(ctx context.Context, contract_address string, outputFile string) {
 	query := fmt.Sprintf("wasm._contract_address='%s' AND wasm.action='order_filled'", contract_address)
 
 	attempts := 0
@@ -40,33 +40,20 @@ func (m *Monitor) GetAllOsmosisOrders(contract_address string, apiUrl string, ou
 	allTxs := []interface{}{}
 	allTxResponses := []interface{}{}
 	for attempts < maxRequests {
-		time.Sleep(2 * time.Second) // sleep between requests to avoid rate limiting
+		// sleep between requests to avoid rate limiting
+		if err := sleepCtx(ctx, 2*time.Second); err != nil {
+			m.logger.Warn().Err(err).Msg("fetching orders cancelled -- saving collected orders")
+			break
+		}
 		params := url.Values{}
 		params.Add("limit", "100")
 		params.Add("page", strconv.Itoa(attempts+1))
 		params.Add("query", query)
 
-		encodedParams := params.Encode()
-		fullURL := fmt.Sprintf("%s?%s", baseURL, encodedParams)
-		m.logger.Info().Str("url", fullURL).Int("attempts", attempts).Msg("fetching orders")
+		path := fmt.Sprintf("/cosmos/tx/v1beta1/txs?%s", params.Encode())
+		m.logger.Info().Str("path", path).Int("attempts", attempts).Msg("fetching orders")
 
-		req, err := http.NewRequest("GET", fullURL, nil)
-		if err != nil {
-			log.Fatal(err)
-		}
-
-		for key, value := range headers {
-			req.Header.Add(key, value)
-		}
-
-		client := &http.Client{}
-		resp, err := client.Do(req)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer resp.Body.Close()
-
-		body, err := io.ReadAll(resp.Body)
+		body, err := m.osmosis.Get(ctx, path)
 		if err != nil {
 			log.Fatal(err)
 		}
@@ -118,51 +105,120 @@ func (m *Monitor) GetAllOsmosisOrders(contract_address string, apiUrl string, ou
 	m.logger.Info().Str("file", outputFile).Msg("saved orders")
 }
 
-func (m *Monitor) GetNewOrders(height int, contractAddress string) ([]DbOrderFilled, []*DbTxResponse, error) {
-	headers := map[string]string{"Accept": "application/json"}
-	baseURL := fmt.Sprintf("%s/cosmos/tx/v1beta1/txs", m.apiUrl)
-	query := fmt.Sprintf("wasm._contract_address='%s' AND wasm.action='order_filled'", contractAddress)
+func ordersQuery(contractAddress string) string {
+	return fmt.Sprintf("wasm._contract_address='%s' AND wasm.action='order_filled'", contractAddress)
+}
 
-	params := url.Values{}
-	params.Add("order_by", "ORDER_BY_DESC")
-	params.Add("query", query)
+// page size and delay between pages when fetching new orders -- replaced in tests
+var (
+	osmosisOrdersPageLimit = 100
+	osmosisOrdersPageSleep = 500 * time.Millisecond
+)
 
-	url := fmt.Sprintf("%s?%s", baseURL, params.Encode())
-	req, err := http.NewRequest("GET", url, nil)
+// GetNewOrders fetches and decodes the order_filled txs above the stored max height.
+// With an empty db only the first page is fetched -- older orders are loaded with data_loader.
+func (m *Monitor) GetNewOrders(ctx context.Context, height int, contractAddress string) ([]DbOrderFilled, []*DbTxResponse, error) {
+	txResponses, err := m.getTxResponsesSince(ctx, ordersQuery(contractAddress), height)
 	if err != nil {
 		return nil, nil, err
 	}
 
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
+	orders, responses := m.decodeOrderTxResponses(txResponses)
+	return orders, responses, nil
+}
 
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, nil, err
+// getTxResponsesSince pages backwards (newest first) through the txs matching the query until it reaches the given height.
+// After the first page the query is pinned to its top height so txs landing in between don't shift the pages.
+// If any page fails nothing is returned, so the stored height is never advanced past a gap.
+// With height 0 only the first page is fetched.
+func (m *Monitor) getTxResponsesSince(ctx context.Context, query string, height int) ([]*sdktypes.TxResponse, error) {
+	txResponses := []*sdktypes.TxResponse{}
+	seen := map[string]bool{}
+	pinnedQuery := query
+	for page := 1; ; page++ {
+		if page > 1 {
+			if err := sleepCtx(ctx, osmosisOrdersPageSleep); err != nil {
+				return nil, err
+			}
+		}
+
+		data, err := m.getOrdersPage(ctx, pinnedQuery, page, osmosisOrdersPageLimit)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch orders page %d: %w", page, err)
+		}
+
+		reachedStored := false
+		for _, txResponse := range data.TxResponses {
+			if txResponse.Height <= int64(height) {
+				reachedStored = true
+				continue
+			}
+			if seen[txResponse.TxHash] {
+				continue
+			}
+			seen[txResponse.TxHash] = true
+			txResponses = append(txResponses, txResponse)
+		}
+
+		if page == 1 && len(data.TxResponses) > 0 {
+			pinnedQuery = fmt.Sprintf("%s AND tx.height<=%d", query, data.TxResponses[0].Height)
+		}
+
+		if reachedStored || len(data.TxResponses) < osmosisOrdersPageLimit || uint64(page*osmosisOrdersPageLimit) >= data.Total {
+			break
+		}
+		if height == 0 {
+			m.logger.Warn().Str("query", query).Msg("nothing stored yet -- only the latest page is fetched, load older txs with data_loader")
+			break
+		}
+		m.logger.Info().Int("page", page).Int("txs", len(txResponses)).Int("stored_height", height).Msg("stored height not reached -- fetching next page")
 	}
-	defer resp.Body.Close()
+	return txResponses, nil
+}
+
+func (m *Monitor) getOrdersPage(ctx context.Context, query string, page, limit int) (*tx.GetTxsEventResponse, error) {
+	params := url.Values{}
+	params.Add("order_by", "ORDER_BY_DESC")
+	params.Add("query", query)
+	params.Add("limit", strconv.Itoa(limit))
+	params.Add("page", strconv.Itoa(page))
 
-	body, err := io.ReadAll(resp.Body)
+	body, err := m.osmosis.Get(ctx, fmt.Sprintf("/cosmos/tx/v1beta1/txs?%s", params.Encode()))
 	if err != nil {
-		return nil, nil, err
+		return nil, err
 	}
 
 	var data tx.GetTxsEventResponse
 	if err := m.Codec.UnmarshalJSON(body, &data); err != nil {
 		m.logger.Error().Err(err).Msg("failed to unmarshal GetTxsEventResponse")
-		return nil, nil, err
+		return nil, err
 	}
+	return &data, nil
+}
 
+// decodeOrderTxResponses decodes the fill orders and raw tx responses stored in the db
+func (m *Monitor) decodeOrderTxResponses(txResponses []*sdktypes.TxResponse) ([]DbOrderFilled, []*DbTxResponse) {
 	orders := []DbOrderFilled{}
 	responses := []*DbTxResponse{}
-	for _, txResponse := range data.TxResponses {
+	for _, txResponse := range txResponses {
 		fillOrders := m.DecodeTxResponse(txResponse)
+		events := parseOrderFilledEvents(txResponse.Events)
+		blockTimestamp := int64(0)
+		if blockTime, err := time.Parse(time.RFC3339, txResponse.Timestamp); err == nil {
+			blockTimestamp = blockTime.Unix()
+		}
 		for _, fillOrder := range fillOrders {
 			amountIn, _ := new(big.Int).SetString(fillOrder.FillOrder.Order.AmountIn, 10)
 			amountOut, _ := new(big.Int).SetString(fillOrder.FillOrder.Order.AmountOut, 10)
 			revenue := amountIn.Sub(amountIn, amountOut)
+			orderId, mismatch := crossCheckOrderEvents(fillOrder, txResponse.Code, events)
+			if mismatch != "" {
+				m.logger.Warn().
+					Str("tx_hash", txResponse.TxHash).
+					Int64("height", txResponse.Height).
+					Str("mismatch", mismatch).
+					Msg("fill order message and tx events disagree")
+			}
 			orders = append(orders, DbOrderFilled{
 				Code:               int64(txResponse.Code),
 				TxHash:             txResponse.TxHash,
@@ -174,6 +230,16 @@ func (m *Monitor) GetNewOrders(height int, contractAddress string) ([]DbOrderFil
 				SolverRevenue:      revenue.Int64(),
 				IngestionTimestamp: time.Now(),
 				Filler:             fillOrder.FillOrder.Filler,
+				Recipient:          fillOrder.FillOrder.Order.Recipient,
+				Nonce:              fillOrder.FillOrder.Order.Nonce,
+				DestinationDomain:  strconv.Itoa(int(fillOrder.FillOrder.Order.DestinationDomain)),
+				TimeoutTimestamp:   fillOrder.FillOrder.Order.TimeoutTimestamp,
+				Data:               fillOrder.FillOrder.Order.Data,
+				OrderId:            orderId,
+				EventMismatch:      mismatch,
+				MsgIndex:           fillOrder.MsgIndex,
+				AuthzMsgIndex:      fillOrder.AuthzMsgIndex,
+				BlockTimestamp:     blockTimestamp,
 			})
 		}
 
@@ -189,7 +255,7 @@ func (m *Monitor) GetNewOrders(height int, contractAddress string) ([]DbOrderFil
 			TxResponse: s,
 		})
 	}
-	return orders, responses, nil
+	return orders, responses
 }
 
 func (m *Monitor) OrdersFromFile(filePath string) ([]DbOrderFilled, []*DbTxResponse, error) {
@@ -208,56 +274,24 @@ func (m *Monitor) OrdersFromFile(filePath string) ([]DbOrderFilled, []*DbTxRespo
 		return nil, nil, err
 	}
 
-	orders := []DbOrderFilled{}
-	responses := []*DbTxResponse{}
-
 	m.logger.Info().Int("count", len(data.TxResponses)).Msg("found records in file - decoding")
-	for _, txResponse := range data.TxResponses {
-		fillOrders := m.DecodeTxResponse(txResponse)
-		for _, fillOrder := range fillOrders {
-			amountIn, _ := new(big.Int).SetString(fillOrder.FillOrder.Order.AmountIn, 10)
-			amountOut, _ := new(big.Int).SetString(fillOrder.FillOrder.Order.AmountOut, 10)
-			revenue := amountIn.Sub(amountIn, amountOut)
-			orders = append(orders, DbOrderFilled{
-				Code:               int64(txResponse.Code),
-				TxHash:             txResponse.TxHash,
-				Height:             txResponse.Height,
-				Sender:             fillOrder.FillOrder.Order.Sender,
-				AmountIn:           fillOrder.FillOrder.Order.AmountIn,
-				AmountOut:          fillOrder.FillOrder.Order.AmountOut,
-				SourceDomain:       strconv.Itoa(int(fillOrder.FillOrder.Order.SourceDomain)),
-				SolverRevenue:      revenue.Int64(),
-				IngestionTimestamp: time.Now(),
-				Filler:             fillOrder.FillOrder.Filler,
-			})
-		}
-
-		s, err := m.Codec.MarshalJSON(txResponse)
-		if err != nil {
-			m.logger.Error().Err(err).Msg("failed to marshal tx response")
-			continue
-		}
-		responses = append(responses, &DbTxResponse{
-			TxHash:     txResponse.TxHash,
-			Height:     txResponse.Height,
-			Valid:      txResponse.Code == 0,
-			TxResponse: s,
-		})
-	}
+	orders, responses := m.decodeOrderTxResponses(data.TxResponses)
 	m.logger.Info().Int("orders", len(orders)).Int("responses", len(responses)).Msg("decoded orders and responses")
 	return orders, responses, nil
 }
 
-func (m *Monitor) RunOrders(saveRawResponses bool) {
+// RunOrders stores the orders filled since the stored max height.
+// Orders are inserted in ascending height and a failed insert stops the run, so the stored max height never skips an order.
+func (m *Monitor) RunOrders(ctx context.Context, saveRawResponses bool) error {
 	contractAddress := m.cfg.Osmosis.ContractAddress
 	solverAddress := m.cfg.Osmosis.SolverAddress
 
 	minHeight, maxHeight := int64(0), int64(0)
 	latestHeight := m.GetLatestHeight()
-	newOrders, rawResponses, err := m.GetNewOrders(latestHeight, contractAddress)
+	newOrders, rawResponses, err := m.GetNewOrders(ctx, latestHeight, contractAddress)
 	if err != nil {
 		m.logger.Error().Str("event", "Error fetching new orders").Err(err).Send()
-		return
+		return err
 	}
 
 	if len(newOrders) > 0 {
@@ -273,7 +307,7 @@ func (m *Monitor) RunOrders(saveRawResponses bool) {
 
 	if int64(latestHeight) >= maxHeight {
 		m.logger.Info().Msg("no new solver fill orders on osmosis -- skipping processing")
-		return
+		return nil
 	}
 
 	if saveRawResponses {
@@ -281,7 +315,7 @@ func (m *Monitor) RunOrders(saveRawResponses bool) {
 			if int64(latestHeight) >= tx.Height {
 				continue
 			}
-			err := m.InsertRawTxResponse(*tx)
+			err := m.InsertRawTxResponse(ctx, *tx)
 			if err != nil {
 				m.logger.Error().Err(err).
 					Str("tx_hash", tx.TxHash).
@@ -292,92 +326,116 @@ func (m *Monitor) RunOrders(saveRawResponses bool) {
 		}
 	}
 
+	sort.SliceStable(newOrders, func(i, j int) bool { return newOrders[i].Height < newOrders[j].Height })
+	// the fills of a height are stored together -- the stored max height is where the next run continues
 	saved := 0
-	for _, tx := range newOrders {
-		if int64(latestHeight) >= tx.Height {
+	for start := 0; start < len(newOrders); {
+		height := newOrders[start].Height
+		end := start
+		for end < len(newOrders) && newOrders[end].Height == height {
+			end++
+		}
+		orders := newOrders[start:end]
+		start = end
+		if int64(latestHeight) >= height {
 			continue
 		}
-		err := m.InsertOrderFilled(tx)
-		if err != nil {
+
+		if err := m.InsertOrdersFilled(ctx, orders); err != nil {
 			m.logger.Error().Err(err).
-				Str("tx_hash", tx.TxHash).
-				Str("filler", tx.Filler).
-				Str("amount_in", tx.AmountIn).
-				Str("amount_out", tx.AmountOut).
-				Str("source_domain", tx.SourceDomain).
-				Int64("height", tx.Height).
-				Msg("failed to insert order filled from osmosis")
-			continue
+				Int("count", len(orders)).
+				Int64("height", height).
+				Msg("failed to insert orders filled from osmosis -- stopping before later heights")
+			return fmt.Errorf("failed to insert orders at height %d: %w", height, err)
 		}
-		if solverAddress != "" && tx.Filler == solverAddress {
-			m.logger.Info().
-				Str("tx_hash", tx.TxHash).
-				Int("height", int(tx.Height)).
-				Int("revenue", int(tx.SolverRevenue)).
-				Msg("monitored solver filled order on osmosis")
+		for _, tx := range orders {
+			if solverAddress != "" && tx.Filler == solverAddress {
+				m.logger.Info().
+					Str("tx_hash", tx.TxHash).
+					Int("height", int(tx.Height)).
+					Int("revenue", int(tx.SolverRevenue)).
+					Msg("monitored solver filled order on osmosis")
+			}
 		}
-		saved++
+		saved += len(orders)
 	}
 	m.logger.Info().Int("count", saved).Msg("saved solver fill orders from osmosis")
+	return nil
+}
+
+type cosmosAdapter struct {
+	m     *Monitor
+	chain ChainConfig
+	lcd   *LcdClient
+}
+
+// osmosis shares the LCDs of the monitor, other cosmos chains query their api_url
+func newCosmosAdapter(m *Monitor, chain ChainConfig) ChainAdapter {
+	lcd := m.osmosis
+	if chain.Name != OSMOSIS_NETWORK {
+		lcd = NewLcdClient([]string{chain.ApiUrl}, m.logger)
+	}
+	return &cosmosAdapter{m: m, chain: chain, lcd: lcd}
+}
+
+func (a *cosmosAdapter) Network() string {
+	return a.chain.Name
+}
+
+func (a *cosmosAdapter) RunBalances(ctx context.Context) error {
+	return a.m.RunCosmosBalances(ctx, a.chain, a.lcd)
+}
+
+// gas is paid in the native denom and is not tracked for cosmos chains
+func (a *cosmosAdapter) RunTxHistory(ctx context.Context, saveRawResponses bool) error {
+	return nil
 }
 
-func (m *Monitor) RunOsmosisBalances() {
-	apiUrl := m.cfg.Osmosis.ApiUrl
-	address := m.cfg.Osmosis.Address
-	usdcDenom := m.cfg.Osmosis.UsdcAddress
+func (m *Monitor) RunCosmosBalances(ctx context.Context, chain ChainConfig, lcd *LcdClient) error {
+	address := chain.Address
+	usdcDenom := chain.UsdcAddress
+	network := chain.Name
 	useTs := time.Now()
 
-	balances, err := m.getCosmosBalance(apiUrl, address, []string{"uosmo", usdcDenom})
+	balances, err := m.getCosmosBalance(ctx, lcd, address, []string{chain.NativeToken, usdcDenom})
 	if err != nil {
-		m.logger.Error().Err(err).Str("address", address).Str("network", "osmosis").Msg("failed to get cosmos balances")
-		return
+		return fmt.Errorf("failed to get cosmos balances: %w", err)
 	}
 
-	buildLog := m.logger.With().Str("network", "osmosis").Str("datetime", useTs.Format(time.RFC3339)).Logger()
+	buildLog := m.logger.With().Str("network", network).Str("datetime", useTs.Format(time.RFC3339)).Logger()
 	for _, balance := range balances {
 		asDecimal := decimal.NewFromInt(balance.Amount.Int64())
 		humanReadableDenom := strings.ToUpper(balance.Denom)
 		if balance.Denom == usdcDenom {
 			humanReadableDenom = "USDC"
 		}
-		buildLog = buildLog.With().Str(strings.Replace(humanReadableDenom, "UOSMO", "OSMO", 1), asDecimal.Shift(-6).String()).Logger()
-		m.InsertBalance(DbBalance{
+		// micro denoms are logged without the "u" prefix (uosmo -> OSMO)
+		logDenom := humanReadableDenom
+		if strings.HasPrefix(balance.Denom, "u") {
+			logDenom = strings.ToUpper(balance.Denom[1:])
+		}
+		buildLog = buildLog.With().Str(logDenom, asDecimal.Shift(-6).String()).Logger()
+		err := m.InsertBalance(ctx, DbBalance{
 			Timestamp: useTs.Unix(),
 			Balance:   balance.Amount.String(),
 			Exponent:  6,
 			Token:     humanReadableDenom,
 			Address:   address,
-			Network:   "osmosis",
+			Network:   network,
 		})
+		if err != nil {
+			return fmt.Errorf("failed to insert %s balance: %w", humanReadableDenom, err)
+		}
 	}
 
 	buildLog.Info().Msg("current balance")
-
+	return nil
 }
 
 // denoms is a list of native and IBC denoms
 // e.g. ["osmo", "ibc/498A0751C798A0D9A389AA3691123DADA57DAA4FE165D5C75894505B876BA6E4"]
-func (m *Monitor) getCosmosBalance(apiUrl, address string, denoms []string) (CosmosBalances, error) {
-	headers := map[string]string{"Accept": "application/json"}
-	url := fmt.Sprintf("%s/cosmos/bank/v1beta1/balances/%s", apiUrl, address)
-
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
-
-	for key, value := range headers {
-		req.Header.Add(key, value)
-	}
-
-	client := &http.Client{}
-	resp, err := client.Do(req)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
+func (m *Monitor) getCosmosBalance(ctx context.Context, lcd *LcdClient, address string, denoms []string) (CosmosBalances, error) {
+	body, err := lcd.Get(ctx, fmt.Sprintf("/cosmos/bank/v1beta1/balances/%s", address))
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,177 @@
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"sort"
+	"strconv"
+	"testing"
+	"time"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var heightConditionRe = regexp.MustCompile(`tx\.height(<=|>=|<)(\d+)`)
+
+// fakeOrdersNode serves the txs returned by served newest first and applies the tx.height conditions of the query
+func fakeOrdersNode(m *Monitor, served func() []*sdktypes.TxResponse) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		page, _ := strconv.Atoi(q.Get("page"))
+		limit, _ := strconv.Atoi(q.Get("limit"))
+
+		matching := []*sdktypes.TxResponse{}
+	txs:
+		for _, txResponse := range served() {
+			for _, cond := range heightConditionRe.FindAllStringSubmatch(q.Get("query"), -1) {
+				h, _ := strconv.ParseInt(cond[2], 10, 64)
+				if (cond[1] == "<=" && txResponse.Height > h) || (cond[1] == ">=" && txResponse.Height < h) || (cond[1] == "<" && txResponse.Height >= h) {
+					continue txs
+				}
+			}
+			matching = append(matching, txResponse)
+		}
+		sort.Slice(matching, func(i, j int) bool { return matching[i].Height > matching[j].Height })
+		from := min((page-1)*limit, len(matching))
+		to := min(page*limit, len(matching))
+		body, _ := m.Codec.MarshalJSON(&tx.GetTxsEventResponse{TxResponses: matching[from:to], Total: uint64(len(matching))})
+		w.Write(body)
+	}
+}
+
+func TestGetNewOrdersPaginates(t *testing.T) {
+	osmosisOrdersPageLimit = 2
+	osmosisOrdersPageSleep = 0
+	defer func() { osmosisOrdersPageLimit = 100 }()
+
+	m := newTestMonitor()
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+	served := fixture.TxResponses
+
+	requests := 0
+	failPage := 0
+	node := fakeOrdersNode(m, func() []*sdktypes.TxResponse { return served })
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		page, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		if page == failPage {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		node(w, r)
+
+		// a new fill lands while the pages are fetched
+		if requests == 1 {
+			landed := *fixture.TxResponses[0]
+			landed.TxHash = "LANDED"
+			landed.Height += 10
+			served = append([]*sdktypes.TxResponse{&landed}, served...)
+		}
+	}))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	stored := int(fixture.TxResponses[4].Height)
+	_, responses, err := m.GetNewOrders(context.Background(), stored, "contract")
+	require.NoError(t, err)
+	assert.Equal(t, 3, requests)
+
+	heights := []int64{}
+	for _, r := range responses {
+		heights = append(heights, r.Height)
+	}
+	assert.Equal(t, []int64{31834305, 31834269, 31834136, 31833882}, heights)
+
+	// a failed page returns nothing so the stored height is not advanced
+	served = fixture.TxResponses
+	requests = 0
+	failPage = 2
+	orders, responses, err := m.GetNewOrders(context.Background(), stored, "contract")
+	assert.Error(t, err)
+	assert.Empty(t, orders)
+	assert.Empty(t, responses)
+}
+
+func TestRunOrdersStoresBlockTimes(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	m := newTestMonitorWithDB(t, &Config{})
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+	srv := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return fixture.TxResponses }))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	require.NoError(t, m.RunOrders(context.Background(), false))
+
+	expected := map[int64]int64{}
+	for _, r := range fixture.TxResponses {
+		ts, err := time.Parse(time.RFC3339, r.Timestamp)
+		require.NoError(t, err)
+		expected[r.Height] = ts.Unix()
+	}
+	// a height is stored once even with several fills in the block
+	require.NoError(t, m.storeBlockTime(context.Background(), &BlockTime{Height: fixture.TxResponses[0].Height, Timestamp: 1}))
+
+	rows, err := m.db.Query(`SELECT height, timestamp FROM osmo_block_times`)
+	require.NoError(t, err)
+	defer rows.Close()
+	stored := map[int64]int64{}
+	for rows.Next() {
+		var height, timestamp int64
+		require.NoError(t, rows.Scan(&height, &timestamp))
+		assert.NotContains(t, stored, height)
+		stored[height] = timestamp
+	}
+	require.NoError(t, rows.Err())
+	assert.Equal(t, expected, stored)
+}
+
+func TestRunOrdersStoresHeightsTogether(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+
+	// a second fill in the block of the fourth newest one
+	height := fixture.TxResponses[3].Height
+	sameHeight := *fixture.TxResponses[3]
+	sameHeight.TxHash = "SAME_HEIGHT"
+	served := append([]*sdktypes.TxResponse{&sameHeight}, fixture.TxResponses...)
+	srv := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return served }))
+	defer srv.Close()
+	m.osmosis = NewLcdClient([]string{srv.URL}, m.logger)
+
+	// fails the second fill of a height
+	_, err := m.db.Exec(`
+		CREATE TRIGGER fail_second_fill BEFORE INSERT ON tx_data
+		WHEN EXISTS (SELECT 1 FROM tx_data WHERE height = NEW.height)
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END
+	`)
+	require.NoError(t, err)
+
+	countAt := func(table string) int {
+		var count int
+		require.NoError(t, m.db.QueryRow("SELECT COUNT(*) FROM "+table+" WHERE height = ?", height).Scan(&count))
+		return count
+	}
+
+	require.Error(t, m.RunOrders(ctx, false))
+	// the lower heights are stored, nothing of the failed height is
+	assert.Equal(t, int(fixture.TxResponses[4].Height), m.GetLatestHeight())
+	assert.Zero(t, countAt("tx_data"))
+	assert.Zero(t, countAt("osmo_block_times"))
+
+	_, err = m.db.Exec("DROP TRIGGER fail_second_fill")
+	require.NoError(t, err)
+	require.NoError(t, m.RunOrders(ctx, false))
+	assert.Equal(t, int(fixture.TxResponses[0].Height), m.GetLatestHeight())
+	assert.Equal(t, 2, countAt("tx_data"))
+	assert.Equal(t, 1, countAt("osmo_block_times"))
+}
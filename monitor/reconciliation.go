@@ -0,0 +1,191 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/shopspring/decimal"
+)
+
+// residual (in token units) above which a reconciliation entry is flagged
+const DEFAULT_RECONCILIATION_THRESHOLD = 0.01
+
+// ReconciliationEntry explains the balance change of a token on a network between two balance snapshots.
+//
+// The explained delta is made up of the tracked flows:
+//   - native token: tx values in - tx values out + internal txs in - internal txs out - gas spent by the solver
+//   - USDC: token transfers in - token transfers out
+//
+// Settlement payouts arrive as USDC transfers and already include the fill revenue, so the attributed
+// revenue is not part of the explained delta. It is reported next to it with its own residual
+// (delta - attributed revenue). Only the residual of the explained delta flags an entry.
+// All amounts are integer strings unless converted by the caller.
+type ReconciliationEntry struct {
+	Network           string `json:"network"`
+	Token             string `json:"token"`
+	Exponent          int64  `json:"exponent,omitempty"`
+	StartTimestamp    int64  `json:"start_timestamp"`
+	EndTimestamp      int64  `json:"end_timestamp"`
+	StartBalance      string `json:"start_balance"`
+	EndBalance        string `json:"end_balance"`
+	Delta             string `json:"delta"`
+	GasSpent          string `json:"gas_spent"`
+	ValueIn           string `json:"value_in"`
+	ValueOut          string `json:"value_out"`
+	InternalIn        string `json:"internal_in"`
+	InternalOut       string `json:"internal_out"`
+	TransfersIn       string `json:"transfers_in"`
+	TransfersOut      string `json:"transfers_out"`
+	AttributedRevenue string `json:"attributed_revenue"`
+	ExplainedDelta    string `json:"explained_delta"`
+	Residual          string `json:"residual"`
+	RevenueResidual   string `json:"revenue_residual"`
+	Flagged           bool   `json:"flagged"`
+}
+
+// GetReconciliationReport reconciles the balances of the network (or all configured networks if network is empty)
+// between from and to. Cosmos chains are left out until their flows are tracked. Entries with an absolute residual above threshold (in token units, e.g. 0.5 USDC) are flagged.
+func (m *Monitor) GetReconciliationReport(network string, from, to time.Time, threshold decimal.Decimal) ([]ReconciliationEntry, error) {
+	networks := []string{}
+	if network != "" {
+		networks = append(networks, network)
+	} else {
+		for _, c := range m.cfg.Chains {
+			networks = append(networks, c.Name)
+		}
+	}
+
+	report := []ReconciliationEntry{}
+	for _, n := range networks {
+		entries, err := m.reconcileNetwork(n, from, to, threshold)
+		if err != nil {
+			return nil, fmt.Errorf("failed to reconcile %s: %w", n, err)
+		}
+		report = append(report, entries...)
+	}
+	return report, nil
+}
+
+func (m *Monitor) reconcileNetwork(network string, from, to time.Time, threshold decimal.Decimal) ([]ReconciliationEntry, error) {
+	chain, _ := m.cfg.ChainByName(network)
+	if chain.Kind == CHAIN_KIND_COSMOS_LCD {
+		// the token movements of cosmos chains (e.g. the amount_out of osmosis fills) are not tracked,
+		// every balance change would be flagged
+		return nil, nil
+	}
+
+	starts, err := m.GetDbBalanceSnapshots(network, from, to, false)
+	if err != nil {
+		return nil, err
+	}
+	ends, err := m.GetDbBalanceSnapshots(network, from, to, true)
+	if err != nil {
+		return nil, err
+	}
+	endByToken := map[string]DbBalance{}
+	for _, b := range ends {
+		endByToken[b.Token] = b
+	}
+
+	entries := []ReconciliationEntry{}
+	for _, start := range starts {
+		end, ok := endByToken[start.Token]
+		if !ok || end.Timestamp <= start.Timestamp {
+			// a single snapshot in range -- nothing to reconcile
+			continue
+		}
+
+		startBalance, err := decimal.NewFromString(start.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse balance: %w", err)
+		}
+		endBalance, err := decimal.NewFromString(end.Balance)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse balance: %w", err)
+		}
+
+		gasSpent, valueIn, valueOut, internalIn, internalOut := decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero, decimal.Zero
+		transfersIn, transfersOut, revenue := decimal.Zero, decimal.Zero, decimal.Zero
+		explained := decimal.Zero
+
+		switch {
+		case start.Token == chain.NativeToken:
+			gas, err := m.GetDbGasSpent(network, chain.Address, start.Timestamp, end.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			txIn, txOut, err := m.GetDbTxValueTotals(network, chain.Address, start.Timestamp, end.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			in, out, err := m.GetDbInternalTxTotals(network, start.Timestamp, end.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			gasSpent = decimal.NewFromBigInt(gas, 0)
+			valueIn = decimal.NewFromBigInt(txIn, 0)
+			valueOut = decimal.NewFromBigInt(txOut, 0)
+			internalIn = decimal.NewFromBigInt(in, 0)
+			internalOut = decimal.NewFromBigInt(out, 0)
+			explained = valueIn.Sub(valueOut).Add(internalIn).Sub(internalOut).Sub(gasSpent)
+
+		case start.Token == "USDC":
+			in, out, err := m.GetDbTokenTransferTotals(network, start.Token, start.Timestamp, end.Timestamp)
+			if err != nil {
+				return nil, err
+			}
+			if sourceDomain, ok := NetworkToChainId[network]; ok && m.cfg.Osmosis.SolverAddress != "" {
+				r, err := m.GetDbAttributedRevenue(m.cfg.Osmosis.SolverAddress, sourceDomain, start.Timestamp, end.Timestamp)
+				if err != nil {
+					return nil, err
+				}
+				revenue = decimal.NewFromInt(r)
+			}
+			transfersIn = decimal.NewFromInt(in)
+			transfersOut = decimal.NewFromInt(out)
+			explained = transfersIn.Sub(transfersOut)
+		}
+
+		delta := endBalance.Sub(startBalance)
+		residual := delta.Sub(explained)
+		entries = append(entries, ReconciliationEntry{
+			Network:           network,
+			Token:             start.Token,
+			Exponent:          start.Exponent,
+			StartTimestamp:    start.Timestamp,
+			EndTimestamp:      end.Timestamp,
+			StartBalance:      startBalance.String(),
+			EndBalance:        endBalance.String(),
+			Delta:             delta.String(),
+			GasSpent:          gasSpent.String(),
+			ValueIn:           valueIn.String(),
+			ValueOut:          valueOut.String(),
+			InternalIn:        internalIn.String(),
+			InternalOut:       internalOut.String(),
+			TransfersIn:       transfersIn.String(),
+			TransfersOut:      transfersOut.String(),
+			AttributedRevenue: revenue.String(),
+			ExplainedDelta:    explained.String(),
+			Residual:          residual.String(),
+			RevenueResidual:   delta.Sub(revenue).String(),
+			Flagged:           residual.Abs().Shift(-int32(start.Exponent)).GreaterThan(threshold),
+		})
+	}
+	return entries, nil
+}
+
+// Shift converts all amounts of the entry from integer strings to decimals
+func (e *ReconciliationEntry) Shift() error {
+	for _, v := range []*string{
+		&e.StartBalance, &e.EndBalance, &e.Delta, &e.GasSpent, &e.ValueIn, &e.ValueOut, &e.InternalIn, &e.InternalOut,
+		&e.TransfersIn, &e.TransfersOut, &e.AttributedRevenue, &e.ExplainedDelta, &e.Residual, &e.RevenueResidual,
+	} {
+		asDecimal, err := decimal.NewFromString(*v)
+		if err != nil {
+			return err
+		}
+		*v = asDecimal.Shift(-int32(e.Exponent)).String()
+	}
+	e.Exponent = 0
+	return nil
+}
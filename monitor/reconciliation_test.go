@@ -0,0 +1,181 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReconciliationReport(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Chains: []ChainConfig{{Name: ARBITRUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN, ChainEntry: ChainEntry{Address: testSolverAddress}}}}
+	require.NoError(t, cfg.resolveChains())
+	m := newTestMonitorWithDB(t, cfg)
+
+	start := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	balances := []DbBalance{
+		{Timestamp: start.Unix(), Balance: "1000000000000000000", Exponent: 18, Token: "ETH", Network: ARBITRUM_NETWORK},
+		{Timestamp: end.Unix(), Balance: "900000000000000000", Exponent: 18, Token: "ETH", Network: ARBITRUM_NETWORK},
+		{Timestamp: start.Unix(), Balance: "100000000", Exponent: 6, Token: "USDC", Network: ARBITRUM_NETWORK},
+		{Timestamp: end.Unix(), Balance: "150000000", Exponent: 6, Token: "USDC", Network: ARBITRUM_NETWORK},
+	}
+	for _, b := range balances {
+		require.NoError(t, m.InsertBalance(ctx, b))
+	}
+
+	// gas and values explain the ETH delta fully: 0.2 in - 0.15 out - 0.15 gas
+	other := "0x2222222222222222222222222222222222222222"
+	for _, tx := range []EthTxDetails{
+		{Hash: "0x1", From: testSolverAddress, To: other, Value: "0", GasUsed: "100000", IsError: "0"},
+		// the gas of an incoming tx is paid by the sender
+		{Hash: "0x4", From: other, To: testSolverAddress, Value: "200000000000000000", GasUsed: "100000", IsError: "0"},
+		{Hash: "0x5", From: testSolverAddress, To: other, Value: "150000000000000000", GasUsed: "50000", IsError: "0"},
+		// a failed tx doesn't transfer its value
+		{Hash: "0x6", From: testSolverAddress, To: other, Value: "1000000000000000000", GasUsed: "0", IsError: "1"},
+	} {
+		tx.BlockNumber = "10"
+		tx.TimeStamp = "1738400000"
+		tx.GasPrice = "1000000000000"
+		require.NoError(t, m.InsertEthTxResponse(ctx, tx, ARBITRUM_NETWORK, false))
+	}
+
+	// transfers explain 40 out of the 50 USDC delta
+	_, err := m.InsertTokenTransfer(ctx, DbTokenTransfer{TxHash: "0x2", Network: ARBITRUM_NETWORK, Token: "USDC", Direction: TRANSFER_DIRECTION_IN, Amount: 60000000, Timestamp: 1738400000})
+	require.NoError(t, err)
+	_, err = m.InsertTokenTransfer(ctx, DbTokenTransfer{TxHash: "0x3", Network: ARBITRUM_NETWORK, Token: "USDC", Direction: TRANSFER_DIRECTION_OUT, Amount: 20000000, Timestamp: 1738400000})
+	require.NoError(t, err)
+
+	report, err := m.GetReconciliationReport(ARBITRUM_NETWORK, start, end, decimal.NewFromFloat(1))
+	require.NoError(t, err)
+	require.Len(t, report, 2)
+
+	byToken := map[string]ReconciliationEntry{}
+	for _, e := range report {
+		byToken[e.Token] = e
+	}
+
+	eth := byToken["ETH"]
+	assert.Equal(t, "150000000000000000", eth.GasSpent)
+	assert.Equal(t, "200000000000000000", eth.ValueIn)
+	assert.Equal(t, "150000000000000000", eth.ValueOut)
+	assert.Equal(t, "-100000000000000000", eth.ExplainedDelta)
+	assert.Equal(t, "0", eth.Residual)
+	assert.False(t, eth.Flagged)
+
+	usdc := byToken["USDC"]
+	assert.Equal(t, "40000000", usdc.ExplainedDelta)
+	assert.Equal(t, "10000000", usdc.Residual)
+	assert.True(t, usdc.Flagged)
+
+	assert.Equal(t, "0", usdc.AttributedRevenue)
+	assert.Equal(t, "50000000", usdc.RevenueResidual)
+
+	require.NoError(t, usdc.Shift())
+	assert.Equal(t, "10", usdc.Residual)
+	assert.Equal(t, "50", usdc.RevenueResidual)
+}
+
+func TestReconciliationReportRevenue(t *testing.T) {
+	ctx := context.Background()
+	solver := "osmo1solver"
+	cfg := &Config{Chains: []ChainConfig{{Name: ARBITRUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN}}}
+	cfg.Osmosis.SolverAddress = solver
+	require.NoError(t, cfg.resolveChains())
+	m := newTestMonitorWithDB(t, cfg)
+
+	start := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for _, b := range []DbBalance{
+		{Timestamp: start.Unix(), Balance: "100000000", Exponent: 6, Token: "USDC", Network: ARBITRUM_NETWORK},
+		{Timestamp: end.Unix(), Balance: "103000000", Exponent: 6, Token: "USDC", Network: ARBITRUM_NETWORK},
+	} {
+		require.NoError(t, m.InsertBalance(ctx, b))
+	}
+	require.NoError(t, m.InsertOrderFilled(ctx, DbOrderFilled{
+		TxHash:         "A",
+		Filler:         solver,
+		SourceDomain:   NetworkToChainId[ARBITRUM_NETWORK],
+		AmountIn:       "100000000",
+		AmountOut:      "99000000",
+		SolverRevenue:  1_000_000,
+		Height:         1,
+		Nonce:          1,
+		BlockTimestamp: start.Add(time.Hour).Unix(),
+	}))
+
+	// no transfers tracked -- the revenue is reported next to the explained delta, not instead of it
+	report, err := m.GetReconciliationReport(ARBITRUM_NETWORK, start, end, decimal.NewFromFloat(0.01))
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	usdc := report[0]
+	assert.Equal(t, "1000000", usdc.AttributedRevenue)
+	assert.Equal(t, "0", usdc.ExplainedDelta)
+	assert.Equal(t, "3000000", usdc.Residual)
+	assert.Equal(t, "2000000", usdc.RevenueResidual)
+	assert.True(t, usdc.Flagged)
+
+	// the payout includes the revenue -- it is not added on top of the transfers
+	_, err = m.InsertTokenTransfer(ctx, DbTokenTransfer{TxHash: "0x1", Network: ARBITRUM_NETWORK, Token: "USDC", Direction: TRANSFER_DIRECTION_IN, Amount: 3000000, Timestamp: start.Add(2 * time.Hour).Unix()})
+	require.NoError(t, err)
+	report, err = m.GetReconciliationReport(ARBITRUM_NETWORK, start, end, decimal.NewFromFloat(0.01))
+	require.NoError(t, err)
+	usdc = report[0]
+	assert.Equal(t, "3000000", usdc.ExplainedDelta)
+	assert.Equal(t, "0", usdc.Residual)
+	assert.Equal(t, "1000000", usdc.AttributedRevenue)
+	assert.False(t, usdc.Flagged)
+}
+
+func TestGetDbGasSpentAboveInt64(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, nil)
+
+	// 4 ETH per tx, the sum doesn't fit into int64
+	for i, gasUsed := range []string{"4000000", "4000000", "4000000", "20000000"} {
+		require.NoError(t, m.InsertEthTxResponse(ctx, EthTxDetails{
+			Hash:        fmt.Sprintf("0x%d", i),
+			From:        testSolverAddress,
+			BlockNumber: "10",
+			TimeStamp:   "1738400000",
+			GasUsed:     gasUsed,
+			GasPrice:    "1000000000000",
+			IsError:     "0",
+		}, ARBITRUM_NETWORK, false))
+	}
+
+	gas, err := m.GetDbGasSpent(ARBITRUM_NETWORK, testSolverAddress, 0, 1738400000)
+	require.NoError(t, err)
+	assert.Equal(t, "32000000000000000000", gas.String())
+}
+
+func TestReconciliationReportSkipsOsmosis(t *testing.T) {
+	ctx := context.Background()
+	cfg := &Config{Chains: []ChainConfig{
+		{Name: ARBITRUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN},
+		{Name: OSMOSIS_NETWORK, Kind: CHAIN_KIND_COSMOS_LCD},
+	}}
+	require.NoError(t, cfg.resolveChains())
+	m := newTestMonitorWithDB(t, cfg)
+
+	start := time.Date(2025, 2, 1, 0, 0, 0, 0, time.UTC)
+	end := start.Add(24 * time.Hour)
+	for _, network := range []string{ARBITRUM_NETWORK, OSMOSIS_NETWORK} {
+		require.NoError(t, m.InsertBalance(ctx, DbBalance{Timestamp: start.Unix(), Balance: "100000000", Exponent: 6, Token: "USDC", Network: network}))
+		require.NoError(t, m.InsertBalance(ctx, DbBalance{Timestamp: end.Unix(), Balance: "90000000", Exponent: 6, Token: "USDC", Network: network}))
+	}
+
+	report, err := m.GetReconciliationReport("", start, end, decimal.NewFromFloat(0.01))
+	require.NoError(t, err)
+	require.Len(t, report, 1)
+	assert.Equal(t, ARBITRUM_NETWORK, report[0].Network)
+
+	report, err = m.GetReconciliationReport(OSMOSIS_NETWORK, start, end, decimal.NewFromFloat(0.01))
+	require.NoError(t, err)
+	assert.Empty(t, report)
+}
@@ -0,0 +1,398 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// global jobs that are not tied to a configured chain
+const (
+	PRICES_JOB = "prices"
+)
+
+// default schedule of the coingecko price job
+const DEFAULT_PRICES_SCHEDULE = "@hourly"
+
+// SchedulesConfig sets the schedules of the jobs that are not tied to a chain.
+// Chain jobs are configured with balances_schedule and tx_history_schedule in the chain blocks.
+// Example:
+//
+//	[schedules]
+//	orders = "@every 20s"
+//	prices = "0 * * * *"
+type SchedulesConfig struct {
+	// defaults to the -interval flag
+	Orders string `json:"orders,omitempty" yaml:"orders,omitempty" toml:"orders,omitempty"`
+	// defaults to @hourly
+	Prices string `json:"prices,omitempty" yaml:"prices,omitempty" toml:"prices,omitempty"`
+	// tx_data gap detection and backfill -- defaults to @daily
+	Gaps string `json:"gaps,omitempty" yaml:"gaps,omitempty" toml:"gaps,omitempty"`
+	// settlement, timeout and refund messages of the contract -- defaults to @every 5m
+	OrderActions string `json:"order_actions,omitempty" yaml:"order_actions,omitempty" toml:"order_actions,omitempty"`
+}
+
+// Schedule returns the next activation time after t.
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+// ParseSchedule parses "@every <duration>" (e.g. "@every 30s"), the @hourly, @daily, @weekly and @monthly
+// descriptors and standard 5 field cron expressions (minute hour day-of-month month day-of-week).
+// Cron fields support *, lists, ranges and steps (e.g. "*/10 8-18 * * 1-5"). Cron expressions are evaluated in UTC.
+func ParseSchedule(spec string) (Schedule, error) {
+	spec = strings.TrimSpace(spec)
+	if strings.HasPrefix(spec, "@every ") {
+		d, err := time.ParseDuration(strings.TrimSpace(strings.TrimPrefix(spec, "@every ")))
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule %q: %w", spec, err)
+		}
+		if d <= 0 {
+			return nil, fmt.Errorf("invalid schedule %q: duration must be positive", spec)
+		}
+		return everySchedule{d}, nil
+	}
+
+	switch spec {
+	case "@hourly":
+		spec = "0 * * * *"
+	case "@daily", "@midnight":
+		spec = "0 0 * * *"
+	case "@weekly":
+		spec = "0 0 * * 0"
+	case "@monthly":
+		spec = "0 0 1 * *"
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("invalid schedule %q: expected 5 cron fields", spec)
+	}
+
+	s := cronSchedule{}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("invalid minute in schedule %q: %w", spec, err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("invalid hour in schedule %q: %w", spec, err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("invalid day of month in schedule %q: %w", spec, err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("invalid month in schedule %q: %w", spec, err)
+	}
+	// 7 is an alias for sunday
+	if s.dow, err = parseCronField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("invalid day of week in schedule %q: %w", spec, err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domStar = fields[2] == "*"
+	s.dowStar = fields[4] == "*"
+	return s, nil
+}
+
+type everySchedule struct {
+	d time.Duration
+}
+
+func (s everySchedule) Next(t time.Time) time.Time {
+	return t.Add(s.d)
+}
+
+// cron fields are stored as bitsets
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	domStar, dowStar              bool
+}
+
+func (s cronSchedule) Next(t time.Time) time.Time {
+	t = t.UTC().Truncate(time.Minute).Add(time.Minute)
+	// every valid expression matches within 4 years (Feb 29)
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, time.UTC)
+			continue
+		}
+		if s.hour&(1<<uint(t.Hour())) == 0 {
+			t = t.Truncate(time.Hour).Add(time.Hour)
+			continue
+		}
+		if s.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// if both day fields are restricted either of them has to match (same as cron)
+func (s cronSchedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom&(1<<uint(t.Day())) != 0
+	dowMatch := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domStar || s.dowStar {
+		return domMatch && dowMatch
+	}
+	return domMatch || dowMatch
+}
+
+func parseCronField(field string, lo, hi int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if idx := strings.Index(part, "/"); idx >= 0 {
+			var err error
+			step, err = strconv.Atoi(part[idx+1:])
+			if err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			part = part[:idx]
+		}
+
+		start, end := lo, hi
+		switch {
+		case part == "*":
+		case strings.Contains(part, "-"):
+			bounds := strings.SplitN(part, "-", 2)
+			var err1, err2 error
+			start, err1 = strconv.Atoi(bounds[0])
+			end, err2 = strconv.Atoi(bounds[1])
+			if err1 != nil || err2 != nil {
+				return 0, fmt.Errorf("invalid range %q", part)
+			}
+		default:
+			v, err := strconv.Atoi(part)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value %q", part)
+			}
+			start = v
+			// "5/10" means starting at 5 every 10
+			if step == 1 {
+				end = v
+			}
+		}
+		if start < lo || end > hi || start > end {
+			return 0, fmt.Errorf("%q out of range %d-%d", part, lo, hi)
+		}
+		for v := start; v <= end; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+// Job is a named task run by the scheduler. Runs of the same job never overlap.
+type Job struct {
+	Name     string
+	Schedule Schedule
+	Run      func(ctx context.Context)
+	// configured schedule -- empty uses the default schedule
+	spec string
+}
+
+func balancesJobName(network string) string {
+	return network + "_balances"
+}
+
+func txHistoryJobName(network string) string {
+	return network + "_tx_history"
+}
+
+// pollJobs returns the orders job and the balances and tx history jobs of every configured chain
+func (m *Monitor) pollJobs(saveRawResponses bool) []Job {
+	jobs := []Job{{
+		Name: ORDERS_WORKER,
+		spec: m.cfg.Schedules.Orders,
+		Run: func(ctx context.Context) {
+			m.supervisor.Run(ctx, ORDERS_WORKER, func(ctx context.Context) error {
+				return m.RunOrders(ctx, saveRawResponses)
+			})
+		},
+	}}
+
+	for _, chain := range m.chains {
+		c := chain
+		cfg, _ := m.cfg.ChainByName(c.Network())
+		balancesJob := balancesJobName(c.Network())
+		txHistoryJob := txHistoryJobName(c.Network())
+		// the jobs of a network share its API key and rate limit so they never run at the same time
+		lock := m.chainLock(c.Network())
+		jobs = append(jobs,
+			Job{
+				Name: balancesJob,
+				spec: cfg.BalancesSchedule,
+				Run: func(ctx context.Context) {
+					lock.Lock()
+					defer lock.Unlock()
+					// a degraded network is retried on the next run
+					if err := m.supervisor.Run(ctx, balancesJob, c.RunBalances); err != nil && ctx.Err() == nil {
+						m.logger.Error().Err(err).Str("network", c.Network()).Msg("failed to run balances")
+					}
+				},
+			},
+			Job{
+				Name: txHistoryJob,
+				spec: cfg.TxHistorySchedule,
+				Run: func(ctx context.Context) {
+					lock.Lock()
+					defer lock.Unlock()
+					err := m.supervisor.Run(ctx, txHistoryJob, func(ctx context.Context) error {
+						return c.RunTxHistory(ctx, saveRawResponses)
+					})
+					if err != nil && ctx.Err() == nil {
+						m.logger.Error().Err(err).Str("network", c.Network()).Msg("failed to run tx history")
+					}
+				},
+			},
+		)
+	}
+	return jobs
+}
+
+// chainLock returns the lock shared by the jobs of the network
+func (m *Monitor) chainLock(network string) *sync.Mutex {
+	m.chainLocksMu.Lock()
+	defer m.chainLocksMu.Unlock()
+	if m.chainLocks == nil {
+		m.chainLocks = map[string]*sync.Mutex{}
+	}
+	lock, ok := m.chainLocks[network]
+	if !ok {
+		lock = &sync.Mutex{}
+		m.chainLocks[network] = lock
+	}
+	return lock
+}
+
+func (m *Monitor) pricesJob() Job {
+	spec := m.cfg.Schedules.Prices
+	if spec == "" {
+		spec = DEFAULT_PRICES_SCHEDULE
+	}
+	return Job{
+		Name: PRICES_JOB,
+		spec: spec,
+		Run: func(ctx context.Context) {
+			m.supervisor.Run(ctx, PRICES_JOB, m.GetCoingeckoPrices)
+		},
+	}
+}
+
+func (m *Monitor) gapsJob(saveRawResponses bool) Job {
+	spec := m.cfg.Schedules.Gaps
+	if spec == "" {
+		spec = DEFAULT_GAPS_SCHEDULE
+	}
+	return Job{
+		Name: GAPS_JOB,
+		spec: spec,
+		Run: func(ctx context.Context) {
+			m.supervisor.Run(ctx, GAPS_JOB, func(ctx context.Context) error {
+				return m.RunGapDetection(ctx, saveRawResponses)
+			})
+		},
+	}
+}
+
+func (m *Monitor) orderActionsJob() Job {
+	spec := m.cfg.Schedules.OrderActions
+	if spec == "" {
+		spec = DEFAULT_ORDER_ACTIONS_SCHEDULE
+	}
+	return Job{
+		Name: ORDER_ACTIONS_JOB,
+		spec: spec,
+		Run: func(ctx context.Context) {
+			m.supervisor.Run(ctx, ORDER_ACTIONS_JOB, m.RunOrderActions)
+		},
+	}
+}
+
+// Jobs returns the scheduled jobs. Polling jobs without a configured schedule use defaultSchedule (the -interval flag).
+// With pricesOnly set only the coingecko price job is returned (server only mode).
+func (m *Monitor) Jobs(defaultSchedule string, saveRawResponses, pricesOnly bool) ([]Job, error) {
+	jobs := []Job{}
+	if !pricesOnly {
+		for _, j := range m.pollJobs(saveRawResponses) {
+			// orders are ingested by RunOrdersSubscription
+			if j.Name == ORDERS_WORKER && m.ordersSubscriptionEnabled() {
+				continue
+			}
+			jobs = append(jobs, j)
+		}
+		jobs = append(jobs, m.gapsJob(saveRawResponses), m.orderActionsJob())
+	}
+	jobs = append(jobs, m.pricesJob())
+
+	for i := range jobs {
+		spec := jobs[i].spec
+		if spec == "" {
+			spec = defaultSchedule
+		}
+		schedule, err := ParseSchedule(spec)
+		if err != nil {
+			return nil, fmt.Errorf("job %s: %w", jobs[i].Name, err)
+		}
+		jobs[i].Schedule = schedule
+		m.supervisor.Register(jobs[i].Name)
+	}
+	return jobs, nil
+}
+
+// RunScheduler runs every job on its own schedule until the context is cancelled.
+// A run is skipped if the previous run of the same job is still in progress.
+func (m *Monitor) RunScheduler(ctx context.Context, wg *sync.WaitGroup, jobs []Job) {
+	for _, job := range jobs {
+		wg.Add(1)
+		go func(j Job) {
+			defer wg.Done()
+			for {
+				next := j.Schedule.Next(time.Now())
+				if next.IsZero() {
+					m.logger.Error().Str("job", j.Name).Msg("schedule has no next activation -- stopping job")
+					return
+				}
+				m.logger.Debug().Str("job", j.Name).Time("next_run", next).Msg("job scheduled")
+				if err := sleepCtx(ctx, time.Until(next)); err != nil {
+					return
+				}
+				m.runExclusive(wg, j.Name, func() { j.Run(ctx) })
+			}
+		}(job)
+	}
+}
+
+// validateSchedules checks the configured schedules when the config is loaded
+func (cfg *Config) validateSchedules() error {
+	specs := map[string]string{
+		ORDERS_WORKER:     cfg.Schedules.Orders,
+		PRICES_JOB:        cfg.Schedules.Prices,
+		GAPS_JOB:          cfg.Schedules.Gaps,
+		ORDER_ACTIONS_JOB: cfg.Schedules.OrderActions,
+	}
+	for _, c := range cfg.Chains {
+		specs[balancesJobName(c.Name)] = c.BalancesSchedule
+		specs[txHistoryJobName(c.Name)] = c.TxHistorySchedule
+	}
+	for job, spec := range specs {
+		if spec == "" {
+			continue
+		}
+		if _, err := ParseSchedule(spec); err != nil {
+			return fmt.Errorf("job %s: %w", job, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package monitor
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSchedule(t *testing.T) {
+	from := time.Date(2025, 3, 19, 16, 47, 30, 0, time.UTC)
+
+	tests := []struct {
+		spec string
+		next time.Time
+	}{
+		{"@every 30s", from.Add(30 * time.Second)},
+		{"@hourly", time.Date(2025, 3, 19, 17, 0, 0, 0, time.UTC)},
+		{"@daily", time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC)},
+		{"*/10 * * * *", time.Date(2025, 3, 19, 16, 50, 0, 0, time.UTC)},
+		{"5/15 * * * *", time.Date(2025, 3, 19, 16, 50, 0, 0, time.UTC)},
+		{"0,30 8-16 * * *", time.Date(2025, 3, 20, 8, 0, 0, 0, time.UTC)},
+		// 2025-03-19 is a wednesday
+		{"0 9 * * 1-5", time.Date(2025, 3, 20, 9, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 3, 23, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * *", time.Date(2025, 4, 1, 0, 0, 0, 0, time.UTC)},
+		// day of month or day of week when both are restricted
+		{"0 0 1 * 5", time.Date(2025, 3, 21, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			s, err := ParseSchedule(tt.spec)
+			require.NoError(t, err)
+			assert.Equal(t, tt.next, s.Next(from))
+		})
+	}
+
+	for _, spec := range []string{"", "@every", "@every -1m", "* * * *", "60 * * * *", "* 24 * * *", "0 0 0 * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		_, err := ParseSchedule(spec)
+		assert.Error(t, err, spec)
+	}
+
+	// never matches
+	s, err := ParseSchedule("0 0 31 2 *")
+	require.NoError(t, err)
+	assert.True(t, s.Next(from).IsZero())
+}
+
+func TestJobs(t *testing.T) {
+	cfg := &Config{Chains: []ChainConfig{{
+		ChainEntry: ChainEntry{Address: "0xSolver", BalancesSchedule: "*/10 * * * *"},
+		Name:       ETHEREUM_NETWORK,
+		Kind:       CHAIN_KIND_ETHERSCAN,
+	}}}
+	cfg.Schedules.Orders = "@every 20s"
+	require.NoError(t, cfg.resolveChains())
+	require.NoError(t, cfg.validateSchedules())
+	m := newTestMonitorWithDB(t, cfg)
+	m.supervisor = NewSupervisor(DefaultBackoffConfig, m.logger)
+	m.chains = m.buildChainAdapters()
+
+	jobs, err := m.Jobs("@every 1m", false, false)
+	require.NoError(t, err)
+	from := time.Date(2025, 3, 19, 16, 47, 30, 0, time.UTC)
+	next := map[string]time.Time{}
+	for _, j := range jobs {
+		next[j.Name] = j.Schedule.Next(from)
+	}
+	assert.Equal(t, map[string]time.Time{
+		ORDERS_WORKER:                      from.Add(20 * time.Second),
+		balancesJobName(ETHEREUM_NETWORK):  time.Date(2025, 3, 19, 16, 50, 0, 0, time.UTC),
+		txHistoryJobName(ETHEREUM_NETWORK): from.Add(time.Minute),
+		PRICES_JOB:                         time.Date(2025, 3, 19, 17, 0, 0, 0, time.UTC),
+		GAPS_JOB:                           time.Date(2025, 3, 20, 0, 0, 0, 0, time.UTC),
+		ORDER_ACTIONS_JOB:                  from.Add(5 * time.Minute),
+	}, next)
+
+	jobs, err = m.Jobs("@every 1m", false, true)
+	require.NoError(t, err)
+	require.Len(t, jobs, 1)
+	assert.Equal(t, PRICES_JOB, jobs[0].Name)
+
+	cfg.Chains[0].TxHistorySchedule = "every 5m"
+	assert.Error(t, cfg.validateSchedules())
+}
+
+// fakeChainAdapter tracks how many of its jobs run at the same time
+type fakeChainAdapter struct {
+	network      string
+	running      atomic.Int32
+	maxRunning   atomic.Int32
+	txHistoryErr error
+}
+
+func (a *fakeChainAdapter) Network() string {
+	return a.network
+}
+
+func (a *fakeChainAdapter) run() {
+	n := a.running.Add(1)
+	defer a.running.Add(-1)
+	for {
+		max := a.maxRunning.Load()
+		if n <= max || a.maxRunning.CompareAndSwap(max, n) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+}
+
+func (a *fakeChainAdapter) RunBalances(ctx context.Context) error {
+	a.run()
+	return nil
+}
+
+func (a *fakeChainAdapter) RunTxHistory(ctx context.Context, saveRawResponses bool) error {
+	a.run()
+	return a.txHistoryErr
+}
+
+func TestChainJobs(t *testing.T) {
+	cfg := &Config{Chains: []ChainConfig{{Name: ETHEREUM_NETWORK, Kind: CHAIN_KIND_ETHERSCAN}}}
+	require.NoError(t, cfg.resolveChains())
+	m := newTestMonitorWithDB(t, cfg)
+	m.supervisor = NewSupervisor(BackoffConfig{MaxRetries: 0}, m.logger)
+	adapter := &fakeChainAdapter{network: ETHEREUM_NETWORK, txHistoryErr: errors.New("rate limited")}
+	m.chains = []ChainAdapter{adapter}
+
+	jobs := map[string]Job{}
+	for _, j := range m.pollJobs(false) {
+		jobs[j.Name] = j
+	}
+
+	// the balances and tx history jobs of a network never overlap
+	ctx := context.Background()
+	done := make(chan struct{})
+	for _, name := range []string{balancesJobName(ETHEREUM_NETWORK), txHistoryJobName(ETHEREUM_NETWORK)} {
+		for i := 0; i < 3; i++ {
+			go func(j Job) {
+				j.Run(ctx)
+				done <- struct{}{}
+			}(jobs[name])
+		}
+	}
+	for i := 0; i < 6; i++ {
+		<-done
+	}
+	assert.Equal(t, int32(1), adapter.maxRunning.Load())
+
+	// a failing tx history marks the worker as degraded
+	states := map[string]WorkerStatus{}
+	for _, w := range m.supervisor.Status() {
+		states[w.Name] = w
+	}
+	assert.Equal(t, WORKER_STATE_HEALTHY, states[balancesJobName(ETHEREUM_NETWORK)].State)
+	txHistory := states[txHistoryJobName(ETHEREUM_NETWORK)]
+	assert.Equal(t, WORKER_STATE_DEGRADED, txHistory.State)
+	assert.Equal(t, "rate limited", txHistory.LastError)
+	assert.Equal(t, 3, txHistory.TotalFailures)
+}
@@ -2,6 +2,7 @@ package monitor
 
 import (
 	"context"
+	"errors"
 	"net/http"
 	"strconv"
 	"time"
@@ -29,8 +30,21 @@ func (s *Server) RunWithContext(ctx context.Context, addr string) error {
 	router.GET("/stats/orders_filled", s.getOrdersFilledStats)
 	router.GET("/stats/orders_filled/fill_stats", s.getFillStats)
 	router.GET("/stats/orders_filled/fills_in_range", s.getOrderDetailsByRange)
+	router.GET("/stats/orders_filled/timeseries", s.getFillTimeseries)
+	router.GET("/orders", s.getOrders)
 	router.GET("/stats/fees", s.getFeesStats)
 	router.GET("/balances/latest", s.getLatestBalances)
+	router.GET("/stats/usdc_flows", s.getUsdcFlows)
+	router.GET("/reports/reconciliation", s.getReconciliationReport)
+	router.GET("/reports/gaps", s.getOrderGaps)
+	router.GET("/reports/order_mismatches", s.getOrderMismatches)
+	router.GET("/reports/missed_orders", s.getMissedOrders)
+	router.GET("/stats/pnl/orders", s.getOrderPnl)
+	router.GET("/stats/pnl/networks", s.getNetworkPnl)
+	router.GET("/stats/settlements", s.getSettlementStats)
+	router.GET("/stats/exposure", s.getExposure)
+	router.GET("/stats/market", s.getMarketStats)
+	router.GET("/status", s.getStatus)
 	// TODO: needs pagination so I'm temporarily removing this
 	// router.GET("/balances/range", s.getBalancesInTimeRange)
 
@@ -204,6 +218,42 @@ func (s *Server) getOrdersFilledStats(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"orders_filled": resp})
 }
 
+// bucket is hour, day (default) or week; from and to are optional dates (YYYY-MM-DD); defaults to the last 30 days
+func (s *Server) getFillTimeseries(c *gin.Context) {
+	asInteger := c.Query("as_integer")
+	filler := c.Query("filler")
+	if filler == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filler address is required"})
+		return
+	}
+	bucket := c.Query("bucket")
+	if bucket == "" {
+		bucket = TIMESERIES_BUCKET_DAY
+	}
+
+	fromTime, toTime, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+	buckets, err := timeseriesBucketCount(bucket, fromTime, toTime)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid bucket, expected hour, day or week"})
+		return
+	}
+	if buckets > MAX_TIMESERIES_BUCKETS {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "too many buckets, use a larger bucket or a shorter range"})
+		return
+	}
+
+	series, err := s.monitor.GetFillTimeseries(filler, bucket, fromTime, toTime, asInteger != "")
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get fill timeseries")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"timeseries": series})
+}
+
 func (s *Server) getFeesStats(c *gin.Context) {
 	asInteger := c.Query("as_integer")
 
@@ -297,3 +347,359 @@ func (s *Server) getOrderDetailsByRange(c *gin.Context) {
 	}
 	c.JSON(http.StatusOK, gin.H{"orders": response})
 }
+
+// from and to are optional dates (YYYY-MM-DD); defaults to the last 30 days
+func (s *Server) getUsdcFlows(c *gin.Context) {
+	network := c.Query("network")
+	asInteger := c.Query("as_integer")
+
+	fromTime, toTime, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	flows, err := s.monitor.GetDbTokenFlows("USDC", network, fromTime, toTime)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get USDC flows")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get flows"})
+		return
+	}
+
+	if asInteger == "" {
+		for i := range flows {
+			for _, v := range []*string{&flows[i].Inflow, &flows[i].Outflow, &flows[i].NetFlow} {
+				asDecimal, err := decimal.NewFromString(*v)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "unexpected error"})
+					return
+				}
+				*v = asDecimal.Shift(-6).String()
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"flows": flows})
+}
+
+// parseDateRange parses optional from and to dates (YYYY-MM-DD, both inclusive).
+// Defaults to the last defaultDays days. Writes the error response if the dates are invalid.
+func parseDateRange(c *gin.Context, defaultDays int) (time.Time, time.Time, bool) {
+	toTime := time.Now().UTC()
+	fromTime := toTime.AddDate(0, 0, -defaultDays)
+	var err error
+	if from := c.Query("from"); from != "" {
+		fromTime, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date format, expected YYYY-MM-DD"})
+			return fromTime, toTime, false
+		}
+	}
+	if to := c.Query("to"); to != "" {
+		toTime, err = time.Parse("2006-01-02", to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to date format, expected YYYY-MM-DD"})
+			return fromTime, toTime, false
+		}
+		// include the whole "to" day
+		toTime = toTime.AddDate(0, 0, 1)
+	}
+	return fromTime, toTime, true
+}
+
+func (s *Server) getReconciliationReport(c *gin.Context) {
+	network := c.Query("network")
+	asInteger := c.Query("as_integer")
+
+	fromTime, toTime, ok := parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	threshold := decimal.NewFromFloat(DEFAULT_RECONCILIATION_THRESHOLD)
+	if t := c.Query("threshold"); t != "" {
+		parsed, err := decimal.NewFromString(t)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid threshold"})
+			return
+		}
+		threshold = parsed
+	}
+
+	report, err := s.monitor.GetReconciliationReport(network, fromTime, toTime, threshold)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get reconciliation report")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get report"})
+		return
+	}
+
+	if asInteger == "" {
+		for i := range report {
+			if err := report[i].Shift(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "unexpected error"})
+				return
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reconciliation": report})
+}
+
+// from and to are optional dates (YYYY-MM-DD); defaults to the last 7 days
+func (s *Server) getOrderPnl(c *gin.Context) {
+	filler := c.Query("filler")
+	network := c.Query("network")
+	if filler == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filler address is required"})
+		return
+	}
+	if _, ok := NetworkToChainId[network]; network != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid network"})
+		return
+	}
+
+	fromTime, toTime, ok := parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	orders, err := s.monitor.GetOrderPnl(filler, network, fromTime, toTime)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get order pnl")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get pnl"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// from and to are optional dates (YYYY-MM-DD); defaults to the last 30 days
+func (s *Server) getNetworkPnl(c *gin.Context) {
+	filler := c.Query("filler")
+	network := c.Query("network")
+	if filler == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filler address is required"})
+		return
+	}
+	if _, ok := NetworkToChainId[network]; network != "" && !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid network"})
+		return
+	}
+
+	fromTime, toTime, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	orders, err := s.monitor.GetOrderPnl(filler, network, fromTime, toTime)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get order pnl")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get pnl"})
+		return
+	}
+	networks, err := AggregateNetworkPnl(orders)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "unexpected error"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"networks": networks})
+}
+
+func (s *Server) getOrderGaps(c *gin.Context) {
+	gaps, err := s.monitor.GetDbOrderGaps()
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get order gaps")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get gaps"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"gaps": gaps})
+}
+
+func (s *Server) getOrderMismatches(c *gin.Context) {
+	orders, err := s.monitor.GetDbOrderMismatches()
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get order mismatches")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get order mismatches"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+const (
+	DEFAULT_ORDERS_LIMIT = 100
+	MAX_ORDERS_LIMIT     = 1000
+)
+
+// getOrders lists stored fill orders, newest first.
+// network is the source network name; destination_domain is the raw domain of the order (e.g. 875 for osmosis).
+func (s *Server) getOrders(c *gin.Context) {
+	filter := OrderFilter{
+		Filler:            c.Query("filler"),
+		Sender:            c.Query("sender"),
+		Recipient:         c.Query("recipient"),
+		DestinationDomain: c.Query("destination_domain"),
+		Limit:             DEFAULT_ORDERS_LIMIT,
+	}
+	if network := c.Query("network"); network != "" {
+		chainId, ok := NetworkToChainId[network]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid network"})
+			return
+		}
+		filter.SourceDomain = chainId
+	}
+	if filter.DestinationDomain != "" {
+		if _, err := strconv.ParseUint(filter.DestinationDomain, 10, 32); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid destination domain"})
+			return
+		}
+	}
+	if limit := c.Query("limit"); limit != "" {
+		asInt, err := strconv.Atoi(limit)
+		if err != nil || asInt <= 0 || asInt > MAX_ORDERS_LIMIT {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		filter.Limit = asInt
+	}
+	if offset := c.Query("offset"); offset != "" {
+		asInt, err := strconv.Atoi(offset)
+		if err != nil || asInt < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid offset"})
+			return
+		}
+		filter.Offset = asInt
+	}
+
+	orders, err := s.monitor.GetDbOrders(filter)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get orders")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get orders"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"orders": orders})
+}
+
+// unsettled amounts are in USDC unless as_integer is set
+func (s *Server) getSettlementStats(c *gin.Context) {
+	asInteger := c.Query("as_integer")
+	filler := c.Query("filler")
+	if filler == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filler address is required"})
+		return
+	}
+
+	stats, err := s.monitor.GetDbSettlementStats(filler)
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get settlement stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
+		return
+	}
+
+	if asInteger == "" {
+		for i := range stats {
+			amount, err := decimal.NewFromString(stats[i].UnsettledAmount)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "unexpected error"})
+				return
+			}
+			stats[i].UnsettledAmount = amount.Shift(-6).String()
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"settlements": stats})
+}
+
+// from is an optional date (YYYY-MM-DD); only fills since then are outstanding, defaults to all unsettled fills
+func (s *Server) getExposure(c *gin.Context) {
+	asInteger := c.Query("as_integer")
+	filler := c.Query("filler")
+	if filler == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "filler address is required"})
+		return
+	}
+
+	since := time.Unix(0, 0)
+	if from := c.Query("from"); from != "" {
+		var err error
+		since, err = time.Parse("2006-01-02", from)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from date format, expected YYYY-MM-DD"})
+			return
+		}
+	}
+
+	exposure, err := s.monitor.GetExposure(filler, since, asInteger != "")
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get exposure")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get exposure"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"exposure": exposure})
+}
+
+// from and to are optional dates (YYYY-MM-DD); defaults to the last 30 days
+func (s *Server) getMarketStats(c *gin.Context) {
+	asInteger := c.Query("as_integer")
+	sourceDomain := ""
+	if network := c.Query("network"); network != "" {
+		chainId, ok := NetworkToChainId[network]
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid network"})
+			return
+		}
+		sourceDomain = chainId
+	}
+
+	fromTime, toTime, ok := parseDateRange(c, 30)
+	if !ok {
+		return
+	}
+
+	stats, err := s.monitor.GetMarketStats(sourceDomain, fromTime, toTime, asInteger != "")
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get market stats")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get stats"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"market": stats})
+}
+
+// from and to are optional dates (YYYY-MM-DD); defaults to the last 7 days
+func (s *Server) getMissedOrders(c *gin.Context) {
+	asInteger := c.Query("as_integer")
+	limit := DEFAULT_MISSED_ORDERS_LIMIT
+	if l := c.Query("limit"); l != "" {
+		asInt, err := strconv.Atoi(l)
+		if err != nil || asInt < 0 || asInt > MAX_ORDERS_LIMIT {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid limit"})
+			return
+		}
+		limit = asInt
+	}
+
+	fromTime, toTime, ok := parseDateRange(c, 7)
+	if !ok {
+		return
+	}
+
+	report, err := s.monitor.GetMissedOrdersReport(fromTime, toTime, limit, asInteger != "")
+	if errors.Is(err, MissingSolverAddressErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		s.monitor.logger.Error().Err(err).Msg("failed to get missed orders")
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to get missed orders"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"missed_orders": report})
+}
+
+// getStatus returns the state of the supervised workers.
+// Responds with 200 even if workers are degraded -- the API itself is up.
+func (s *Server) getStatus(c *gin.Context) {
+	supervisor := s.monitor.Supervisor()
+	c.JSON(http.StatusOK, gin.H{
+		"degraded":          supervisor.Degraded(),
+		"workers":           supervisor.Status(),
+		"osmosis_endpoints": s.monitor.OsmosisEndpoints(),
+	})
+}
@@ -0,0 +1,294 @@
+package monitor
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	abcitypes "github.com/cometbft/cometbft/abci/types"
+	codectypes "github.com/cosmos/cosmos-sdk/codec/types"
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// the node pings every ~30s -- a connection without any message for this long is considered dead
+	ORDERS_WS_READ_TIMEOUT = 2 * time.Minute
+	// delay before reconnecting after an established connection was closed
+	ORDERS_WS_RECONNECT_DELAY = 5 * time.Second
+	// delay before subscribing again after the worker ended up degraded
+	ORDERS_WS_DEGRADED_DELAY = 5 * time.Minute
+)
+
+type rpcRequest struct {
+	JsonRpc string            `json:"jsonrpc"`
+	Method  string            `json:"method"`
+	Id      int               `json:"id"`
+	Params  map[string]string `json:"params"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Data    string `json:"data"`
+}
+
+type rpcResponse struct {
+	Id     json.RawMessage `json:"id"`
+	Result json.RawMessage `json:"result"`
+	Error  *rpcError       `json:"error"`
+}
+
+// txEvent is the result of a cometbft tx event subscription
+type txEvent struct {
+	Query string `json:"query"`
+	Data  struct {
+		Type  string `json:"type"`
+		Value struct {
+			TxResult struct {
+				Height string `json:"height"`
+				Index  uint32 `json:"index"`
+				Tx     []byte `json:"tx"`
+				Result struct {
+					Code      uint32            `json:"code"`
+					Codespace string            `json:"codespace"`
+					Log       string            `json:"log"`
+					GasWanted string            `json:"gas_wanted"`
+					GasUsed   string            `json:"gas_used"`
+					Events    []abcitypes.Event `json:"events"`
+				} `json:"result"`
+			} `json:"TxResult"`
+		} `json:"value"`
+	} `json:"data"`
+	Events map[string][]string `json:"events"`
+}
+
+// websocketUrl converts an rpc url (https://rpc.osmosis.zone) to its websocket endpoint (wss://rpc.osmosis.zone/websocket)
+func websocketUrl(rpcUrl string) (string, error) {
+	u, err := url.Parse(rpcUrl)
+	if err != nil {
+		return "", err
+	}
+	switch u.Scheme {
+	case "https":
+		u.Scheme = "wss"
+	case "http":
+		u.Scheme = "ws"
+	case "wss", "ws":
+	default:
+		return "", fmt.Errorf("unsupported websocket url scheme %q", u.Scheme)
+	}
+	if u.Path == "" || u.Path == "/" {
+		u.Path = "/websocket"
+	}
+	return u.String(), nil
+}
+
+// toTxResponse converts the event to the tx response returned by the LCD so it can be decoded by DecodeTxResponse
+func (e *txEvent) toTxResponse() (*sdktypes.TxResponse, error) {
+	txResult := e.Data.Value.TxResult
+	height, err := strconv.ParseInt(txResult.Height, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid tx height %q: %w", txResult.Height, err)
+	}
+	gasWanted, _ := strconv.ParseInt(txResult.Result.GasWanted, 10, 64)
+	gasUsed, _ := strconv.ParseInt(txResult.Result.GasUsed, 10, 64)
+
+	hash := fmt.Sprintf("%X", sha256.Sum256(txResult.Tx))
+	if hashes := e.Events["tx.hash"]; len(hashes) > 0 {
+		hash = hashes[0]
+	}
+
+	return &sdktypes.TxResponse{
+		Height:    height,
+		TxHash:    hash,
+		Code:      txResult.Result.Code,
+		Codespace: txResult.Result.Codespace,
+		RawLog:    txResult.Result.Log,
+		GasWanted: gasWanted,
+		GasUsed:   gasUsed,
+		Events:    txResult.Result.Events,
+		// raw tx bytes are wire compatible with the Tx message
+		Tx: &codectypes.Any{TypeUrl: "/cosmos.tx.v1beta1.Tx", Value: txResult.Tx},
+	}, nil
+}
+
+// RunOrdersSubscription ingests fill orders from the osmosis rpc websocket instead of polling the LCD.
+// Every (re)connect subscribes first and then polls the LCD to catch up on orders missed while disconnected.
+func (m *Monitor) RunOrdersSubscription(ctx context.Context, wg *sync.WaitGroup, saveRawResponses bool) {
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for {
+			err := m.supervisor.Run(ctx, ORDERS_WORKER, func(ctx context.Context) error {
+				return m.subscribeOrders(ctx, saveRawResponses)
+			})
+			if ctx.Err() != nil {
+				return
+			}
+			delay := ORDERS_WS_RECONNECT_DELAY
+			if err != nil {
+				delay = ORDERS_WS_DEGRADED_DELAY
+			}
+			if err := sleepCtx(ctx, delay); err != nil {
+				return
+			}
+		}
+	}()
+}
+
+// subscribeOrders returns an error if the subscription or the catch-up fails.
+// A connection that was closed after it was established returns nil and is reconnected.
+func (m *Monitor) subscribeOrders(ctx context.Context, saveRawResponses bool) error {
+	wsUrl, err := websocketUrl(m.cfg.Osmosis.WebsocketUrl)
+	if err != nil {
+		return err
+	}
+
+	conn, _, err := websocket.DefaultDialer.DialContext(ctx, wsUrl, nil)
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", wsUrl, err)
+	}
+	defer conn.Close()
+
+	// unblocks the reads on shutdown
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.Close()
+		case <-done:
+		}
+	}()
+
+	query := ordersQuery(m.cfg.Osmosis.ContractAddress)
+	if err := conn.WriteJSON(rpcRequest{JsonRpc: "2.0", Method: "subscribe", Id: 1, Params: map[string]string{"query": query}}); err != nil {
+		return fmt.Errorf("failed to subscribe: %w", err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(ORDERS_WS_READ_TIMEOUT))
+	conn.SetPingHandler(func(data string) error {
+		conn.SetReadDeadline(time.Now().Add(ORDERS_WS_READ_TIMEOUT))
+		return conn.WriteControl(websocket.PongMessage, []byte(data), time.Now().Add(10*time.Second))
+	})
+
+	var resp rpcResponse
+	if err := conn.ReadJSON(&resp); err != nil {
+		return fmt.Errorf("failed to read subscribe response: %w", err)
+	}
+	if resp.Error != nil {
+		return fmt.Errorf("failed to subscribe: %s %s", resp.Error.Message, resp.Error.Data)
+	}
+	m.logger.Info().Str("url", wsUrl).Msg("subscribed to osmosis fill orders")
+
+	// events that arrive during the catch-up are buffered by the connection
+	if err := m.catchUpOrders(ctx, saveRawResponses); err != nil {
+		return fmt.Errorf("failed to catch up on orders: %w", err)
+	}
+
+	for {
+		var resp rpcResponse
+		if err := conn.ReadJSON(&resp); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			m.logger.Warn().Err(err).Str("url", wsUrl).Msg("osmosis websocket closed -- reconnecting")
+			return nil
+		}
+		conn.SetReadDeadline(time.Now().Add(ORDERS_WS_READ_TIMEOUT))
+
+		if resp.Error != nil {
+			// e.g. the node cancelled the subscription because the client was too slow
+			m.logger.Warn().Str("error", resp.Error.Message).Str("data", resp.Error.Data).Msg("osmosis websocket error -- reconnecting")
+			return nil
+		}
+
+		var event txEvent
+		if err := json.Unmarshal(resp.Result, &event); err != nil || event.Data.Value.TxResult.Tx == nil {
+			continue
+		}
+		txResponse, err := event.toTxResponse()
+		if err != nil {
+			m.logger.Error().Err(err).Msg("failed to convert osmosis tx event")
+			continue
+		}
+		if err := m.storeOrderTxResponse(ctx, txResponse, saveRawResponses); err != nil {
+			return fmt.Errorf("failed to store order %s: %w", txResponse.TxHash, err)
+		}
+	}
+}
+
+// catchUpOrders stores the orders missed while disconnected. The websocket stores the txs of a height one at a time,
+// so the poll starts below the stored max height to pick up the rest of it -- stored txs are skipped.
+func (m *Monitor) catchUpOrders(ctx context.Context, saveRawResponses bool) error {
+	height := max(m.GetLatestHeight()-1, 0)
+	txResponses, err := m.getTxResponsesSince(ctx, ordersQuery(m.cfg.Osmosis.ContractAddress), height)
+	if err != nil {
+		return err
+	}
+	// oldest first, an interrupted catch-up continues below the stored max height
+	sort.SliceStable(txResponses, func(i, j int) bool { return txResponses[i].Height < txResponses[j].Height })
+	for _, txResponse := range txResponses {
+		if err := m.storeOrderTxResponse(ctx, txResponse, saveRawResponses); err != nil {
+			return fmt.Errorf("failed to store order %s: %w", txResponse.TxHash, err)
+		}
+	}
+	m.logger.Info().Int("txs", len(txResponses)).Int("from_height", height).Msg("caught up on osmosis fill orders")
+	return nil
+}
+
+// storeOrderTxResponse decodes and inserts the orders of a tx unless the tx is already stored
+func (m *Monitor) storeOrderTxResponse(ctx context.Context, txResponse *sdktypes.TxResponse, saveRawResponses bool) error {
+	stored, err := m.GetDbOrderTxHashes(txResponse.Height, txResponse.Height+1)
+	if err != nil {
+		return err
+	}
+	if stored[txResponse.TxHash] {
+		return nil
+	}
+
+	// websocket events don't carry the block time, orders without it are left to the block times backfill
+	if txResponse.Timestamp == "" {
+		b, err := m.getOsmosisBlockTime(ctx, txResponse.Height)
+		if err != nil {
+			m.logger.Warn().Err(err).Int64("height", txResponse.Height).Msg("failed to get osmosis block time")
+		} else {
+			txResponse.Timestamp = time.Unix(b.Timestamp, 0).UTC().Format(time.RFC3339)
+		}
+	}
+
+	orders, responses := m.decodeOrderTxResponses([]*sdktypes.TxResponse{txResponse})
+	if saveRawResponses {
+		for _, r := range responses {
+			if err := m.InsertRawTxResponse(ctx, *r); err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", r.TxHash).Msg("failed to insert raw tx response from osmosis")
+			}
+		}
+	}
+	// the tx counts as stored once any of its orders is stored -- all of them are inserted together
+	if err := m.InsertOrdersFilled(ctx, orders); err != nil {
+		return err
+	}
+	for _, o := range orders {
+		logEvent := m.logger.Info().Str("tx_hash", o.TxHash).Int64("height", o.Height)
+		if solver := m.cfg.Osmosis.SolverAddress; solver != "" && o.Filler == solver {
+			logEvent.Int64("revenue", o.SolverRevenue).Msg("monitored solver filled order on osmosis")
+			continue
+		}
+		logEvent.Msg("stored fill order from osmosis websocket")
+	}
+	return nil
+}
+
+// ordersSubscriptionEnabled is true if orders are ingested from the websocket and polled only to catch up
+func (m *Monitor) ordersSubscriptionEnabled() bool {
+	return strings.TrimSpace(m.cfg.Osmosis.WebsocketUrl) != ""
+}
@@ -0,0 +1,157 @@
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	sdktypes "github.com/cosmos/cosmos-sdk/types"
+	"github.com/cosmos/cosmos-sdk/types/tx"
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebsocketUrl(t *testing.T) {
+	for in, expected := range map[string]string{
+		"https://rpc.osmosis.zone":         "wss://rpc.osmosis.zone/websocket",
+		"http://localhost:26657/":          "ws://localhost:26657/websocket",
+		"wss://rpc.osmosis.zone/ws/custom": "wss://rpc.osmosis.zone/ws/custom",
+	} {
+		out, err := websocketUrl(in)
+		require.NoError(t, err)
+		assert.Equal(t, expected, out)
+	}
+	_, err := websocketUrl("ftp://rpc.osmosis.zone")
+	assert.Error(t, err)
+}
+
+func TestSubscribeOrders(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+
+	// the catch-up poll returns the oldest order, block times of the websocket orders are fetched from the lcd
+	blockTime := time.Date(2025, 3, 19, 12, 0, 0, 0, time.UTC)
+	node := fakeOrdersNode(m, func() []*sdktypes.TxResponse { return fixture.TxResponses[6:] })
+	lcd := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.Contains(r.URL.Path, BLOCK_QUERY) {
+			var resp ShortBlockResp
+			resp.Block.Header.Time = blockTime
+			json.NewEncoder(w).Encode(resp)
+			return
+		}
+		node(w, r)
+	}))
+	defer lcd.Close()
+	m.osmosis = NewLcdClient([]string{lcd.URL}, m.logger)
+
+	upgrader := websocket.Upgrader{}
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var req rpcRequest
+		require.NoError(t, conn.ReadJSON(&req))
+		assert.Equal(t, "subscribe", req.Method)
+		assert.Equal(t, ordersQuery(m.cfg.Osmosis.ContractAddress), req.Params["query"])
+		conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.Id, "result": map[string]any{}})
+
+		// the second event is sent twice
+		for _, i := range []int{2, 1, 1, 0} {
+			r := fixture.TxResponses[i]
+			event := txEvent{Query: req.Params["query"], Events: map[string][]string{"tx.hash": {r.TxHash}}}
+			event.Data.Type = "tendermint/event/Tx"
+			event.Data.Value.TxResult.Height = strconv.FormatInt(r.Height, 10)
+			event.Data.Value.TxResult.Tx = r.Tx.Value
+			event.Data.Value.TxResult.Result.Code = r.Code
+			conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.Id, "result": event})
+		}
+	}))
+	defer rpc.Close()
+	m.cfg.Osmosis.WebsocketUrl = strings.Replace(rpc.URL, "http://", "ws://", 1) + "/websocket"
+
+	// the connection closed by the node is reconnected by the caller
+	require.NoError(t, m.subscribeOrders(ctx, true))
+
+	stored, err := m.GetDbOrderTxHashes(0, fixture.TxResponses[0].Height+1)
+	require.NoError(t, err)
+	assert.Len(t, stored, 4)
+	for _, i := range []int{0, 1, 2, 6} {
+		assert.True(t, stored[fixture.TxResponses[i].TxHash], "order %d not stored", i)
+	}
+
+	var rawCount int
+	require.NoError(t, m.db.QueryRow("SELECT COUNT(*) FROM raw_tx_responses").Scan(&rawCount))
+	assert.Equal(t, 4, rawCount)
+
+	for _, i := range []int{0, 1, 2} {
+		var timestamp int64
+		require.NoError(t, m.db.QueryRow("SELECT timestamp FROM osmo_block_times WHERE height = ?", fixture.TxResponses[i].Height).Scan(&timestamp))
+		assert.Equal(t, blockTime.Unix(), timestamp)
+	}
+}
+
+func TestSubscribeOrdersCatchesUpOnPartialHeight(t *testing.T) {
+	osmosisOrdersPageSleep = 0
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, &Config{})
+	var fixture tx.GetTxsEventResponse
+	require.NoError(t, m.Codec.UnmarshalJSON(loadTestFixture(t, "decoding_test_fixture.json"), &fixture))
+
+	// two fills at one height
+	first := *fixture.TxResponses[0]
+	second := *fixture.TxResponses[1]
+	second.Height = first.Height
+	// the node has no fills yet when the first connection catches up
+	served := []*sdktypes.TxResponse{}
+	lcd := httptest.NewServer(fakeOrdersNode(m, func() []*sdktypes.TxResponse { return served }))
+	defer lcd.Close()
+	m.osmosis = NewLcdClient([]string{lcd.URL}, m.logger)
+
+	// the connection drops after the event of the first fill
+	connections := 0
+	upgrader := websocket.Upgrader{}
+	rpc := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		var req rpcRequest
+		require.NoError(t, conn.ReadJSON(&req))
+		conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.Id, "result": map[string]any{}})
+		connections++
+		if connections > 1 {
+			return
+		}
+		event := txEvent{Query: req.Params["query"], Events: map[string][]string{"tx.hash": {first.TxHash}}}
+		event.Data.Type = "tendermint/event/Tx"
+		event.Data.Value.TxResult.Height = strconv.FormatInt(first.Height, 10)
+		event.Data.Value.TxResult.Tx = first.Tx.Value
+		event.Data.Value.TxResult.Result.Code = first.Code
+		conn.WriteJSON(map[string]any{"jsonrpc": "2.0", "id": req.Id, "result": event})
+	}))
+	defer rpc.Close()
+	m.cfg.Osmosis.WebsocketUrl = strings.Replace(rpc.URL, "http://", "ws://", 1) + "/websocket"
+
+	require.NoError(t, m.subscribeOrders(ctx, false))
+	stored, err := m.GetDbOrderTxHashes(first.Height, first.Height+1)
+	require.NoError(t, err)
+	require.Len(t, stored, 1)
+
+	// the catch-up after the reconnect stores the rest of the height
+	served = []*sdktypes.TxResponse{&first, &second}
+	require.NoError(t, m.subscribeOrders(ctx, false))
+	stored, err = m.GetDbOrderTxHashes(first.Height, first.Height+1)
+	require.NoError(t, err)
+	assert.Len(t, stored, 2)
+	assert.True(t, stored[second.TxHash])
+}
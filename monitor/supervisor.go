@@ -0,0 +1,201 @@
+package monitor
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog"
+)
+
+const (
+	WORKER_STATE_IDLE     = "idle"
+	WORKER_STATE_RUNNING  = "running"
+	WORKER_STATE_HEALTHY  = "healthy"
+	WORKER_STATE_RETRYING = "retrying"
+	// retries were exhausted -- the worker is retried again on the next tick
+	WORKER_STATE_DEGRADED = "degraded"
+)
+
+type BackoffConfig struct {
+	Initial    time.Duration
+	Max        time.Duration
+	MaxRetries int
+	// fraction of the delay that is randomized (0.2 -> delay +-20%)
+	Jitter float64
+}
+
+var DefaultBackoffConfig = BackoffConfig{
+	Initial:    5 * time.Second,
+	Max:        5 * time.Minute,
+	MaxRetries: 5,
+	Jitter:     0.2,
+}
+
+type WorkerStatus struct {
+	Name                string    `json:"name"`
+	State               string    `json:"state"`
+	ConsecutiveFailures int       `json:"consecutive_failures"`
+	TotalFailures       int       `json:"total_failures"`
+	LastError           string    `json:"last_error,omitempty"`
+	LastRun             time.Time `json:"last_run,omitempty"`
+	LastSuccess         time.Time `json:"last_success,omitempty"`
+	NextRetry           time.Time `json:"next_retry,omitempty"`
+	// the previous run is still in progress
+	Busy bool `json:"busy"`
+	// ticks skipped because the previous run was still in progress
+	SkippedTicks int       `json:"skipped_ticks"`
+	LastSkipped  time.Time `json:"last_skipped,omitempty"`
+}
+
+// Supervisor runs workers with exponential backoff and tracks their state.
+// A worker that exhausts its retries is marked as degraded instead of stopping the process.
+type Supervisor struct {
+	mu      sync.RWMutex
+	workers map[string]*WorkerStatus
+	backoff BackoffConfig
+	logger  *zerolog.Logger
+	// replaced in tests
+	sleep func(context.Context, time.Duration) error
+}
+
+func NewSupervisor(backoff BackoffConfig, logger *zerolog.Logger) *Supervisor {
+	return &Supervisor{
+		workers: map[string]*WorkerStatus{},
+		backoff: backoff,
+		logger:  logger,
+		sleep:   sleepCtx,
+	}
+}
+
+// Register makes the worker visible in the status before it runs for the first time
+func (s *Supervisor) Register(name string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.workers[name]; !ok {
+		s.workers[name] = &WorkerStatus{Name: name, State: WORKER_STATE_IDLE}
+	}
+}
+
+// TryLock marks the worker as busy. Returns false and counts a skipped tick
+// if the previous run of the worker is still in progress.
+func (s *Supervisor) TryLock(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	w, ok := s.workers[name]
+	if !ok {
+		w = &WorkerStatus{Name: name, State: WORKER_STATE_IDLE}
+		s.workers[name] = w
+	}
+	if w.Busy {
+		w.SkippedTicks++
+		w.LastSkipped = time.Now()
+		return false
+	}
+	w.Busy = true
+	return true
+}
+
+func (s *Supervisor) Unlock(name string) {
+	s.update(name, func(w *WorkerStatus) { w.Busy = false })
+}
+
+// Run calls fn until it succeeds or the retries are exhausted.
+// Returns the last error if the worker ended up degraded or the context error if it was cancelled.
+func (s *Supervisor) Run(ctx context.Context, name string, fn func(context.Context) error) error {
+	s.Register(name)
+	for attempt := 0; ; attempt++ {
+		s.update(name, func(w *WorkerStatus) {
+			w.State = WORKER_STATE_RUNNING
+			w.LastRun = time.Now()
+			w.NextRetry = time.Time{}
+		})
+
+		err := fn(ctx)
+		if ctx.Err() != nil {
+			// shutting down -- not a worker failure
+			s.update(name, func(w *WorkerStatus) { w.State = WORKER_STATE_IDLE })
+			return ctx.Err()
+		}
+		if err == nil {
+			s.update(name, func(w *WorkerStatus) {
+				w.State = WORKER_STATE_HEALTHY
+				w.ConsecutiveFailures = 0
+				w.LastError = ""
+				w.LastSuccess = time.Now()
+			})
+			return nil
+		}
+
+		if attempt >= s.backoff.MaxRetries {
+			s.update(name, func(w *WorkerStatus) {
+				w.State = WORKER_STATE_DEGRADED
+				w.ConsecutiveFailures++
+				w.TotalFailures++
+				w.LastError = err.Error()
+			})
+			s.logger.Error().Err(err).Str("worker", name).Int("attempts", attempt+1).Msg("worker retries exceeded -- marked as degraded")
+			return err
+		}
+
+		delay := s.backoffDelay(attempt)
+		s.update(name, func(w *WorkerStatus) {
+			w.State = WORKER_STATE_RETRYING
+			w.ConsecutiveFailures++
+			w.TotalFailures++
+			w.LastError = err.Error()
+			w.NextRetry = time.Now().Add(delay)
+		})
+		s.logger.Error().Err(err).Str("worker", name).Dur("retry_in", delay).Msg("worker failed")
+		if err := s.sleep(ctx, delay); err != nil {
+			s.update(name, func(w *WorkerStatus) { w.State = WORKER_STATE_IDLE })
+			return err
+		}
+	}
+}
+
+// Status returns the state of all workers sorted by name
+func (s *Supervisor) Status() []WorkerStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	status := make([]WorkerStatus, 0, len(s.workers))
+	for _, w := range s.workers {
+		status = append(status, *w)
+	}
+	sort.Slice(status, func(i, j int) bool { return status[i].Name < status[j].Name })
+	return status
+}
+
+// Degraded returns true if any worker is degraded
+func (s *Supervisor) Degraded() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, w := range s.workers {
+		if w.State == WORKER_STATE_DEGRADED {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Supervisor) update(name string, fn func(w *WorkerStatus)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	fn(s.workers[name])
+}
+
+// backoffDelay doubles the initial delay for every attempt up to the max and applies jitter
+func (s *Supervisor) backoffDelay(attempt int) time.Duration {
+	delay := s.backoff.Initial
+	for i := 0; i < attempt && delay < s.backoff.Max; i++ {
+		delay *= 2
+	}
+	delay = min(delay, s.backoff.Max)
+	if s.backoff.Jitter > 0 {
+		spread := float64(delay) * s.backoff.Jitter
+		delay += time.Duration(spread * (2*rand.Float64() - 1))
+	}
+	return delay
+}
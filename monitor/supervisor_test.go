@@ -0,0 +1,109 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/rs/zerolog"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSupervisorRun(t *testing.T) {
+	logger := zerolog.Nop()
+	s := NewSupervisor(BackoffConfig{Initial: time.Second, Max: 3 * time.Second, MaxRetries: 3}, &logger)
+	sleeps := []time.Duration{}
+	s.sleep = func(_ context.Context, d time.Duration) error {
+		sleeps = append(sleeps, d)
+		return nil
+	}
+
+	calls := 0
+	err := s.Run(context.Background(), "arbitrum_balances", func(context.Context) error {
+		calls++
+		return fmt.Errorf("rate limited")
+	})
+	require.Error(t, err)
+	assert.Equal(t, 4, calls)
+	assert.Equal(t, []time.Duration{time.Second, 2 * time.Second, 3 * time.Second}, sleeps)
+	assert.True(t, s.Degraded())
+
+	status := s.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, WORKER_STATE_DEGRADED, status[0].State)
+	assert.Equal(t, 4, status[0].ConsecutiveFailures)
+	assert.Equal(t, "rate limited", status[0].LastError)
+
+	// the next successful run recovers the worker
+	require.NoError(t, s.Run(context.Background(), "arbitrum_balances", func(context.Context) error { return nil }))
+	assert.False(t, s.Degraded())
+	status = s.Status()
+	assert.Equal(t, WORKER_STATE_HEALTHY, status[0].State)
+	assert.Equal(t, 0, status[0].ConsecutiveFailures)
+	assert.Equal(t, 4, status[0].TotalFailures)
+}
+
+func TestSupervisorBackoffJitter(t *testing.T) {
+	logger := zerolog.Nop()
+	s := NewSupervisor(BackoffConfig{Initial: 10 * time.Second, Max: time.Minute, Jitter: 0.2}, &logger)
+	for i := 0; i < 100; i++ {
+		d := s.backoffDelay(1)
+		assert.GreaterOrEqual(t, d, 16*time.Second)
+		assert.LessOrEqual(t, d, 24*time.Second)
+	}
+}
+
+func TestSupervisorRunCancelled(t *testing.T) {
+	logger := zerolog.Nop()
+	s := NewSupervisor(BackoffConfig{Initial: time.Hour, Max: time.Hour, MaxRetries: 3}, &logger)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	calls := 0
+	go func() {
+		// cancel while the worker waits for the retry
+		time.Sleep(50 * time.Millisecond)
+		cancel()
+	}()
+	err := s.Run(ctx, "base_balances", func(context.Context) error {
+		calls++
+		return fmt.Errorf("rate limited")
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, calls)
+	assert.False(t, s.Degraded())
+	assert.Equal(t, WORKER_STATE_IDLE, s.Status()[0].State)
+}
+
+func TestRunExclusiveSkipsOverlappingTicks(t *testing.T) {
+	m := newTestMonitor()
+	m.supervisor = NewSupervisor(DefaultBackoffConfig, m.logger)
+
+	var wg sync.WaitGroup
+	release := make(chan struct{})
+	runs := 0
+	run := func() {
+		runs++
+		<-release
+	}
+
+	m.runExclusive(&wg, "arbitrum", run)
+	// previous run is still in progress -- both ticks are skipped
+	m.runExclusive(&wg, "arbitrum", run)
+	m.runExclusive(&wg, "arbitrum", run)
+	close(release)
+	wg.Wait()
+
+	status := m.supervisor.Status()
+	require.Len(t, status, 1)
+	assert.Equal(t, 1, runs)
+	assert.Equal(t, 2, status[0].SkippedTicks)
+	assert.False(t, status[0].Busy)
+
+	// the worker runs again once the previous run finished
+	m.runExclusive(&wg, "arbitrum", func() { runs++ })
+	wg.Wait()
+	assert.Equal(t, 2, runs)
+}
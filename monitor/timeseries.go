@@ -0,0 +1,124 @@
+package monitor
+
+import (
+	"fmt"
+	"time"
+)
+
+// timeseries bucket sizes, buckets start at UTC midnight and weeks on monday
+const (
+	TIMESERIES_BUCKET_HOUR = "hour"
+	TIMESERIES_BUCKET_DAY  = "day"
+	TIMESERIES_BUCKET_WEEK = "week"
+	MAX_TIMESERIES_BUCKETS = 2000
+)
+
+// unix time of the first monday, week buckets are aligned to it
+const firstMondayUnix = 4 * 24 * 60 * 60
+
+var timeseriesBucketSizes = map[string]time.Duration{
+	TIMESERIES_BUCKET_HOUR: time.Hour,
+	TIMESERIES_BUCKET_DAY:  24 * time.Hour,
+	TIMESERIES_BUCKET_WEEK: 7 * 24 * time.Hour,
+}
+
+// timeseriesBucket returns the size and the offset of the buckets from unix time 0 in seconds
+func timeseriesBucket(bucket string) (int64, int64, error) {
+	size, ok := timeseriesBucketSizes[bucket]
+	if !ok {
+		return 0, 0, fmt.Errorf("invalid bucket %q", bucket)
+	}
+	if bucket == TIMESERIES_BUCKET_WEEK {
+		return int64(size.Seconds()), firstMondayUnix, nil
+	}
+	return int64(size.Seconds()), 0, nil
+}
+
+// bucketStart returns the start of the bucket containing ts, same as the bucket_start of GetDbFillTimeseries
+func bucketStart(ts, size, offset int64) int64 {
+	return (ts-offset)/size*size + offset
+}
+
+// TimeseriesPoint are the successful fills of a source domain in a bucket, as returned by the db
+type TimeseriesPoint struct {
+	SourceDomain string
+	BucketStart  int64
+	Orders       int64
+	Volume       int64
+	Revenue      int64
+}
+
+type TimeseriesBucket struct {
+	Start   time.Time `json:"start"`
+	Orders  int64     `json:"orders"`
+	Volume  string    `json:"volume"`
+	Revenue string    `json:"revenue"`
+}
+
+type DomainTimeseries struct {
+	SourceDomain string             `json:"source_domain"`
+	Network      string             `json:"network"`
+	Buckets      []TimeseriesBucket `json:"buckets"`
+}
+
+type FillTimeseries struct {
+	Filler        string             `json:"filler"`
+	Bucket        string             `json:"bucket"`
+	From          time.Time          `json:"from"`
+	To            time.Time          `json:"to"`
+	SourceDomains []DomainTimeseries `json:"source_domains"`
+}
+
+// GetFillTimeseries returns the revenue, volume (amount_in) and number of successful fills of the filler per bucket and
+// source domain in [from, to). Buckets without fills are included.
+func (m *Monitor) GetFillTimeseries(filler, bucket string, from, to time.Time, asInteger bool) (FillTimeseries, error) {
+	size, offset, err := timeseriesBucket(bucket)
+	if err != nil {
+		return FillTimeseries{}, err
+	}
+	points, err := m.GetDbFillTimeseries(filler, size, offset, from.Unix(), to.Unix())
+	if err != nil {
+		return FillTimeseries{}, err
+	}
+	series := aggregateTimeseries(points, size, offset, from.Unix(), to.Unix(), asInteger)
+	series.Filler, series.Bucket, series.From, series.To = filler, bucket, from, to
+	return series, nil
+}
+
+// timeseriesBucketCount returns the number of buckets between from and to
+func timeseriesBucketCount(bucket string, from, to time.Time) (int64, error) {
+	size, offset, err := timeseriesBucket(bucket)
+	if err != nil {
+		return 0, err
+	}
+	if !to.After(from) {
+		return 0, nil
+	}
+	return (bucketStart(to.Unix()-1, size, offset)-bucketStart(from.Unix(), size, offset))/size + 1, nil
+}
+
+func aggregateTimeseries(points []TimeseriesPoint, size, offset, from, to int64, asInteger bool) FillTimeseries {
+	domains := map[string]map[int64]TimeseriesPoint{}
+	for _, p := range points {
+		if _, ok := domains[p.SourceDomain]; !ok {
+			domains[p.SourceDomain] = map[int64]TimeseriesPoint{}
+		}
+		domains[p.SourceDomain][p.BucketStart] = p
+	}
+
+	series := FillTimeseries{SourceDomains: []DomainTimeseries{}}
+	for _, sourceDomain := range sortedMapKeys(domains) {
+		d := DomainTimeseries{SourceDomain: sourceDomain, Network: ChainIdToNetwork[sourceDomain], Buckets: []TimeseriesBucket{}}
+		for start := bucketStart(from, size, offset); start < to; start += size {
+			p := domains[sourceDomain][start]
+			d.Buckets = append(d.Buckets, TimeseriesBucket{
+				Start:   time.Unix(start, 0).UTC(),
+				Orders:  p.Orders,
+				Volume:  formatUsdc(p.Volume, asInteger),
+				Revenue: formatUsdc(p.Revenue, asInteger),
+			})
+		}
+		series.SourceDomains = append(series.SourceDomains, d)
+	}
+	return series
+}
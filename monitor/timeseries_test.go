@@ -0,0 +1,95 @@
+package monitor
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTimeseriesBucket(t *testing.T) {
+	ts := time.Date(2025, 3, 19, 15, 42, 7, 0, time.UTC) // wednesday
+	for bucket, expected := range map[string]time.Time{
+		TIMESERIES_BUCKET_HOUR: time.Date(2025, 3, 19, 15, 0, 0, 0, time.UTC),
+		TIMESERIES_BUCKET_DAY:  time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC),
+		TIMESERIES_BUCKET_WEEK: time.Date(2025, 3, 17, 0, 0, 0, 0, time.UTC),
+	} {
+		size, offset, err := timeseriesBucket(bucket)
+		require.NoError(t, err)
+		assert.Equal(t, expected.Unix(), bucketStart(ts.Unix(), size, offset), bucket)
+	}
+	_, _, err := timeseriesBucket("month")
+	assert.Error(t, err)
+
+	from := time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)
+	count, err := timeseriesBucketCount(TIMESERIES_BUCKET_DAY, from, from.AddDate(0, 0, 31))
+	require.NoError(t, err)
+	assert.Equal(t, int64(31), count)
+	// mar 1 is a saturday, the range touches 6 weeks
+	count, err = timeseriesBucketCount(TIMESERIES_BUCKET_WEEK, from, from.AddDate(0, 0, 31))
+	require.NoError(t, err)
+	assert.Equal(t, int64(6), count)
+}
+
+func TestAggregateTimeseries(t *testing.T) {
+	from := time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC).Unix()
+	hour := int64(3600)
+	points := []TimeseriesPoint{
+		{SourceDomain: "42161", BucketStart: from + hour, Orders: 2, Volume: 3_000_000, Revenue: 150_000},
+		{SourceDomain: "1", BucketStart: from, Orders: 1, Volume: 100_000_000, Revenue: 1_000_000},
+	}
+
+	series := aggregateTimeseries(points, hour, 0, from, from+3*hour, false)
+	require.Len(t, series.SourceDomains, 2)
+	assert.Equal(t, "1", series.SourceDomains[0].SourceDomain)
+	assert.Equal(t, ETHEREUM_NETWORK, series.SourceDomains[0].Network)
+	assert.Equal(t, DomainTimeseries{
+		SourceDomain: "42161",
+		Network:      ARBITRUM_NETWORK,
+		Buckets: []TimeseriesBucket{
+			{Start: time.Unix(from, 0).UTC(), Volume: "0", Revenue: "0"},
+			{Start: time.Unix(from+hour, 0).UTC(), Orders: 2, Volume: "3", Revenue: "0.15"},
+			{Start: time.Unix(from+2*hour, 0).UTC(), Volume: "0", Revenue: "0"},
+		},
+	}, series.SourceDomains[1])
+}
+
+func TestGetFillTimeseries(t *testing.T) {
+	m := newTestMonitorWithDB(t, nil)
+	orders := seedFixtureOrders(t, m)
+
+	filler := orders[0].Filler
+	expectedOrders, expectedRevenue := int64(0), int64(0)
+	for _, o := range orders {
+		if o.Filler == filler && o.Code == 0 {
+			expectedOrders++
+			expectedRevenue += o.SolverRevenue
+		}
+	}
+
+	from := time.Date(2025, 3, 18, 0, 0, 0, 0, time.UTC)
+	series, err := m.GetFillTimeseries(filler, TIMESERIES_BUCKET_DAY, from, from.AddDate(0, 0, 3), true)
+	require.NoError(t, err)
+	assert.Equal(t, filler, series.Filler)
+
+	totalOrders, totalRevenue := int64(0), int64(0)
+	for _, d := range series.SourceDomains {
+		// empty days around the fills are included
+		require.Len(t, d.Buckets, 3)
+		assert.Zero(t, d.Buckets[0].Orders)
+		assert.Equal(t, time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC), d.Buckets[1].Start)
+		for _, b := range d.Buckets {
+			totalOrders += b.Orders
+			revenue, err := strconv.ParseInt(b.Revenue, 10, 64)
+			require.NoError(t, err)
+			totalRevenue += revenue
+		}
+	}
+	assert.Equal(t, expectedOrders, totalOrders)
+	assert.Equal(t, expectedRevenue, totalRevenue)
+
+	_, err = m.GetFillTimeseries(filler, "month", from, from.AddDate(0, 0, 3), true)
+	assert.Error(t, err)
+}
@@ -0,0 +1,142 @@
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+const (
+	TRANSFER_DIRECTION_IN  = "in"
+	TRANSFER_DIRECTION_OUT = "out"
+)
+
+// EthTokenTransfer is a single result of the etherscan tokentx action
+type EthTokenTransfer struct {
+	BlockNumber      string `json:"blockNumber"`
+	TimeStamp        string `json:"timeStamp"`
+	Hash             string `json:"hash"`
+	BlockHash        string `json:"blockHash"`
+	From             string `json:"from"`
+	To               string `json:"to"`
+	ContractAddress  string `json:"contractAddress"`
+	Value            string `json:"value"`
+	TokenName        string `json:"tokenName"`
+	TokenSymbol      string `json:"tokenSymbol"`
+	TokenDecimal     string `json:"tokenDecimal"`
+	TransactionIndex string `json:"transactionIndex"`
+	LogIndex         string `json:"logIndex"` // not returned by all etherscan compatible APIs
+}
+
+// RunEtherscanTokenTransfers stores USDC transfers to and from the chain address
+// starting at the stored tokentx cursor.
+func (m *Monitor) RunEtherscanTokenTransfers(ctx context.Context, chain ChainConfig) error {
+	if chain.UsdcAddress == "" {
+		return nil
+	}
+	startBlock := m.getEtherscanStartBlock(chain.Name, ETHERSCAN_ACTION_TOKENTX)
+	if err := m.syncEtherscanTokenTransfers(ctx, chain, startBlock); err != nil {
+		return fmt.Errorf("failed to get token transfers: %w", err)
+	}
+	return nil
+}
+
+func (m *Monitor) syncEtherscanTokenTransfers(ctx context.Context, chain ChainConfig, startBlock int64) error {
+	network := chain.Name
+	total, totalInserted, totalFailed, totalSkipped := 0, 0, 0, 0
+
+	// the cursor is not advanced past the first transfer that failed to store so it is fetched again on the next run.
+	// Transfers that can't be converted would fail again and are skipped.
+	failedBlock := ""
+
+	handle := func(transfers []EthTokenTransfer) error {
+		total += len(transfers)
+		for _, t := range transfers {
+			transfer, err := toDbTokenTransfer(chain, t)
+			if err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", t.Hash).Str("network", network).Msg("failed to convert token transfer -- skipping")
+				totalSkipped++
+				continue
+			}
+			inserted, err := m.InsertTokenTransfer(ctx, transfer)
+			if err != nil {
+				m.logger.Error().Err(err).Str("tx_hash", t.Hash).Str("network", network).Msg("failed to insert token transfer")
+				totalFailed++
+				if failedBlock == "" {
+					failedBlock = t.BlockNumber
+				}
+				continue
+			}
+			if inserted {
+				totalInserted++
+			}
+		}
+		if len(transfers) == 0 {
+			return nil
+		}
+		cursorBlock := transfers[len(transfers)-1].BlockNumber
+		if failedBlock != "" {
+			cursorBlock = failedBlock
+		}
+		lastBlock, err := strconv.ParseInt(cursorBlock, 10, 64)
+		if err != nil {
+			return fmt.Errorf("failed to parse block number: %w", err)
+		}
+		return m.UpsertEthCursor(ctx, network, ETHERSCAN_ACTION_TOKENTX, lastBlock)
+	}
+
+	// tokentx accepts the token contract as a filter
+	extra := url.Values{}
+	extra.Add("contractaddress", chain.UsdcAddress)
+	err := walkEtherscanHistory(ctx, m, chain, ETHERSCAN_ACTION_TOKENTX, extra, startBlock,
+		func(t EthTokenTransfer) string { return t.BlockNumber },
+		handle)
+
+	m.logger.Info().Int("total", total).
+		Int("new", totalInserted).
+		Int("failed", totalFailed).
+		Int("skipped", totalSkipped).
+		Int64("start_block", startBlock).
+		Str("network", network).
+		Msg("finished processing USDC transfers")
+	return err
+}
+
+func toDbTokenTransfer(chain ChainConfig, t EthTokenTransfer) (DbTokenTransfer, error) {
+	height, err := strconv.ParseInt(t.BlockNumber, 10, 64)
+	if err != nil {
+		return DbTokenTransfer{}, fmt.Errorf("failed to parse block number: %w", err)
+	}
+	timestamp, err := strconv.ParseInt(t.TimeStamp, 10, 64)
+	if err != nil {
+		return DbTokenTransfer{}, fmt.Errorf("failed to parse timestamp: %w", err)
+	}
+	// USDC amounts always fit into int64 (6 decimals)
+	amount, err := strconv.ParseInt(t.Value, 10, 64)
+	if err != nil {
+		return DbTokenTransfer{}, fmt.Errorf("failed to parse amount: %w", err)
+	}
+
+	direction := TRANSFER_DIRECTION_OUT
+	counterparty := t.To
+	if strings.EqualFold(t.To, chain.Address) {
+		direction = TRANSFER_DIRECTION_IN
+		counterparty = t.From
+	}
+
+	return DbTokenTransfer{
+		TxHash:        t.Hash,
+		LogIndex:      t.LogIndex,
+		Network:       chain.Name,
+		Token:         "USDC",
+		TokenAddress:  strings.ToLower(t.ContractAddress),
+		Direction:     direction,
+		Counterparty:  strings.ToLower(counterparty),
+		Amount:        amount,
+		Height:        height,
+		Timestamp:     timestamp,
+		SolverAddress: strings.ToLower(chain.Address),
+	}, nil
+}
@@ -0,0 +1,210 @@
+package monitor
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testSolverAddress = "0x1111111111111111111111111111111111111111"
+	testUsdcAddress   = "0xaf88d065e77c8cC2239327C5EDb3A432268e5831"
+)
+
+func TestToDbTokenTransfer(t *testing.T) {
+	chain := ChainConfig{Name: ARBITRUM_NETWORK, ChainEntry: ChainEntry{Address: testSolverAddress}}
+	other := "0x2222222222222222222222222222222222222222"
+	transfer := func(from, to, value, logIndex string) EthTokenTransfer {
+		return EthTokenTransfer{
+			BlockNumber:     "100",
+			TimeStamp:       "1742400000",
+			Hash:            "0xabc",
+			From:            from,
+			To:              to,
+			ContractAddress: testUsdcAddress,
+			Value:           value,
+			LogIndex:        logIndex,
+		}
+	}
+
+	tests := []struct {
+		name         string
+		transfer     EthTokenTransfer
+		direction    string
+		counterparty string
+		logIndex     string
+		wantErr      bool
+	}{
+		{
+			name:         "inflow",
+			transfer:     transfer(other, testSolverAddress, "1000000", "3"),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+			logIndex:     "3",
+		},
+		{
+			name:         "outflow",
+			transfer:     transfer(testSolverAddress, other, "1000000", "4"),
+			direction:    TRANSFER_DIRECTION_OUT,
+			counterparty: other,
+			logIndex:     "4",
+		},
+		{
+			// addresses are compared and stored case insensitive
+			name:         "checksummed solver address",
+			transfer:     transfer("0xAbCdEf0000000000000000000000000000000000", "0x1111111111111111111111111111111111111111", "5", "0"),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: "0xabcdef0000000000000000000000000000000000",
+			logIndex:     "0",
+		},
+		{
+			// not returned by all etherscan compatible APIs
+			name:         "missing log index",
+			transfer:     transfer(other, testSolverAddress, "1000000", ""),
+			direction:    TRANSFER_DIRECTION_IN,
+			counterparty: other,
+		},
+		{
+			name:     "invalid amount",
+			transfer: transfer(other, testSolverAddress, "1.5", "1"),
+			wantErr:  true,
+		},
+		{
+			name: "invalid block number",
+			transfer: func() EthTokenTransfer {
+				tr := transfer(other, testSolverAddress, "1", "1")
+				tr.BlockNumber = ""
+				return tr
+			}(),
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := toDbTokenTransfer(chain, tt.transfer)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.direction, got.Direction)
+			assert.Equal(t, tt.counterparty, got.Counterparty)
+			assert.Equal(t, tt.logIndex, got.LogIndex)
+			assert.Equal(t, "USDC", got.Token)
+			assert.Equal(t, "0xaf88d065e77c8cc2239327c5edb3a432268e5831", got.TokenAddress)
+			assert.Equal(t, testSolverAddress, got.SolverAddress)
+			assert.Equal(t, int64(100), got.Height)
+			assert.Equal(t, int64(1742400000), got.Timestamp)
+		})
+	}
+}
+
+func TestGetDbTokenFlows(t *testing.T) {
+	ctx := context.Background()
+	m := newTestMonitorWithDB(t, nil)
+
+	day := time.Date(2025, 3, 19, 0, 0, 0, 0, time.UTC)
+	transfers := []DbTokenTransfer{
+		{TxHash: "a", Network: ARBITRUM_NETWORK, Direction: TRANSFER_DIRECTION_IN, Counterparty: "x", Amount: 5_000_000, Timestamp: day.Unix()},
+		{TxHash: "b", Network: ARBITRUM_NETWORK, Direction: TRANSFER_DIRECTION_IN, Counterparty: "x", Amount: 2_000_000, Timestamp: day.Add(time.Hour).Unix()},
+		{TxHash: "c", Network: ARBITRUM_NETWORK, Direction: TRANSFER_DIRECTION_OUT, Counterparty: "y", Amount: 10_000_000, Timestamp: day.Add(2 * time.Hour).Unix()},
+		{TxHash: "d", Network: ARBITRUM_NETWORK, Direction: TRANSFER_DIRECTION_OUT, Counterparty: "y", Amount: 1_000_000, Timestamp: day.AddDate(0, 0, 1).Unix()},
+		{TxHash: "e", Network: BASE_NETWORK, Direction: TRANSFER_DIRECTION_IN, Counterparty: "x", Amount: 3_000_000, Timestamp: day.Unix()},
+		// outside of the range
+		{TxHash: "f", Network: BASE_NETWORK, Direction: TRANSFER_DIRECTION_IN, Counterparty: "x", Amount: 3_000_000, Timestamp: day.AddDate(0, 0, 2).Unix()},
+	}
+	for _, transfer := range transfers {
+		transfer.Token = "USDC"
+		inserted, err := m.InsertTokenTransfer(ctx, transfer)
+		require.NoError(t, err)
+		require.True(t, inserted)
+	}
+	// the same transfer is stored once
+	inserted, err := m.InsertTokenTransfer(ctx, DbTokenTransfer{TxHash: "a", Token: "USDC", Network: ARBITRUM_NETWORK, Direction: TRANSFER_DIRECTION_IN, Counterparty: "x", Amount: 5_000_000, Timestamp: day.Unix()})
+	require.NoError(t, err)
+	assert.False(t, inserted)
+
+	flows, err := m.GetDbTokenFlows("USDC", "", day, day.AddDate(0, 0, 2))
+	require.NoError(t, err)
+	assert.Equal(t, []TokenFlow{
+		{Network: ARBITRUM_NETWORK, Day: "2025-03-19", Inflow: "7000000", Outflow: "10000000", NetFlow: "-3000000", TransferCount: 3},
+		{Network: ARBITRUM_NETWORK, Day: "2025-03-20", Inflow: "0", Outflow: "1000000", NetFlow: "-1000000", TransferCount: 1},
+		{Network: BASE_NETWORK, Day: "2025-03-19", Inflow: "3000000", Outflow: "0", NetFlow: "3000000", TransferCount: 1},
+	}, flows)
+
+	flows, err = m.GetDbTokenFlows("USDC", BASE_NETWORK, day, day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	require.Len(t, flows, 2)
+	assert.Equal(t, "2025-03-21", flows[1].Day)
+
+	flows, err = m.GetDbTokenFlows("USDT", "", day, day.AddDate(0, 0, 3))
+	require.NoError(t, err)
+	assert.Empty(t, flows)
+}
+
+func TestSyncEtherscanTokenTransfersHoldsCursorAtFailure(t *testing.T) {
+	etherscanPageSleep = 0
+	ctx := context.Background()
+
+	transfers := []EthTokenTransfer{}
+	for block := 10; block <= 40; block += 10 {
+		transfers = append(transfers, EthTokenTransfer{
+			BlockNumber:     strconv.Itoa(block),
+			TimeStamp:       "1742400000",
+			Hash:            "0x" + strconv.Itoa(block),
+			From:            "0x2222222222222222222222222222222222222222",
+			To:              testSolverAddress,
+			ContractAddress: testUsdcAddress,
+			Value:           "1000000",
+			LogIndex:        "0",
+		})
+	}
+	// can't be converted and is skipped
+	transfers[3].Value = "invalid"
+
+	requests := []etherscanRequest{}
+	srv := fakeEtherscan(t, transfers, func(t EthTokenTransfer) string { return t.BlockNumber }, &requests)
+
+	m := newTestMonitorWithDB(t, nil)
+	chain := ChainConfig{
+		Name:       ARBITRUM_NETWORK,
+		ChainEntry: ChainEntry{ApiUrl: srv.URL, Address: testSolverAddress, UsdcAddress: testUsdcAddress},
+	}
+	_, err := m.db.Exec(`
+		CREATE TRIGGER fail_transfer BEFORE INSERT ON token_transfers
+		WHEN NEW.tx_hash = '0x20'
+		BEGIN SELECT RAISE(ABORT, 'disk I/O error'); END
+	`)
+	require.NoError(t, err)
+
+	require.NoError(t, m.RunEtherscanTokenTransfers(ctx, chain))
+	cursor, err := m.GetEthCursor(ARBITRUM_NETWORK, ETHERSCAN_ACTION_TOKENTX)
+	require.NoError(t, err)
+	assert.Equal(t, int64(20), cursor)
+
+	// the transfers after the failed one are stored anyway
+	flows, err := m.GetDbTokenFlows("USDC", ARBITRUM_NETWORK, time.Unix(0, 0), time.Now())
+	require.NoError(t, err)
+	require.Len(t, flows, 1)
+	assert.Equal(t, int64(2), flows[0].TransferCount)
+
+	// the next run starts at the failed transfer
+	_, err = m.db.Exec("DROP TRIGGER fail_transfer")
+	require.NoError(t, err)
+	requests = requests[:0]
+	require.NoError(t, m.RunEtherscanTokenTransfers(ctx, chain))
+	require.NotEmpty(t, requests)
+	assert.Equal(t, 20, requests[0].startBlock)
+
+	// the skipped transfer doesn't hold the cursor
+	cursor, err = m.GetEthCursor(ARBITRUM_NETWORK, ETHERSCAN_ACTION_TOKENTX)
+	require.NoError(t, err)
+	assert.Equal(t, int64(40), cursor)
+	flows, err = m.GetDbTokenFlows("USDC", ARBITRUM_NETWORK, time.Unix(0, 0), time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), flows[0].TransferCount)
+}
@@ -4,6 +4,9 @@ import "time"
 
 type FillOrderEnvelope struct {
 	FillOrder *OrderEnvelope `json:"fill_order"`
+	// position of the message in the tx and inside the authz.MsgExec (-1 if not executed with authz)
+	MsgIndex      int `json:"-"`
+	AuthzMsgIndex int `json:"-"`
 }
 
 type OrderEnvelope struct {
@@ -32,3 +35,31 @@ type OrderWithMeta struct {
 	SolverRevenue      int       `json:"solver_revenue"`
 	IngestionTimestamp time.Time `json:"ingestion_timestamp"`
 }
+
+// ContractActionEnvelope holds the execute messages of the fast transfer contract other than fill_order
+type ContractActionEnvelope struct {
+	InitiateSettlement *InitiateSettlementMsg `json:"initiate_settlement,omitempty"`
+	InitiateTimeout    *InitiateTimeoutMsg    `json:"initiate_timeout,omitempty"`
+	Process            *HyperlaneProcessMsg   `json:"process,omitempty"`
+}
+
+// InitiateSettlementMsg is sent by the filler to get repaid on the source domain of the filled orders
+type InitiateSettlementMsg struct {
+	// hex encoded order ids
+	OrderIds         []string `json:"order_ids"`
+	RepaymentAddress string   `json:"repayment_address"`
+}
+
+// InitiateTimeoutMsg refunds expired orders that were never filled
+type InitiateTimeoutMsg struct {
+	Orders []FastTransferOrder `json:"orders"`
+}
+
+// HyperlaneProcessMsg delivers a message from the contract on another domain to the hyperlane mailbox.
+// Relayers execute process on the mailbox, which calls handle on the recipient contract as a sub-message.
+// The body of the message carries the settle_orders and refund_orders commands for orders with osmosis as source domain.
+type HyperlaneProcessMsg struct {
+	// hex encoded
+	Metadata string `json:"metadata"`
+	Message  string `json:"message"`
+}